@@ -8,15 +8,28 @@ import (
 )
 
 func NewAddCommand() *cobra.Command {
-	var stageDeleted bool
+	var stageDeleted, patch bool
 	cmd := &cobra.Command{
-		Use:     "add <files...>",
+		Use:     "add [-p] <files...>",
 		Short:   "Add files to the staging area",
-		Args:    cobra.MinimumNArgs(1),
+		Args:    cobra.ArbitraryArgs,
 		PreRunE: preRunErr,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && !patch {
+				return fmt.Errorf("nothing specified, nothing added")
+			}
+
+			paths, err := repoPaths(args)
+			if err != nil {
+				return err
+			}
 
-			added, err := add.Add(repoPath, stageDeleted, args)
+			var added []add.AddResult
+			if patch {
+				added, err = add.AddPatch(repoPath, paths, newHunkPrompter())
+			} else {
+				added, err = add.Add(repoPath, stageDeleted, paths)
+			}
 			if err != nil {
 				return err
 			}
@@ -38,5 +51,6 @@ func NewAddCommand() *cobra.Command {
 	}
 
 	cmd.Flags().BoolVarP(&stageDeleted, "deletions", "d", false, "Stage deletions")
+	cmd.Flags().BoolVarP(&patch, "patch", "p", false, "Choose the hunks of the changes to stage")
 	return cmd
 }
@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/matiasmartin00/arbor/internal/bisect"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/spf13/cobra"
+)
+
+func NewBisectCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bisect",
+		Short: "Use binary search to find the commit that introduced a bug",
+	}
+
+	startCmd := &cobra.Command{
+		Use:     "start <bad> <good>...",
+		Short:   "Start bisecting between a bad commit and one or more good ones",
+		Args:    cobra.MinimumNArgs(2),
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			step, err := bisect.Start(repoPath, args[0], args[1:])
+			if err != nil {
+				return err
+			}
+
+			printBisectStep(step)
+			return nil
+		},
+	}
+
+	markCmd := func(term bisect.Term, short string) *cobra.Command {
+		return &cobra.Command{
+			Use:     fmt.Sprintf("%s [<revision>]", term),
+			Short:   short,
+			Args:    cobra.MaximumNArgs(1),
+			PreRunE: preRunErr,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				rev := ""
+				if len(args) == 1 {
+					rev = args[0]
+				}
+
+				step, err := bisect.Mark(repoPath, term, rev)
+				if err != nil {
+					return err
+				}
+
+				printBisectStep(step)
+				return nil
+			},
+		}
+	}
+
+	resetCmd := &cobra.Command{
+		Use:     "reset",
+		Short:   "End bisecting and go back to the commit checked out before",
+		Args:    cobra.NoArgs,
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := bisect.Reset(repoPath); err != nil {
+				return err
+			}
+
+			fmt.Println("Bisect finished, worktree restored.")
+			return nil
+		},
+	}
+
+	logCmd := &cobra.Command{
+		Use:     "log",
+		Short:   "Show the bisect commands run so far",
+		Args:    cobra.NoArgs,
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lines, err := bisect.Log(repoPath)
+			if err != nil {
+				return err
+			}
+
+			for _, l := range lines {
+				fmt.Println(l)
+			}
+			return nil
+		},
+	}
+
+	runCmd := &cobra.Command{
+		Use:   "run <cmd> [<args>...]",
+		Short: "Test commits automatically with a command",
+		Long: `Runs the command on each commit to test and classifies it by exit code:
+  0         the commit is good
+  125       the commit can not be tested, skip it
+  1 - 127   the commit is bad
+  other     abort bisecting`,
+		Args:    cobra.MinimumNArgs(1),
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			step, err := bisect.Run(repoPath, args, func(tested object.ObjectHash, term bisect.Term, next bisect.Step) {
+				fmt.Printf("%s is %s\n", tested.Short(7), term)
+				if !next.Done {
+					printBisectStep(next)
+				}
+			})
+			if err != nil {
+				return err
+			}
+
+			printBisectStep(step)
+			return nil
+		},
+	}
+	// everything after <cmd> belongs to the command
+	runCmd.Flags().SetInterspersed(false)
+
+	cmd.AddCommand(
+		startCmd,
+		markCmd(bisect.Good, "Mark a commit (the current one by default) as good"),
+		markCmd(bisect.Bad, "Mark a commit (the current one by default) as bad"),
+		markCmd(bisect.Skip, "Skip a commit (the current one by default) that can not be tested"),
+		resetCmd,
+		logCmd,
+		runCmd,
+	)
+	return cmd
+}
+
+func printBisectStep(step bisect.Step) {
+	if step.Done {
+		if step.FirstBad != nil {
+			fmt.Printf("%s is the first bad commit\n", step.FirstBad)
+			return
+		}
+
+		fmt.Println("There are only skipped commits left to test.")
+		fmt.Println("The first bad commit could be any of:")
+		for _, c := range step.Candidates {
+			fmt.Printf("  %s\n", c)
+		}
+		return
+	}
+
+	fmt.Printf("Bisecting: %d revisions left to test after this (roughly %d steps)\n", step.Remaining-1, step.Steps)
+	fmt.Printf("Checked out %s\n", step.Current)
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/blame"
+	"github.com/spf13/cobra"
+)
+
+func NewBlameCommand() *cobra.Command {
+	var lineRange string
+	var porcelain bool
+	cmd := &cobra.Command{
+		Use:     "blame [<revision>] <file> [-L <start>,<end>] [--porcelain]",
+		Short:   "Show what commit last modified each line of a file",
+		Args:    cobra.RangeArgs(1, 2),
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rev, path := "", args[0]
+			if len(args) == 2 {
+				rev, path = args[0], args[1]
+			}
+
+			paths, err := repoPaths([]string{path})
+			if err != nil {
+				return err
+			}
+			path = paths[0]
+
+			start, end, err := parseLineRange(lineRange)
+			if err != nil {
+				return err
+			}
+
+			result, err := blame.Blame(repoPath, rev, path, start, end)
+			if err != nil {
+				return err
+			}
+
+			if len(result.Lines) == 0 {
+				return nil
+			}
+
+			stop, err := startOutput(true)
+			if err != nil {
+				return err
+			}
+			defer stop()
+
+			if porcelain {
+				printBlamePorcelain(result)
+				return nil
+			}
+
+			authorWidth := 0
+			for _, l := range result.Lines {
+				if len(l.Author) > authorWidth {
+					authorWidth = len(l.Author)
+				}
+			}
+
+			numWidth := len(strconv.Itoa(result.Lines[len(result.Lines)-1].FinalLine))
+			for _, l := range result.Lines {
+				fmt.Printf("%s (%-*s %s %*d) %s\n",
+					l.Hash.Short(7),
+					authorWidth, l.Author,
+					l.Date.Format("2006-01-02 15:04:05 -0700"),
+					numWidth, l.FinalLine,
+					l.Content)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&lineRange, "lines", "L", "", "Annotate only the given line range: <start>,<end> or <start>,+<count>")
+	cmd.Flags().BoolVar(&porcelain, "porcelain", false, "Show in a format designed for machine consumption")
+	return cmd
+}
+
+// parseLineRange reads "<start>,<end>", "<start>,+<count>", "<start>," or ",<end>".
+func parseLineRange(s string) (int, int, error) {
+	if len(s) == 0 {
+		return 0, 0, nil
+	}
+
+	startS, endS, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid line range %q, expected <start>,<end>", s)
+	}
+
+	start := 0
+	if len(startS) > 0 {
+		v, err := strconv.Atoi(startS)
+		if err != nil || v < 1 {
+			return 0, 0, fmt.Errorf("invalid line range start %q", startS)
+		}
+		start = v
+	}
+
+	end := 0
+	if len(endS) > 0 {
+		count := strings.HasPrefix(endS, "+")
+		v, err := strconv.Atoi(strings.TrimPrefix(endS, "+"))
+		if err != nil || v < 1 {
+			return 0, 0, fmt.Errorf("invalid line range end %q", endS)
+		}
+		end = v
+		if count {
+			end = max(start, 1) + v - 1
+		}
+	}
+
+	if end > 0 && start > end {
+		return 0, 0, fmt.Errorf("invalid line range %q, start is after end", s)
+	}
+
+	return start, end, nil
+}
+
+// printBlamePorcelain prints each line as "<hash> <orig line> <final line> [<lines in group>]",
+// the commit details the first time the commit shows up, and the line content after a tab.
+func printBlamePorcelain(result blame.BlameResult) {
+	seen := map[string]struct{}{}
+	for i, l := range result.Lines {
+		newGroup := i == 0 || result.Lines[i-1].Hash.NotEquals(l.Hash) ||
+			result.Lines[i-1].OrigLine+1 != l.OrigLine
+		if newGroup {
+			size := 1
+			for j := i + 1; j < len(result.Lines); j++ {
+				prev, cur := result.Lines[j-1], result.Lines[j]
+				if cur.Hash.NotEquals(l.Hash) || prev.OrigLine+1 != cur.OrigLine {
+					break
+				}
+				size++
+			}
+			fmt.Printf("%s %d %d %d\n", l.Hash, l.OrigLine, l.FinalLine, size)
+		} else {
+			fmt.Printf("%s %d %d\n", l.Hash, l.OrigLine, l.FinalLine)
+		}
+
+		if _, ok := seen[l.Hash.String()]; !ok {
+			seen[l.Hash.String()] = struct{}{}
+			fmt.Printf("author %s\n", l.Author)
+			fmt.Printf("author-mail <%s>\n", l.Email)
+			fmt.Printf("author-time %d\n", l.Date.Unix())
+			fmt.Printf("author-tz %s\n", l.Date.Format("-0700"))
+			fmt.Printf("summary %s\n", l.Summary)
+			fmt.Printf("filename %s\n", result.Path)
+		}
+
+		fmt.Printf("\t%s\n", l.Content)
+	}
+}
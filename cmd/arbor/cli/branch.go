@@ -1,9 +1,12 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/matiasmartin00/arbor/internal/branch"
+	"github.com/matiasmartin00/arbor/internal/color"
+	"github.com/matiasmartin00/arbor/internal/refs"
 	"github.com/spf13/cobra"
 )
 
@@ -40,10 +43,23 @@ func NewBranchCommand() *cobra.Command {
 				return err
 			}
 
+			stop, err := startOutput(false)
+			if err != nil {
+				return err
+			}
+			defer stop()
+
 			fmt.Println("Branches:")
+			if _, err := branch.GetCurrentBranch(repoPath); errors.Is(err, branch.ErrDetachedHead) {
+				head, err := refs.GetRefHash(repoPath)
+				if err != nil {
+					return err
+				}
+				fmt.Printf(" * %s\n", paint(color.Green, fmt.Sprintf("(HEAD detached at %s)", head.Short(7))))
+			}
 			for _, b := range branches {
 				if b.IsActive {
-					fmt.Printf(" * %s\n", b.Name)
+					fmt.Printf(" * %s\n", paint(color.Green, b.Name))
 					continue
 				}
 				fmt.Printf("   %s\n", b.Name)
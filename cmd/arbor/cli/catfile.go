@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/revision"
+	"github.com/spf13/cobra"
+)
+
+func NewCatFileCommand() *cobra.Command {
+	var showType, showSize, pretty bool
+	cmd := &cobra.Command{
+		Use:   "cat-file (-t | -s | -p) <object>",
+		Short: "Show the type, size or content of an object",
+		Long: `The object is a hash, a revision, <rev>:<path> or <rev>^{tree}.
+  -t  prints the type: blob, tree or commit
+  -s  prints the size of the content in bytes
+  -p  prints the content, trees in the ls-tree format`,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			modes := 0
+			for _, m := range []bool{showType, showSize, pretty} {
+				if m {
+					modes++
+				}
+			}
+			if modes != 1 {
+				return fmt.Errorf("exactly one of -t, -s or -p is required")
+			}
+
+			hash, err := revision.ResolveObject(repoPath, args[0])
+			if err != nil {
+				return err
+			}
+
+			objType, data, err := object.ReadObject(repoPath, hash)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case showType:
+				fmt.Println(objType)
+			case showSize:
+				fmt.Println(len(data))
+			case objType == object.TreeType:
+				entries, err := object.ReadTreeEntries(repoPath, hash)
+				if err != nil {
+					return err
+				}
+				for _, e := range entries {
+					printTreeEntry(e, e.Name)
+				}
+			default:
+				if _, err := os.Stdout.Write(data); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&showType, "type", "t", false, "Show the object type")
+	cmd.Flags().BoolVarP(&showSize, "size", "s", false, "Show the object size")
+	cmd.Flags().BoolVarP(&pretty, "print", "p", false, "Show the object content")
+	return cmd
+}
@@ -2,27 +2,74 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/matiasmartin00/arbor/internal/checkout"
+	"github.com/matiasmartin00/arbor/internal/object"
 	"github.com/spf13/cobra"
 )
 
 func NewCheckoutCommand() *cobra.Command {
+	var opts checkout.CheckoutOptions
 	cmd := &cobra.Command{
-		Use:     "checkout <commit-hash|branch-name>",
-		Short:   "Checkout a commit or branch",
+		Use:   "checkout [--force | --merge] <branch-name|revision>",
+		Short: "Checkout a commit or branch",
+		Long: `Switches the working directory and the index to a branch or a commit. Any
+revision other than a branch name or HEAD detaches HEAD at that commit. Local
+changes, staged or not, to files that are the same in both commits are kept.
+Checkout is refused when it would overwrite a changed or untracked file, unless
+--force discards the changes or --merge merges them into the new version.`,
 		Args:    cobra.ExactArgs(1),
 		PreRunE: preRunErr,
 		RunE: func(cmd *cobra.Command, args []string) error {
 
-			if err := checkout.Checkout(repoPath, args[0]); err != nil {
+			result, err := checkout.Checkout(repoPath, args[0], opts)
+			if err != nil {
 				return err
 			}
 
 			fmt.Printf("Checked out to %s\n", args[0])
-			return nil
+			return printCheckoutResult(result)
 		},
 	}
 
+	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Discard the local changes")
+	cmd.Flags().BoolVarP(&opts.Merge, "merge", "m", false, "Merge the local changes into the files that change")
+	cmd.MarkFlagsMutuallyExclusive("force", "merge")
+
 	return cmd
 }
+
+// printCheckoutResult reports the conflicts, the detached HEAD and the commits
+// left behind by checkout and switch.
+func printCheckoutResult(result checkout.CheckoutResult) error {
+	if len(result.Conflicts) > 0 {
+		fmt.Println("Local changes merged with conflicts:")
+		for _, c := range result.Conflicts {
+			fmt.Printf(" -%s\n", c)
+		}
+	}
+
+	if len(result.Left) > 0 {
+		commits := "commits"
+		if len(result.Left) == 1 {
+			commits = "commit"
+		}
+		fmt.Printf("\nWarning: you are leaving %d %s behind, not connected to any branch:\n", len(result.Left), commits)
+		for _, h := range result.Left {
+			c, err := object.ReadCommit(repoPath, h)
+			if err != nil {
+				return err
+			}
+			subject, _, _ := strings.Cut(c.Message(), "\n")
+			fmt.Printf("  %s %s\n", h.Short(7), subject)
+		}
+		fmt.Printf("\nTo keep them, create a branch: arbor switch -c <new-branch> %s\n", result.Left[0].Short(7))
+	}
+
+	if result.Detached != nil {
+		fmt.Printf("\nHEAD is now detached at %s. New commits belong to no branch,\n", result.Detached.Short(7))
+		fmt.Println("run `arbor switch -c <new-branch>` to keep them on a new branch.")
+	}
+	return nil
+}
@@ -2,23 +2,41 @@ package cli
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 
 	"github.com/matiasmartin00/arbor/internal/commit"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
 	"github.com/spf13/cobra"
 )
 
 func NewCommitCommand() *cobra.Command {
-	var message string
+	var message, file, author, date string
+	var amend, allowEmpty, noVerify bool
 	cmd := &cobra.Command{
-		Use:     "commit -m <message>",
+		Use:     "commit [-m <message> | -F <file>]",
 		Short:   "Create a new commit",
 		PreRunE: preRunErr,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(message) == 0 {
-				return fmt.Errorf("commit message required: arbor commit -m <message>")
+			if cmd.Flags().Changed("message") && len(file) > 0 {
+				return fmt.Errorf("-m and -F can not be used together")
 			}
 
-			commitHash, err := commit.Commit(repoPath, message)
+			msg, err := commitMessage(cmd, message, file, amend)
+			if err != nil {
+				return err
+			}
+
+			commitHash, err := commit.Commit(repoPath, commit.CommitOptions{
+				Message:    msg,
+				Author:     author,
+				Date:       date,
+				Amend:      amend,
+				AllowEmpty: allowEmpty,
+				NoVerify:   noVerify,
+			})
 			if err != nil {
 				return err
 			}
@@ -29,6 +47,55 @@ func NewCommitCommand() *cobra.Command {
 	}
 
 	cmd.Flags().StringVarP(&message, "message", "m", "", "Commit message")
+	cmd.Flags().StringVarP(&file, "file", "F", "", "Read the commit message from a file, - for stdin")
+	cmd.Flags().StringVar(&author, "author", "", "Override the commit author, as \"Name <email>\"")
+	cmd.Flags().StringVar(&date, "date", "", "Override the author date (e.g. \"2024-01-31 10:00:00 +0100\", \"2 days ago\")")
+	cmd.Flags().BoolVar(&amend, "amend", false, "Replace the tip commit of the current branch")
+	cmd.Flags().BoolVar(&allowEmpty, "allow-empty", false, "Allow a commit with the same tree as its parent")
+	cmd.Flags().BoolVarP(&noVerify, "no-verify", "n", false, "Skip the pre-commit and commit-msg hooks")
 
 	return cmd
 }
+
+// commitMessage returns the message from -m or -F, or asks for it in the editor.
+// When amending, the editor starts with the message of the amended commit.
+func commitMessage(cmd *cobra.Command, message, file string, amend bool) (string, error) {
+	if cmd.Flags().Changed("message") {
+		return message, nil
+	}
+
+	if len(file) > 0 {
+		var data []byte
+		var err error
+		if file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			// relative to the directory arbor was started in, not the root
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(prefix, file)
+			}
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return "", fmt.Errorf("can not read the commit message: %w", err)
+		}
+		return commit.CleanupMessage(string(data), false), nil
+	}
+
+	initial := ""
+	if amend {
+		headHash, err := refs.GetRefHash(repoPath)
+		if err != nil {
+			return "", err
+		}
+		if headHash != nil {
+			c, err := object.ReadCommit(repoPath, headHash)
+			if err != nil {
+				return "", err
+			}
+			initial = c.Message()
+		}
+	}
+
+	return commit.EditMessage(repoPath, initial)
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommitMessageFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll("sub", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range map[string]string{"msg": "root\n", "sub/msg": "sub  \n\n\n"} {
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	abs, err := filepath.Abs("msg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		file   string
+		want   string
+	}{
+		{"at the root", "", "msg", "root"},
+		{"relative to the start directory", "sub", "msg", "sub"},
+		{"absolute", "sub", abs, "root"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { prefix = "" })
+			prefix = tt.prefix
+
+			got, err := commitMessage(NewCommitCommand(), "", tt.file, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := commitMessage(NewCommitCommand(), "", "missing", false); err == nil {
+		t.Error("read a missing message file")
+	}
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/matiasmartin00/arbor/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func NewConfigCommand() *cobra.Command {
+	var global, showOrigin bool
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Get and set repository or global options",
+		Long: `Options are read from, in increasing precedence:
+  ~/.arborconfig (or the file in $ARBOR_CONFIG_GLOBAL)   --global
+  .arbor/config                                           default
+  ARBOR_CONFIG_<SECTION>__<NAME> environment variables    e.g. ARBOR_CONFIG_USER__EMAIL
+
+In variable names "__" separates the section, the optional subsection and the
+name, and "_" stands for "-": branch.main.remote is ARBOR_CONFIG_BRANCH__MAIN__REMOTE.`,
+	}
+
+	// the global config can be used outside of a repository
+	preRunScope := func(cmd *cobra.Command, args []string) error {
+		if global {
+			return nil
+		}
+		return preRunErr(cmd, args)
+	}
+
+	scope := func() config.Scope {
+		if global {
+			return config.Global
+		}
+		return config.Local
+	}
+
+	getCmd := &cobra.Command{
+		Use:     "get <key>",
+		Short:   "Print the value of an option",
+		Args:    cobra.ExactArgs(1),
+		PreRunE: preRunOptional,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v, ok, err := config.Get(repoPath, args[0])
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				return fmt.Errorf("key %s is not set", args[0])
+			}
+
+			fmt.Println(v)
+			return nil
+		},
+	}
+
+	setCmd := &cobra.Command{
+		Use:     "set [--global] <key> <value>",
+		Short:   "Set an option",
+		Args:    cobra.ExactArgs(2),
+		PreRunE: preRunScope,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return config.Set(repoPath, scope(), args[0], args[1])
+		},
+	}
+	setCmd.Flags().BoolVar(&global, "global", false, "Write to the per-user config file")
+
+	unsetCmd := &cobra.Command{
+		Use:     "unset [--global] <key>",
+		Short:   "Remove an option",
+		Args:    cobra.ExactArgs(1),
+		PreRunE: preRunScope,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return config.Unset(repoPath, scope(), args[0])
+		},
+	}
+	unsetCmd.Flags().BoolVar(&global, "global", false, "Remove from the per-user config file")
+
+	listCmd := &cobra.Command{
+		Use:     "list [--show-origin]",
+		Short:   "List every option, lowest precedence first",
+		Args:    cobra.NoArgs,
+		PreRunE: preRunOptional,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := config.List(repoPath)
+			if err != nil {
+				return err
+			}
+
+			for _, e := range entries {
+				if showOrigin {
+					fmt.Printf("%s\t%s=%s\n", e.Scope, e.Key, e.Value)
+					continue
+				}
+				fmt.Printf("%s=%s\n", e.Key, e.Value)
+			}
+			return nil
+		},
+	}
+	listCmd.Flags().BoolVar(&showOrigin, "show-origin", false, "Show where each option comes from")
+
+	cmd.AddCommand(getCmd, setCmd, unsetCmd, listCmd)
+	return cmd
+}
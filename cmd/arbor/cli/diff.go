@@ -2,14 +2,253 @@ package cli
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
+	"github.com/matiasmartin00/arbor/internal/color"
 	"github.com/matiasmartin00/arbor/internal/diff"
 	"github.com/spf13/cobra"
 )
 
+// diffOutput selects how diffs are computed and printed: whole files by default, or one of the summaries
+type diffOutput struct {
+	stat       bool
+	numstat    bool
+	shortstat  bool
+	nameOnly   bool
+	nameStatus bool
+
+	findRenames string
+	noRenames   bool
+	findCopies  bool
+
+	ignoreAllSpace    bool
+	ignoreSpaceChange bool
+	ignoreBlankLines  bool
+	ignoreCRAtEOL     bool
+
+	// wordDiff is the word diff mode: plain, color or porcelain, empty for line diffs
+	wordDiff  string
+	wordRegex string
+	wordRE    *regexp.Regexp
+}
+
+// word diff modes
+const (
+	wordDiffPlain     = "plain"
+	wordDiffColor     = "color"
+	wordDiffPorcelain = "porcelain"
+)
+
+func (o *diffOutput) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.findRenames, "find-renames", "M", "", "Detect renames with at least this similarity (default 50%)")
+	cmd.Flags().Lookup("find-renames").NoOptDefVal = fmt.Sprintf("%d%%", diff.DefaultRenameThreshold)
+	cmd.Flags().BoolVar(&o.noRenames, "no-renames", false, "Show renames as a deletion and an addition")
+	cmd.Flags().BoolVar(&o.findCopies, "find-copies", false, "Also detect files copied from files that still exist")
+	cmd.Flags().BoolVarP(&o.ignoreAllSpace, "ignore-all-space", "w", false, "Ignore whitespace when comparing lines")
+	cmd.Flags().BoolVarP(&o.ignoreSpaceChange, "ignore-space-change", "b", false, "Ignore changes in the amount of whitespace")
+	cmd.Flags().BoolVar(&o.ignoreBlankLines, "ignore-blank-lines", false, "Ignore blank lines that are added or removed")
+	cmd.Flags().BoolVar(&o.ignoreCRAtEOL, "ignore-cr-at-eol", false, "Ignore a carriage return at the end of lines")
+	cmd.Flags().StringVar(&o.wordDiff, "word-diff", "", "Show changed words instead of lines: plain, color or porcelain")
+	cmd.Flags().Lookup("word-diff").NoOptDefVal = wordDiffPlain
+	cmd.Flags().StringVar(&o.wordRegex, "word-diff-regex", "", "Regular expression of a word, implies --word-diff (default diff.wordRegex or \\S+)")
+	cmd.Flags().BoolVar(&o.stat, "stat", false, "Show a histogram of changed lines per file")
+	cmd.Flags().BoolVar(&o.numstat, "numstat", false, "Show added and removed lines per file, tab separated")
+	cmd.Flags().BoolVar(&o.shortstat, "shortstat", false, "Show only the total of files and lines changed")
+	cmd.Flags().BoolVar(&o.nameOnly, "name-only", false, "Show only the names of changed files")
+	cmd.Flags().BoolVar(&o.nameStatus, "name-status", false, "Show the names and status (A/M/D/R/C) of changed files")
+}
+
+// options returns the diff options for paths, renames are detected as set in
+// diff.renames unless the flags say otherwise. It also prepares the word diff.
+func (o *diffOutput) options(paths []string) (diff.Options, error) {
+	if err := o.prepareWordDiff(); err != nil {
+		return diff.Options{}, err
+	}
+
+	renames, copies, err := diff.RenamesFromConfig(repoPath)
+	if err != nil {
+		return diff.Options{}, err
+	}
+
+	opts := diff.Options{
+		Paths:             paths,
+		Renames:           renames,
+		Copies:            copies,
+		IgnoreAllSpace:    o.ignoreAllSpace,
+		IgnoreSpaceChange: o.ignoreSpaceChange,
+		IgnoreBlankLines:  o.ignoreBlankLines,
+		IgnoreCRAtEOL:     o.ignoreCRAtEOL,
+	}
+	if len(o.findRenames) > 0 {
+		threshold, err := diff.ParseThreshold(o.findRenames)
+		if err != nil {
+			return diff.Options{}, err
+		}
+		opts.Renames = true
+		opts.RenameThreshold = threshold
+	}
+
+	if o.findCopies {
+		opts.Renames, opts.Copies = true, true
+	}
+
+	if o.noRenames {
+		opts.Renames, opts.Copies = false, false
+	}
+
+	return opts, nil
+}
+
+func (o *diffOutput) prepareWordDiff() error {
+	if len(o.wordRegex) > 0 && len(o.wordDiff) == 0 {
+		o.wordDiff = wordDiffPlain
+	}
+	if len(o.wordDiff) == 0 {
+		return nil
+	}
+
+	switch o.wordDiff {
+	case wordDiffPlain, wordDiffColor, wordDiffPorcelain:
+	default:
+		return fmt.Errorf("invalid --word-diff mode %q, expected plain, color or porcelain", o.wordDiff)
+	}
+
+	re, err := diff.WordRegex(repoPath, o.wordRegex)
+	if err != nil {
+		return err
+	}
+	o.wordRE = re
+	return nil
+}
+
+func (o diffOutput) summary() bool {
+	return o.stat || o.numstat || o.shortstat || o.nameOnly || o.nameStatus
+}
+
+// lines renders the results as selected, label names the compared sides in full diffs.
+func (o diffOutput) lines(label string, results []diff.DiffResult) []string {
+	if !o.summary() {
+		return o.resultLines(label, results)
+	}
+
+	stats := diff.Stats(results)
+	out := []string{}
+	switch {
+	case o.nameOnly:
+		for _, s := range stats {
+			out = append(out, s.File)
+		}
+	case o.nameStatus:
+		for _, s := range stats {
+			if len(s.OldFile) > 0 {
+				out = append(out, fmt.Sprintf("%s%03d\t%s\t%s", s.Status, s.Similarity, s.OldFile, s.File))
+				continue
+			}
+			out = append(out, fmt.Sprintf("%s\t%s", s.Status, s.File))
+		}
+	case o.numstat:
+		for _, s := range stats {
+			if s.Binary {
+				out = append(out, fmt.Sprintf("-\t-\t%s", statName(s)))
+				continue
+			}
+			out = append(out, fmt.Sprintf("%d\t%d\t%s", s.Added, s.Removed, statName(s)))
+		}
+	case o.stat:
+		out = append(out, statLines(stats)...)
+	case o.shortstat:
+		if len(stats) > 0 {
+			out = append(out, shortstat(stats))
+		}
+	}
+	return out
+}
+
+func (o diffOutput) print(label string, results []diff.DiffResult) {
+	for _, l := range o.lines(label, results) {
+		fmt.Println(l)
+	}
+}
+
+// statWidth is the widest histogram bar
+const statWidth = 50
+
+func statLines(stats []diff.FileStat) []string {
+	if len(stats) == 0 {
+		return nil
+	}
+
+	nameWidth, maxChanges := 0, 0
+	for _, s := range stats {
+		nameWidth = max(nameWidth, len(statName(s)))
+		maxChanges = max(maxChanges, s.Added+s.Removed)
+	}
+	countWidth := len(fmt.Sprint(maxChanges))
+
+	out := make([]string, 0, len(stats)+1)
+	for _, s := range stats {
+		if s.Binary {
+			out = append(out, fmt.Sprintf(" %-*s | Bin", nameWidth, statName(s)))
+			continue
+		}
+
+		added, removed := s.Added, s.Removed
+		// scale down keeping at least one mark for any change
+		if maxChanges > statWidth {
+			added = scaleStat(added, maxChanges)
+			removed = scaleStat(removed, maxChanges)
+		}
+		bar := paint(color.Green, strings.Repeat("+", added)) + paint(color.Red, strings.Repeat("-", removed))
+		out = append(out, strings.TrimRight(fmt.Sprintf(" %-*s | %*d %s", nameWidth, statName(s), countWidth, s.Added+s.Removed, bar), " "))
+	}
+
+	return append(out, shortstat(stats))
+}
+
+// statName is the file name in stats, "old => new" for renames and copies
+func statName(s diff.FileStat) string {
+	if len(s.OldFile) > 0 {
+		return fmt.Sprintf("%s => %s", s.OldFile, s.File)
+	}
+	return s.File
+}
+
+func scaleStat(n, maxChanges int) int {
+	if n == 0 {
+		return 0
+	}
+	return max(1, n*statWidth/maxChanges)
+}
+
+func shortstat(stats []diff.FileStat) string {
+	added, removed := 0, 0
+	for _, s := range stats {
+		added += s.Added
+		removed += s.Removed
+	}
+
+	line := fmt.Sprintf(" %d %s changed", len(stats), plural(len(stats), "file", "files"))
+	if added > 0 || removed == 0 {
+		line += fmt.Sprintf(", %d %s(+)", added, plural(added, "insertion", "insertions"))
+	}
+	if removed > 0 || added == 0 {
+		line += fmt.Sprintf(", %d %s(-)", removed, plural(removed, "deletion", "deletions"))
+	}
+	return line
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
 func NewDiffCommand() *cobra.Command {
 	var staged bool
 	var paths []string
+	var output diffOutput
 	cmd := &cobra.Command{
 		Use:   "diff [<commitA> <commitB>] [--paths paths...]",
 		Short: "Show changes between commits, index and working tree",
@@ -18,38 +257,56 @@ func NewDiffCommand() *cobra.Command {
 						- arbor diff --staged   : index vs HEAD (staged)
 						- arbor diff <A> <B>    : diff between two commits
 						You can pass paths with flag --paths to limit to specific files.
+						--stat, --numstat, --shortstat, --name-only and --name-status summarize any mode.
+						Renames are detected between commits and in staged changes, -M<n>% sets the similarity.
+						--word-diff shows changed words, -w, -b and --ignore-blank-lines ignore whitespace.
 					`,
 		Args:    cobra.ArbitraryArgs,
 		PreRunE: preRunErr,
 		RunE: func(c *cobra.Command, args []string) error {
+			paths, err := repoPaths(paths)
+			if err != nil {
+				return err
+			}
+
+			opts, err := output.options(paths)
+			if err != nil {
+				return err
+			}
+
+			stop, err := startOutput(true)
+			if err != nil {
+				return err
+			}
+			defer stop()
 
 			// if commits present -> diff commits
 			if len(args) >= 2 {
-				diffResult, err := diff.DiffCommits(repoPath, args[0], args[1], paths)
+				diffResult, err := diff.DiffCommits(repoPath, args[0], args[1], opts)
 				if err != nil {
 					return err
 				}
-				printResult(fmt.Sprintf("commit %s -> %s", args[0], args[1]), diffResult)
+				output.print(fmt.Sprintf("commit %s -> %s", args[0], args[1]), diffResult)
 				return nil
 			}
 
 			// staged mode
 			if staged {
-				diffResults, err := diff.DiffIndexVsHead(repoPath, paths)
+				diffResults, err := diff.DiffIndexVsHead(repoPath, opts)
 				if err != nil {
 					return err
 				}
-				printResult("index vs HEAD", diffResults)
+				output.print("index vs HEAD", diffResults)
 				return nil
 			}
 
 			// default: workdir vs index
-			diffResult, err := diff.DiffWorktreeVsIndex(repoPath, paths)
+			diffResult, err := diff.DiffWorktreeVsIndex(repoPath, opts)
 			if err != nil {
 				return err
 			}
 
-			printResult("workdir vs index", diffResult)
+			output.print("workdir vs index", diffResult)
 
 			return nil
 		},
@@ -57,29 +314,152 @@ func NewDiffCommand() *cobra.Command {
 
 	cmd.Flags().BoolVarP(&staged, "staged", "s", false, "Show diff between index and HEAD (staged changes)")
 	cmd.Flags().StringSliceVarP(&paths, "paths", "p", []string{}, "You can pass paths to limit to specific files")
+	output.addFlags(cmd)
 	return cmd
 }
 
-func printResult(difference string, diffResult []diff.DiffResult) {
+func (o diffOutput) resultLines(difference string, diffResult []diff.DiffResult) []string {
+	out := []string{}
 	for _, dr := range diffResult {
-		fmt.Printf("diff -- a/%s b/%s (%s)\n", dr.File, dr.File, difference)
+		oldFile := dr.File
+		if len(dr.OldFile) > 0 {
+			oldFile = dr.OldFile
+		}
+		out = append(out, paint(color.Bold, fmt.Sprintf("diff -- a/%s b/%s (%s)", oldFile, dr.File, difference)))
+
+		switch {
+		case dr.Copied:
+			out = append(out, paint(color.Bold, fmt.Sprintf("copied: %s -> %s (%d%% similar)", dr.OldFile, dr.File, dr.Similarity)))
+		case len(dr.OldFile) > 0:
+			out = append(out, paint(color.Bold, fmt.Sprintf("renamed: %s -> %s (%d%% similar)", dr.OldFile, dr.File, dr.Similarity)))
+		}
+
+		if dr.AMode != 0 && dr.BMode != 0 && dr.AMode != dr.BMode {
+			out = append(out, paint(color.Bold, fmt.Sprintf("old mode %s", dr.AMode)), paint(color.Bold, fmt.Sprintf("new mode %s", dr.BMode)))
+		}
+
 		if dr.AHash != nil && dr.BHash != nil {
-			fmt.Printf("index -- %s vs %s\n", dr.AHash, dr.BHash)
+			out = append(out, paint(color.Bold, fmt.Sprintf("index -- %s vs %s", dr.AHash, dr.BHash)))
 		} else if dr.AHash != nil {
-			fmt.Printf("index -- %s\n", dr.AHash)
+			out = append(out, paint(color.Bold, fmt.Sprintf("index -- %s", dr.AHash)))
 		} else {
-			fmt.Printf("index -- %s\n", dr.BHash)
+			out = append(out, paint(color.Bold, fmt.Sprintf("index -- %s", dr.BHash)))
 		}
 
 		if dr.Lines == nil {
-			fmt.Printf("Binary file\n")
-			fmt.Printf("\n\n")
+			out = append(out, "Binary file", "", "")
+			continue
+		}
+
+		if o.wordRE != nil {
+			out = append(out, o.wordDiffLines(dr.Lines)...)
+			out = append(out, "", "")
 			continue
 		}
 
 		for _, ld := range dr.Lines {
-			fmt.Printf("%s%s\n", ld.Result, ld.ResultLine)
+			out = append(out, paint(lineColor(ld.Result), fmt.Sprintf("%s%s", ld.Result, ld.ResultLine)))
+			if ld.NoEOL {
+				out = append(out, diff.NoEOLLine)
+			}
+		}
+		out = append(out, "", "")
+	}
+	return out
+}
+
+// wordDiffLines renders the lines of a file diff with each run of changed lines
+// compared word by word.
+func (o diffOutput) wordDiffLines(lines []diff.LineData) []string {
+	out := []string{}
+	removed, added := []string{}, []string{}
+	flush := func() {
+		if len(removed)+len(added) > 0 {
+			out = append(out, o.wordSegmentLines(diff.WordDiff(removed, added, o.wordRE))...)
+		}
+		removed, added = removed[:0], added[:0]
+	}
+
+	for _, ld := range lines {
+		switch ld.Result {
+		case diff.RemovedLine:
+			removed = append(removed, ld.ResultLine)
+		case diff.AddedLine:
+			added = append(added, ld.ResultLine)
+		default:
+			flush()
+			if o.wordDiff == wordDiffPorcelain {
+				out = append(out, " "+ld.ResultLine, "~")
+				continue
+			}
+			out = append(out, ld.ResultLine)
 		}
-		fmt.Printf("\n\n")
 	}
+	flush()
+
+	return out
+}
+
+// wordSegmentLines lays out the segments of a word diff: inline with [-removed-]
+// and {+added+} marks, in red and green, or one segment per line for porcelain,
+// where "~" ends a line.
+func (o diffOutput) wordSegmentLines(segments []diff.WordSegment) []string {
+	out := []string{}
+	var line strings.Builder
+	for _, seg := range segments {
+		for i, piece := range strings.Split(seg.Text, "\n") {
+			if i > 0 {
+				if o.wordDiff == wordDiffPorcelain {
+					out = append(out, "~")
+					continue
+				}
+				out = append(out, line.String())
+				line.Reset()
+			}
+			if len(piece) == 0 {
+				continue
+			}
+
+			switch o.wordDiff {
+			case wordDiffPorcelain:
+				out = append(out, seg.Result.String()+piece)
+			case wordDiffColor:
+				line.WriteString(wordColor(seg.Result, piece))
+			default:
+				line.WriteString(wordMarks(seg.Result, piece))
+			}
+		}
+	}
+
+	if o.wordDiff == wordDiffPorcelain {
+		return append(out, "~")
+	}
+	return append(out, line.String())
+}
+
+// lineColor is the color of added and removed lines, empty for the others.
+func lineColor(r diff.LineResult) string {
+	switch r {
+	case diff.RemovedLine:
+		return color.Red
+	case diff.AddedLine:
+		return color.Green
+	}
+	return ""
+}
+
+func wordMarks(r diff.LineResult, text string) string {
+	switch r {
+	case diff.RemovedLine:
+		return "[-" + text + "-]"
+	case diff.AddedLine:
+		return "{+" + text + "+}"
+	}
+	return text
+}
+
+// wordColor colors changed words, --word-diff=color is colored even when the
+// rest of the output is not.
+func wordColor(r diff.LineResult, text string) string {
+	return color.Paint(r != diff.EqLine, lineColor(r), text)
 }
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/matiasmartin00/arbor/internal/fsck"
+	"github.com/spf13/cobra"
+)
+
+func NewFsckCommand() *cobra.Command {
+	var noDangling bool
+	cmd := &cobra.Command{
+		Use:   "fsck [--no-dangling]",
+		Short: "Verify the objects and the connectivity of the repository",
+		Long: `Checks that every object hashes to its name and parses as its type, and
+that everything reachable from the branches, HEAD, MERGE_HEAD and the index
+is in the store. Exits with a non-zero status when the repository is corrupt.`,
+		Args:    cobra.NoArgs,
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := fsck.Fsck(repoPath)
+			if err != nil {
+				return err
+			}
+
+			for _, f := range report.Invalid {
+				fmt.Printf("error: invalid file in object store: %s\n", f)
+			}
+
+			for _, c := range report.Corrupt {
+				fmt.Printf("error: corrupt object %s: %v\n", c.Hash, c.Err)
+			}
+
+			for _, m := range report.Missing {
+				fmt.Printf("missing %s %s (referenced by %s)\n", m.Type, m.Hash, m.From)
+			}
+
+			if !noDangling {
+				for _, d := range report.Dangling {
+					fmt.Printf("dangling %s %s\n", d.Type, d.Hash)
+				}
+			}
+
+			if !report.OK() {
+				// the report is the output, usage would only hide it
+				cmd.SilenceUsage = true
+				return fmt.Errorf("repository is corrupt: %d invalid files, %d corrupt and %d missing objects",
+					len(report.Invalid), len(report.Corrupt), len(report.Missing))
+			}
+
+			fmt.Printf("Checked %d objects, no corruption found.\n", report.Checked)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&noDangling, "no-dangling", false, "Do not list dangling objects")
+	return cmd
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/date"
+	"github.com/matiasmartin00/arbor/internal/gc"
+	"github.com/spf13/cobra"
+)
+
+// defaultPruneExpire is the grace period for unreachable objects, like git
+const defaultPruneExpire = "2 weeks ago"
+
+func NewGCCommand() *cobra.Command {
+	var expire string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "gc [--prune=<age>] [--dry-run]",
+		Short: "Clean up unnecessary objects",
+		Long: `Deletes the objects that can not be reached from the branches, HEAD,
+MERGE_HEAD or the index and are older than --prune (two weeks by default).
+--prune=now deletes every unreachable object, --prune=never keeps them all.
+Temporary files left in .arbor by interrupted writes are deleted after an hour.`,
+		Args:    cobra.NoArgs,
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPrune(expire, dryRun)
+		},
+	}
+
+	cmd.Flags().StringVar(&expire, "prune", defaultPruneExpire, "Prune unreachable objects older than this date (e.g. \"now\", \"1 week ago\", \"never\")")
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Report what would be pruned without deleting")
+	return cmd
+}
+
+func NewPruneCommand() *cobra.Command {
+	var expire string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:     "prune [--expire=<age>] [--dry-run]",
+		Short:   "Delete unreachable objects",
+		Long:    `Deletes the objects that can not be reached from the branches, HEAD, MERGE_HEAD or the index.`,
+		Args:    cobra.NoArgs,
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPrune(expire, dryRun)
+		},
+	}
+
+	cmd.Flags().StringVar(&expire, "expire", "now", "Only prune unreachable objects older than this date")
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Report what would be pruned without deleting")
+	return cmd
+}
+
+func runPrune(expire string, dryRun bool) error {
+	if expire == "never" {
+		fmt.Println("Nothing pruned (--prune=never).")
+		return nil
+	}
+
+	when, err := date.Parse(expire, time.Now())
+	if err != nil {
+		return err
+	}
+
+	result, err := gc.Prune(repoPath, gc.PruneOptions{Expire: when, DryRun: dryRun})
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		for _, p := range result.Pruned {
+			fmt.Printf("would prune %s (%d bytes)\n", p.Hash, p.Size)
+		}
+		fmt.Printf("Would prune %d objects, %s would be reclaimed.\n", len(result.Pruned), formatBytes(result.Bytes))
+	} else {
+		fmt.Printf("Pruned %d objects, %s reclaimed.\n", len(result.Pruned), formatBytes(result.Bytes))
+	}
+	if result.Recent > 0 {
+		fmt.Printf("Kept %d unreachable objects newer than %s.\n", result.Recent, expire)
+	}
+	if len(result.Temp) > 0 {
+		verb := "Removed"
+		if dryRun {
+			verb = "Would remove"
+		}
+		fmt.Printf("%s %d temporary files left by interrupted writes.\n", verb, len(result.Temp))
+	}
+	return nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d bytes", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
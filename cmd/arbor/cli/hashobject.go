@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/repo"
+	"github.com/spf13/cobra"
+)
+
+func NewHashObjectCommand() *cobra.Command {
+	var write, stdin bool
+	cmd := &cobra.Command{
+		Use:   "hash-object [-w] (--stdin | <file>...)",
+		Short: "Compute the blob hash of files",
+		Long: `Prints the hash each file would have as a blob, one per line.
+With -w the blobs are also written to the object store.`,
+		Args:    cobra.ArbitraryArgs,
+		PreRunE: preRunOptional,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if stdin == (len(args) > 0) {
+				return fmt.Errorf("give either --stdin or files")
+			}
+
+			if write {
+				if err := repo.EnsureRepo(repoPath); err != nil {
+					return err
+				}
+			}
+
+			if stdin {
+				data, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return err
+				}
+				return printBlobHash(data, write)
+			}
+
+			for _, f := range args {
+				// relative to the directory arbor was started in
+				if !filepath.IsAbs(f) {
+					f = filepath.Join(prefix, f)
+				}
+
+				data, err := os.ReadFile(f)
+				if err != nil {
+					return err
+				}
+
+				if err := printBlobHash(data, write); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&write, "write", "w", false, "Write the blob to the object store")
+	cmd.Flags().BoolVar(&stdin, "stdin", false, "Read the content from stdin")
+	return cmd
+}
+
+func printBlobHash(data []byte, write bool) error {
+	hash, err := object.NewHashBlob(data)
+	if write {
+		hash, err = object.WriteBlobData(repoPath, data)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(hash)
+	return nil
+}
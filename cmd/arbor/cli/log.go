@@ -1,50 +1,208 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/matiasmartin00/arbor/internal/color"
+	"github.com/matiasmartin00/arbor/internal/date"
 	"github.com/matiasmartin00/arbor/internal/log"
+	"github.com/matiasmartin00/arbor/internal/object"
 	"github.com/spf13/cobra"
 )
 
 func NewLogCommand() *cobra.Command {
 	var from string
 	var limit int
+	var all, graph, topoOrder, dateOrder bool
+	var author, grep, since, until string
+	var format string
+	var oneline, patch bool
+	var output diffOutput
 	cmd := &cobra.Command{
-		Use:     "log [--from <commitHash>] [--limit <number>]",
+		Use:     "log [<revision>...] [--all] [--graph] [--topo-order|--date-order] [--limit <number>] [-p | --stat] [-- <paths>...]",
 		Short:   "Show commit logs",
-		Args:    cobra.NoArgs,
+		Args:    cobra.ArbitraryArgs,
 		PreRunE: preRunErr,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			logResult, err := log.Log(repoPath, from, limit)
+			if topoOrder && dateOrder {
+				return fmt.Errorf("--topo-order and --date-order cannot be used together")
+			}
+
+			// everything after "--" are paths
+			revisions, paths := args, []string{}
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+				revisions, paths = args[:dash], args[dash:]
+			}
+
+			paths, err := repoPaths(paths)
 			if err != nil {
 				return err
 			}
 
-			for _, l := range logResult.Logs {
-				fmt.Printf("commit %s\n", l.Hash)
-				if len(l.Author) > 0 {
-					fmt.Printf("Author: %s <%s>\n", l.Author, l.Email)
+			opts := log.LogOptions{
+				Revisions: revisions,
+				All:       all,
+				Limit:     limit,
+				Graph:     graph,
+				Author:    author,
+				Grep:      grep,
+				Paths:     paths,
+			}
+
+			now := time.Now()
+			if len(since) > 0 {
+				t, err := date.Parse(since, now)
+				if err != nil {
+					return err
+				}
+				opts.Since = t
+			}
+			if len(until) > 0 {
+				t, err := date.Parse(until, now)
+				if err != nil {
+					return err
+				}
+				opts.Until = t
+			}
+
+			if len(from) > 0 {
+				opts.Revisions = append(opts.Revisions, from)
+			}
+
+			if topoOrder {
+				opts.Order = log.TopoOrder
+			}
+			if dateOrder {
+				opts.Order = log.DateOrder
+			}
+
+			logResult, err := log.Log(repoPath, opts)
+			if err != nil {
+				return err
+			}
+
+			stop, err := startOutput(true)
+			if err != nil {
+				return err
+			}
+			defer stop()
+
+			if format == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				for _, l := range logResult.Logs {
+					if err := enc.Encode(l); err != nil {
+						return err
+					}
 				}
+				return nil
+			}
+
+			if oneline {
+				format = "%h%d %s"
+			}
 
-				fmt.Printf("Date:   %s\n\n", l.Date.Format(time.RFC1123))
+			for _, l := range logResult.Logs {
+				lines := logLines(l, format, now)
+				if patch || output.summary() {
+					c, err := object.ReadCommit(repoPath, l.Hash)
+					if err != nil {
+						return err
+					}
 
-				if len(l.Message) > 0 {
-					fmt.Printf("    %s\n\n", strings.ReplaceAll(l.Message, "\n", "\n    "))
+					diffLines, err := commitDiffLines(c, output)
+					if err != nil {
+						return err
+					}
+					lines = append(lines, diffLines...)
 				}
+				printLogLines(l.Graph, lines)
 			}
 
-			if logResult.NextCommit != nil {
-				fmt.Printf("Next commit: %s", logResult.NextCommit)
+			// keep custom formats clean for scripts
+			if logResult.NextCommit != nil && len(format) == 0 {
+				fmt.Printf("Next commit: %s\n", logResult.NextCommit)
 			}
 
 			return nil
 		},
 	}
 
-	cmd.Flags().IntVarP(&limit, "limit", "l", 5, "You can set a limit to restrict the number of commits")
+	cmd.Flags().IntVarP(&limit, "limit", "l", 5, "You can set a limit to restrict the number of commits (0 for no limit)")
 	cmd.Flags().StringVarP(&from, "from", "f", "", "You can pass commitHash to start from the same one")
+	cmd.Flags().BoolVar(&all, "all", false, "Show the history of every branch")
+	cmd.Flags().BoolVar(&graph, "graph", false, "Draw the history as an ASCII graph")
+	cmd.Flags().BoolVar(&topoOrder, "topo-order", false, "Show no parents before all of their children, without interleaving lines of history")
+	cmd.Flags().BoolVar(&dateOrder, "date-order", false, "Show no parents before all of their children, otherwise by commit date")
+	cmd.Flags().StringVar(&author, "author", "", "Show commits whose author (name <email>) matches the regular expression")
+	cmd.Flags().StringVar(&grep, "grep", "", "Show commits whose message matches the regular expression")
+	cmd.Flags().StringVar(&since, "since", "", "Show commits more recent than a date (e.g. 2024-01-31, \"2 weeks ago\")")
+	cmd.Flags().BoolVar(&oneline, "oneline", false, "Show each commit as \"<short hash> <subject>\"")
+	cmd.Flags().StringVar(&format, "format", "", "Show commits with a template (%H, %h, %P, %p, %an, %ae, %ad, %ai, %at, %ar, %cn, %ce, %cd, %ci, %ct, %cr, %s, %b, %B, %n) or \"json\" for JSON lines")
+	cmd.Flags().BoolVarP(&patch, "patch", "p", false, "Show the changes of each commit")
+	output.addFlags(cmd)
+	cmd.Flags().StringVar(&until, "until", "", "Show commits older than a date (e.g. 2024-01-31, yesterday)")
 	return cmd
 }
+
+// logLines renders one commit, with the template when given or the default multi-line layout.
+func logLines(l log.LogCommit, format string, now time.Time) []string {
+	if len(format) > 0 {
+		return strings.Split(log.Format(l, format, now), "\n")
+	}
+
+	lines := []string{paint(color.Yellow, fmt.Sprintf("commit %s", l.Hash)) + paint(color.Cyan, l.Decoration())}
+	if len(l.Parents) > 1 {
+		parents := make([]string, 0, len(l.Parents))
+		for _, p := range l.Parents {
+			parents = append(parents, p.Short(7))
+		}
+		lines = append(lines, fmt.Sprintf("Merge: %s", strings.Join(parents, " ")))
+	}
+	if len(l.Author) > 0 {
+		lines = append(lines, fmt.Sprintf("Author: %s <%s>", l.Author, l.Email))
+	}
+
+	lines = append(lines, fmt.Sprintf("Date:   %s", l.Date.Format(time.RFC1123)), "")
+
+	if len(l.Message) > 0 {
+		for _, ml := range strings.Split(l.Message, "\n") {
+			lines = append(lines, "    "+ml)
+		}
+		lines = append(lines, "")
+	}
+
+	return lines
+}
+
+// printLogLines prints the lines of one commit, prefixed with the graph lanes if any.
+func printLogLines(row *log.GraphRow, lines []string) {
+	if row == nil {
+		for _, l := range lines {
+			fmt.Println(l)
+		}
+		return
+	}
+
+	for _, b := range row.Before {
+		fmt.Println(strings.TrimRight(b, " "))
+	}
+
+	for i, l := range lines {
+		prefix := row.Padding
+		switch {
+		case i == 0:
+			prefix = row.Commit
+		case i-1 < len(row.After):
+			prefix = row.After[i-1]
+		}
+		fmt.Println(strings.TrimRight(prefix+l, " "))
+	}
+
+	for i := len(lines) - 1; i < len(row.After); i++ {
+		fmt.Println(strings.TrimRight(row.After[i], " "))
+	}
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/add"
+	"github.com/matiasmartin00/arbor/internal/commit"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/repo"
+)
+
+// newRepo creates a repository in a temporary directory and moves into it.
+func newRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("ARBOR_CONFIG_USER__NAME", "T")
+	t.Setenv("ARBOR_CONFIG_USER__EMAIL", "t@x")
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commitFile writes and stages the file and commits it with message.
+func commitFile(t *testing.T, p, content, message string) object.ObjectHash {
+	t.Helper()
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := add.Add(".", false, []string{p}); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := commit.Commit(".", commit.CommitOptions{Message: message})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// runArbor runs arbor with args and returns what it printed.
+func runArbor(t *testing.T, args ...string) string {
+	t.Helper()
+	t.Cleanup(func() { prefix, colorMode, workDir = "", "", "" })
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+
+	cmd := NewRootCommand()
+	cmd.SetArgs(normalizeArgs(cmd, args))
+	err = cmd.Execute()
+	w.Close()
+	printed := <-out
+	if err != nil {
+		t.Fatalf("arbor %s: %v", strings.Join(args, " "), err)
+	}
+	return printed
+}
+
+func TestLogOutput(t *testing.T) {
+	newRepo(t)
+	first := commitFile(t, "f", "one\n", "first")
+	second := commitFile(t, "f", "two\n", "second\n\nbody")
+
+	t.Run("oneline", func(t *testing.T) {
+		want := second.Short(7) + " (HEAD -> main) second\n" + first.Short(7) + " first\n"
+		if got := runArbor(t, "log", "--oneline"); got != want {
+			t.Errorf("got\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("patch", func(t *testing.T) {
+		got := runArbor(t, "log", "--oneline", "-p", "--limit", "1")
+		for _, want := range []string{
+			second.Short(7) + " (HEAD -> main) second\n",
+			"diff -- a/f b/f (commit " + first.Short(7) + " -> " + second.Short(7) + ")\n",
+			"-one\n+two\n",
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("%q is missing from\n%s", want, got)
+			}
+		}
+		if strings.Contains(got, " first") {
+			t.Errorf("the limit is not applied:\n%s", got)
+		}
+	})
+
+	t.Run("format", func(t *testing.T) {
+		want := "T|second|body|" + first.Short(7) + "\nT|first||\n"
+		if got := runArbor(t, "log", "--format", "%an|%s|%b|%p"); got != want {
+			t.Errorf("got\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSuffix(runArbor(t, "log", "--format", "json"), "\n"), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("got %d lines, want one per commit:\n%s", len(lines), strings.Join(lines, "\n"))
+		}
+
+		var c struct {
+			Hash    string   `json:"hash"`
+			Parents []string `json:"parents"`
+			Message string   `json:"message"`
+			Refs    []string `json:"refs"`
+		}
+		if err := json.Unmarshal([]byte(lines[0]), &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Hash != second.String() || len(c.Parents) != 1 || c.Parents[0] != first.String() ||
+			c.Message != "second\n\nbody" || len(c.Refs) != 1 || c.Refs[0] != "HEAD -> main" {
+			t.Errorf("got %+v", c)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/matiasmartin00/arbor/internal/index"
+	"github.com/matiasmartin00/arbor/internal/pathspec"
+	"github.com/spf13/cobra"
+)
+
+func NewLsFilesCommand() *cobra.Command {
+	var stage bool
+	cmd := &cobra.Command{
+		Use:   "ls-files [--stage] [<path>...]",
+		Short: "List the files in the index",
+		Long: `Lists the paths in the index, relative to the repository root and sorted.
+With --stage each line is "<mode> <hash> 0\t<path>".`,
+		Args:    cobra.ArbitraryArgs,
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			paths, err := repoPaths(args)
+			if err != nil {
+				return err
+			}
+
+			idx, err := index.Load(repoPath)
+			if err != nil {
+				return err
+			}
+
+			files := make([]string, 0, len(idx))
+			for p := range idx {
+				if pathspec.Match(paths, p) {
+					files = append(files, p)
+				}
+			}
+			sort.Strings(files)
+
+			for _, f := range files {
+				if stage {
+					fmt.Printf("%s %s 0\t%s\n", idx[f].Mode, idx[f].Hash, f)
+					continue
+				}
+				fmt.Println(f)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&stage, "stage", "s", false, "Show the mode and hash of each file")
+	return cmd
+}
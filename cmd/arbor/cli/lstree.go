@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/revision"
+	"github.com/spf13/cobra"
+)
+
+func NewLsTreeCommand() *cobra.Command {
+	var recursive bool
+	cmd := &cobra.Command{
+		Use:   "ls-tree [-r] <tree-ish>",
+		Short: "List the contents of a tree",
+		Long: `The tree-ish is a tree hash, or a commit whose tree is listed.
+Each line is "<mode> <type> <hash>\t<path>". With -r subtrees are expanded
+and only blobs are listed, with their full path.`,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hash, err := revision.ResolveObject(repoPath, args[0])
+			if err != nil {
+				return err
+			}
+
+			objType, _, err := object.ReadObject(repoPath, hash)
+			if err != nil {
+				return err
+			}
+
+			if objType == object.CommitType {
+				c, err := object.ReadCommit(repoPath, hash)
+				if err != nil {
+					return err
+				}
+				hash = c.TreeHash()
+			} else if objType != object.TreeType {
+				return fmt.Errorf("%s is a %s, not a tree or commit", args[0], objType)
+			}
+
+			return listTree(hash, "", recursive)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recurse into subtrees")
+	return cmd
+}
+
+func listTree(hash object.ObjectHash, prefix string, recursive bool) error {
+	entries, err := object.ReadTreeEntries(repoPath, hash)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if recursive && e.Type == object.TreeType {
+			if err := listTree(e.Hash, prefix+e.Name+"/", recursive); err != nil {
+				return err
+			}
+			continue
+		}
+		printTreeEntry(e, prefix+e.Name)
+	}
+
+	return nil
+}
+
+func printTreeEntry(e object.TreeEntry, path string) {
+	fmt.Printf("%s %s %s\t%s\n", e.Mode, e.Type, e.Hash, path)
+}
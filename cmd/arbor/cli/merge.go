@@ -8,14 +8,15 @@ import (
 )
 
 func NewMergeCommand() *cobra.Command {
-	return &cobra.Command{
+	var noVerify bool
+	cmd := &cobra.Command{
 		Use:     "merge <branch>",
 		Short:   "Merge a branch into the current branch",
 		Args:    cobra.ExactArgs(1),
 		PreRunE: preRunErr,
 		RunE: func(c *cobra.Command, args []string) error {
 			branchName := args[0]
-			mergeDetail, err := merge.Merge(repoPath, branchName)
+			mergeDetail, err := merge.Merge(repoPath, branchName, merge.MergeOptions{NoVerify: noVerify})
 			if err != nil {
 				return err
 			}
@@ -42,4 +43,8 @@ func NewMergeCommand() *cobra.Command {
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&noVerify, "no-verify", false, "Skip the pre-merge hook")
+
+	return cmd
 }
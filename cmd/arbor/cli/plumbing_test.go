@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/add"
+	"github.com/matiasmartin00/arbor/internal/commit"
+	"github.com/matiasmartin00/arbor/internal/object"
+)
+
+func TestPlumbing(t *testing.T) {
+	newRepo(t)
+	if err := os.MkdirAll("d", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("d", "a"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("b", []byte("b\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod("b", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := add.Add(".", false, []string{"d/a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	head, err := commit.Commit(".", commit.CommitOptions{Message: "one"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := object.ReadCommit(".", head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobA, _ := object.NewHashBlob([]byte("a\n"))
+	blobB, _ := object.NewHashBlob([]byte("b\n"))
+	dir, err := object.FindTreeEntry(".", c.TreeHash(), "d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"cat-file", "-t", "HEAD"}, "commit\n"},
+		{[]string{"cat-file", "-t", "HEAD:d"}, "tree\n"},
+		{[]string{"cat-file", "-s", "HEAD:b"}, "2\n"},
+		{[]string{"cat-file", "-p", "HEAD:d/a"}, "a\n"},
+		{[]string{"cat-file", "-p", "HEAD^{tree}"}, "100755 blob " + blobB.String() + "\tb\n040000 tree " + dir.Hash.String() + "\td\n"},
+		{[]string{"ls-tree", "HEAD"}, "100755 blob " + blobB.String() + "\tb\n040000 tree " + dir.Hash.String() + "\td\n"},
+		{[]string{"ls-tree", "-r", "HEAD"}, "100755 blob " + blobB.String() + "\tb\n100644 blob " + blobA.String() + "\td/a\n"},
+		{[]string{"ls-files"}, "b\nd/a\n"},
+		{[]string{"ls-files", "d"}, "d/a\n"},
+		{[]string{"ls-files", "--stage"}, "100755 " + blobB.String() + " 0\tb\n100644 " + blobA.String() + " 0\td/a\n"},
+		{[]string{"rev-parse", "HEAD", "main"}, head.String() + "\n" + head.String() + "\n"},
+		{[]string{"rev-parse", "--short", "HEAD"}, head.Short(7) + "\n"},
+		{[]string{"rev-parse", "--short=10", "HEAD"}, head.Short(10) + "\n"},
+		{[]string{"hash-object", "b"}, blobB.String() + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			if got := runArbor(t, tt.args...); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("hash-object -w", func(t *testing.T) {
+		if err := os.WriteFile("x", []byte("x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		hash, _ := object.NewHashBlob([]byte("x\n"))
+
+		runArbor(t, "hash-object", "x")
+		if object.Exists(".", hash) {
+			t.Error("hash-object without -w wrote the blob")
+		}
+		if got := runArbor(t, "hash-object", "-w", "x"); got != hash.String()+"\n" || !object.Exists(".", hash) {
+			t.Errorf("got %q, want %s written", got, hash)
+		}
+	})
+}
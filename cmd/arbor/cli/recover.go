@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/matiasmartin00/arbor/internal/journal"
+	"github.com/spf13/cobra"
+)
+
+func NewRecoverCommand() *cobra.Command {
+	var resume bool
+	cmd := &cobra.Command{
+		Use:   "recover [--continue]",
+		Short: "Roll back or finish an interrupted checkout or merge",
+		Long: `A checkout or a merge that was interrupted, by a crash or a power loss,
+leaves its journal behind and other commands refuse to run until it is
+recovered. By default the working directory, the index and the refs are put
+back as they were before it started, with --continue it is finished instead.`,
+		Args: cobra.NoArgs,
+		// the other commands refuse to run while a journal is left, see preRunErr
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return enterRepo(true)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			action, err := journal.Recover(repoPath, resume)
+			if err != nil {
+				return err
+			}
+
+			if resume {
+				fmt.Printf("Finished the interrupted %s\n", action)
+				return nil
+			}
+			fmt.Printf("Rolled back the interrupted %s\n", action)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&resume, "continue", false, "Finish the interrupted checkout or merge instead of rolling it back")
+	return cmd
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/matiasmartin00/arbor/internal/reset"
+	"github.com/spf13/cobra"
+)
+
+func NewResetCommand() *cobra.Command {
+	var patch bool
+	cmd := &cobra.Command{
+		Use:     "reset [-p] [<path>...]",
+		Short:   "Unstage changes, keeping them in the working directory",
+		Args:    cobra.ArbitraryArgs,
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			paths, err := repoPaths(args)
+			if err != nil {
+				return err
+			}
+
+			if patch {
+				_, err := reset.ResetPatch(repoPath, paths, newHunkPrompter())
+				return err
+			}
+
+			unstaged, err := reset.Reset(repoPath, paths)
+			if err != nil {
+				return err
+			}
+
+			if len(unstaged) > 0 {
+				fmt.Println("Unstaged changes after reset:")
+			}
+			for _, p := range unstaged {
+				fmt.Printf("  %s\n", p)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&patch, "patch", "p", false, "Choose the hunks of the staged changes to unstage")
+	return cmd
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/matiasmartin00/arbor/internal/restore"
+	"github.com/spf13/cobra"
+)
+
+func NewRestoreCommand() *cobra.Command {
+	var patch bool
+	cmd := &cobra.Command{
+		Use:     "restore [-p] <path>...",
+		Short:   "Discard changes in the working directory, back to the staged version",
+		Args:    cobra.ArbitraryArgs,
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && !patch {
+				return fmt.Errorf("you must specify path(s) to restore")
+			}
+
+			paths, err := repoPaths(args)
+			if err != nil {
+				return err
+			}
+
+			if patch {
+				_, err := restore.RestorePatch(repoPath, paths, newHunkPrompter())
+				return err
+			}
+
+			restored, err := restore.Restore(repoPath, paths)
+			if err != nil {
+				return err
+			}
+
+			for _, p := range restored {
+				fmt.Printf("Restored %s\n", p)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&patch, "patch", "p", false, "Choose the hunks of the changes to discard")
+	return cmd
+}
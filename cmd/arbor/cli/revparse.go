@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/matiasmartin00/arbor/internal/revision"
+	"github.com/spf13/cobra"
+)
+
+func NewRevParseCommand() *cobra.Command {
+	var short int
+	cmd := &cobra.Command{
+		Use:   "rev-parse [--short[=<n>]] <rev>...",
+		Short: "Print the object hash of revisions",
+		Long: `Prints the full hash of each revision, one per line. Revisions are branch
+names, HEAD, hashes, with ~<n> and ^<n> suffixes, <rev>:<path> and <rev>^{tree}.`,
+		Args:    cobra.MinimumNArgs(1),
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, rev := range args {
+				hash, err := revision.ResolveObject(repoPath, rev)
+				if err != nil {
+					return err
+				}
+
+				if short > 0 {
+					fmt.Println(hash.Short(short))
+					continue
+				}
+				fmt.Println(hash)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&short, "short", 0, "Abbreviate hashes to n characters")
+	cmd.Flags().Lookup("short").NoOptDefVal = "7"
+	return cmd
+}
@@ -1,37 +1,242 @@
 package cli
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/color"
+	"github.com/matiasmartin00/arbor/internal/commit"
+	"github.com/matiasmartin00/arbor/internal/hunk"
+	"github.com/matiasmartin00/arbor/internal/journal"
+	"github.com/matiasmartin00/arbor/internal/pager"
+	"github.com/matiasmartin00/arbor/internal/pathspec"
 	"github.com/matiasmartin00/arbor/internal/repo"
+	"github.com/matiasmartin00/arbor/internal/utils"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 const version = "0.1.0"
+
+// commands run in the repository root, see preRunErr
 const repoPath = "."
 
+var (
+	// workDir is the -C directory, arbor starts there instead of the current directory
+	workDir string
+	// prefix is the directory arbor was started in, relative to the repository root
+	prefix string
+	// colorMode is the --color flag, color.ui decides when empty
+	colorMode string
+	noPager   bool
+	// colorOn is set by startOutput for the commands that color their output
+	colorOn bool
+)
+
 var preRunErr = func(cmd *cobra.Command, args []string) error {
-	if err := repo.EnsureRepo(repoPath); err != nil {
+	if err := enterRepo(true); err != nil {
+		return err
+	}
+
+	// an interrupted checkout or merge must be recovered first
+	return journal.Check(repoPath)
+}
+
+// preRunOptional is for commands that also work outside of a repository
+var preRunOptional = func(cmd *cobra.Command, args []string) error {
+	return enterRepo(false)
+}
+
+// enterRepo finds the repository arbor was started in and changes to its root.
+func enterRepo(required bool) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	root, err := repo.FindRoot(cwd)
+	if err != nil {
+		if !required && errors.Is(err, repo.ErrNotRepo) {
+			return nil
+		}
+		return err
+	}
+
+	rel, err := filepath.Rel(root, cwd)
+	if err != nil {
+		return err
+	}
+	prefix = rel
+
+	// paths in the index and the trees are relative to the root, run from there
+	if err := os.Chdir(root); err != nil {
 		return err
 	}
-	return nil
+
+	return repo.EnsureRepo(repoPath)
+}
+
+// startOutput decides if the output is colored and, with page, sends it through
+// the pager when it goes to a terminal. The returned function waits for the pager.
+func startOutput(page bool) (func(), error) {
+	terminal := pager.IsTerminal(os.Stdout)
+
+	on, err := color.Enabled(repoPath, colorMode, terminal)
+	if err != nil {
+		return nil, err
+	}
+	colorOn = on
+
+	if !page || noPager || !terminal {
+		return func() {}, nil
+	}
+
+	command, err := pager.Command(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	return pager.Start(command), nil
+}
+
+// paint colors s when the output is colored.
+func paint(code, s string) string {
+	return color.Paint(colorOn, code, s)
+}
+
+// newHunkPrompter asks about hunks on the terminal, editing them in the user editor.
+func newHunkPrompter() *hunk.Prompter {
+	p := hunk.NewPrompter(os.Stdin, os.Stdout)
+	p.Edit = func(text string) (string, error) {
+		path := filepath.Join(utils.GetRepoDir(repoPath), "ADD_EDIT.patch")
+		if err := utils.WriteFile(path, []byte(text)); err != nil {
+			return "", err
+		}
+		defer utils.RemoveFile(path)
+
+		if err := commit.EditFile(repoPath, path); err != nil {
+			return "", err
+		}
+
+		data, err := utils.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return p
+}
+
+// repoPaths translates paths given relative to the directory arbor was started in
+// into repository relative paths.
+func repoPaths(paths []string) ([]string, error) {
+	root, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return pathspec.Resolve(root, prefix, paths)
 }
 
 func NewRootCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "arbor",
 		Short: "Arbor is a simple version control system",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(workDir) == 0 {
+				return nil
+			}
+
+			if err := os.Chdir(workDir); err != nil {
+				return fmt.Errorf("can not change to %s: %w", workDir, err)
+			}
+			return nil
+		},
 	}
 
+	cmd.PersistentFlags().StringVarP(&workDir, "directory", "C", "", "Run as if arbor was started in this directory")
+	cmd.PersistentFlags().StringVar(&colorMode, "color", "", "Color the output: auto, always or never (default color.ui or auto)")
+	cmd.PersistentFlags().BoolVar(&noPager, "no-pager", false, "Do not send long output through the pager")
+
 	cmd.AddCommand(
 		NewInitCommand(),
 		NewAddCommand(),
 		NewCommitCommand(),
 		NewLogCommand(),
 		NewCheckoutCommand(),
+		NewSwitchCommand(),
 		NewBranchCommand(),
 		NewStatusCommand(),
 		NewDiffCommand(),
 		NewMergeCommand(),
+		NewBlameCommand(),
+		NewBisectCommand(),
+		NewConfigCommand(),
+		NewFsckCommand(),
+		NewGCCommand(),
+		NewPruneCommand(),
+		NewCatFileCommand(),
+		NewHashObjectCommand(),
+		NewLsTreeCommand(),
+		NewLsFilesCommand(),
+		NewRevParseCommand(),
+		NewShowCommand(),
+		NewResetCommand(),
+		NewRestoreCommand(),
+		NewRecoverCommand(),
 	)
 
+	cmd.SetArgs(normalizeArgs(cmd, os.Args[1:]))
+
 	return cmd
 }
+
+// normalizeArgs rewrites the options that pflag can not parse, "-M50%" becomes
+// "--find-renames=50%" for the commands that detect renames. Flag values and
+// the arguments after "--" are left alone.
+func normalizeArgs(root *cobra.Command, args []string) []string {
+	cmd, _, err := root.Find(args)
+	if err != nil || cmd.Flags().Lookup("find-renames") == nil {
+		return args
+	}
+
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			return append(out, args[i:]...)
+		}
+
+		if len(a) > 2 && strings.HasPrefix(a, "-M") && a[2] != '=' {
+			a = "--find-renames=" + a[2:]
+		}
+		out = append(out, a)
+
+		// the next argument is the value of the flag
+		if takesValue(cmd, a) && i+1 < len(args) {
+			i++
+			out = append(out, args[i])
+		}
+	}
+	return out
+}
+
+// takesValue reports if the argument is a flag of cmd whose value is the next
+// argument.
+func takesValue(cmd *cobra.Command, arg string) bool {
+	var f *pflag.Flag
+	switch {
+	case strings.HasPrefix(arg, "--") && !strings.Contains(arg, "="):
+		f = cmd.Flags().Lookup(arg[2:])
+		if f == nil {
+			f = cmd.InheritedFlags().Lookup(arg[2:])
+		}
+	case len(arg) == 2 && arg[0] == '-':
+		f = cmd.Flags().ShorthandLookup(arg[1:])
+		if f == nil {
+			f = cmd.InheritedFlags().ShorthandLookup(arg[1:])
+		}
+	}
+	return f != nil && len(f.NoOptDefVal) == 0
+}
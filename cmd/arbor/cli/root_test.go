@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"diff", []string{"diff", "-M90%"}, []string{"diff", "--find-renames=90%"}},
+		{"show", []string{"show", "HEAD", "-M"}, []string{"show", "HEAD", "-M"}},
+		{"log", []string{"log", "-M30", "-p"}, []string{"log", "--find-renames=30", "-p"}},
+		{"global flag", []string{"-C", "dir", "diff", "-M70%"}, []string{"-C", "dir", "diff", "--find-renames=70%"}},
+		{"flag value", []string{"log", "--grep", "-M fix", "-M90%"}, []string{"log", "--grep", "-M fix", "--find-renames=90%"}},
+		{"after --", []string{"diff", "--", "-Mfile"}, []string{"diff", "--", "-Mfile"}},
+		{"no renames", []string{"commit", "--allow-empty", "-m", "-M fix"}, []string{"commit", "--allow-empty", "-m", "-M fix"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeArgs(NewRootCommand(), tt.args)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColorFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		mode string
+		cmd  string
+	}{
+		{[]string{"--color", "never", "diff"}, "never", "diff"},
+		{[]string{"--color=always", "log"}, "always", "log"},
+		{[]string{"diff", "--color", "auto", "--staged"}, "auto", "diff"},
+		{[]string{"status"}, "", "status"},
+	}
+
+	for _, tt := range tests {
+		colorMode = ""
+		cmd, rest, err := NewRootCommand().Find(tt.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.ParseFlags(rest); err != nil {
+			t.Fatal(err)
+		}
+		if cmd.Name() != tt.cmd || colorMode != tt.mode || len(cmd.Flags().Args()) != 0 {
+			t.Errorf("%q: got %s with --color %q and arguments %q, want %s with %q",
+				tt.args, cmd.Name(), colorMode, cmd.Flags().Args(), tt.cmd, tt.mode)
+		}
+	}
+}
+
+func TestSubdirectory(t *testing.T) {
+	newRepo(t)
+	if err := os.MkdirAll(filepath.Join("src", "pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range map[string]string{"src/pkg/a": "a\n", "top": "top\n"} {
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// paths are relative to the directory arbor starts in
+	if err := os.Chdir("src"); err != nil {
+		t.Fatal(err)
+	}
+	out := runArbor(t, "add", "pkg/a", "../top")
+	if !strings.Contains(out, "Added src/pkg/a ") || !strings.Contains(out, "Added top ") {
+		t.Errorf("got\n%s\nwant src/pkg/a and top added", out)
+	}
+
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if got := runArbor(t, "-C", filepath.Join(root, "src", "pkg"), "ls-files"); got != "src/pkg/a\ntop\n" {
+		t.Errorf("got\n%s\nwant the index of the repository above -C", got)
+	}
+}
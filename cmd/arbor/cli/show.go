@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/color"
+	"github.com/matiasmartin00/arbor/internal/diff"
+	"github.com/matiasmartin00/arbor/internal/log"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/revision"
+	"github.com/spf13/cobra"
+)
+
+func NewShowCommand() *cobra.Command {
+	var output diffOutput
+	cmd := &cobra.Command{
+		Use:   "show [<object>...]",
+		Short: "Show commits, trees and file contents",
+		Long: `For a commit prints its header and message followed by its diff against its
+parent, or a combined diff against every parent for a merge.
+<rev>:<path> prints the content of a file at a revision, or lists a directory.`,
+		Args:    cobra.ArbitraryArgs,
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{"HEAD"}
+			}
+
+			stop, err := startOutput(true)
+			if err != nil {
+				return err
+			}
+			defer stop()
+
+			for _, rev := range args {
+				if err := showObject(rev, output); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	output.addFlags(cmd)
+	return cmd
+}
+
+func showObject(rev string, output diffOutput) error {
+	hash, err := revision.ResolveObject(repoPath, rev)
+	if err != nil {
+		return err
+	}
+
+	objType, data, err := object.ReadObject(repoPath, hash)
+	if err != nil {
+		return err
+	}
+
+	switch objType {
+	case object.CommitType:
+		return showCommit(hash, output)
+	case object.TreeType:
+		entries, err := object.ReadTreeEntries(repoPath, hash)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("tree %s\n\n", rev)
+		for _, e := range entries {
+			if e.Type == object.TreeType {
+				fmt.Printf("%s/\n", e.Name)
+				continue
+			}
+			fmt.Println(e.Name)
+		}
+		fmt.Println()
+		return nil
+	default:
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+}
+
+func showCommit(hash object.ObjectHash, output diffOutput) error {
+	c, err := object.ReadCommit(repoPath, hash)
+	if err != nil {
+		return err
+	}
+
+	lines := logLines(log.NewLogCommit(c), "", time.Now())
+	diffLines, err := commitDiffLines(c, output)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range append(lines, diffLines...) {
+		fmt.Println(l)
+	}
+	return nil
+}
+
+// commitDiffLines renders the changes made by a commit against its parent. Merges
+// get a combined diff against every parent, or a summary against the first parent.
+func commitDiffLines(c object.Commit, output diffOutput) ([]string, error) {
+	parentTrees := make([]object.ObjectHash, 0, len(c.ParentHashes()))
+	for _, p := range c.ParentHashes() {
+		parent, err := object.ReadCommit(repoPath, p)
+		if err != nil {
+			return nil, err
+		}
+		parentTrees = append(parentTrees, parent.TreeHash())
+	}
+
+	opts, err := output.options(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parentTrees) > 1 && !output.summary() {
+		results, err := diff.CombinedDiff(repoPath, parentTrees, c.TreeHash(), opts)
+		if err != nil {
+			return nil, err
+		}
+		return combinedLines(results), nil
+	}
+
+	var parentTree object.ObjectHash
+	label := "root commit"
+	if len(parentTrees) > 0 {
+		parentTree = parentTrees[0]
+		label = fmt.Sprintf("commit %s -> %s", c.ParentHash().Short(7), c.Hash().Short(7))
+	}
+
+	results, err := diff.DiffTrees(repoPath, parentTree, c.TreeHash(), opts)
+	if err != nil {
+		return nil, err
+	}
+	return output.lines(label, results), nil
+}
+
+// combinedLines renders a combined diff, with one +/- column per parent.
+func combinedLines(results []diff.CombinedResult) []string {
+	out := []string{}
+	for _, r := range results {
+		out = append(out, paint(color.Bold, fmt.Sprintf("diff --cc %s", r.File)))
+
+		parents := make([]string, len(r.Parents))
+		for i, p := range r.Parents {
+			parents[i] = shortOrNone(p)
+		}
+		out = append(out, paint(color.Bold, fmt.Sprintf("index %s..%s", strings.Join(parents, ","), shortOrNone(r.Hash))))
+
+		if r.Lines == nil {
+			out = append(out, "Binary file", "", "")
+			continue
+		}
+
+		for _, l := range r.Lines {
+			var sb strings.Builder
+			code := ""
+			for _, m := range l.Markers {
+				sb.WriteString(m.String())
+				if m != diff.EqLine {
+					code = lineColor(m)
+				}
+			}
+			out = append(out, paint(code, sb.String()+l.Line))
+		}
+		out = append(out, "", "")
+	}
+	return out
+}
+
+func shortOrNone(h object.ObjectHash) string {
+	if h == nil {
+		return strings.Repeat("0", 7)
+	}
+	return h.Short(7)
+}
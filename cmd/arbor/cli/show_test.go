@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
+)
+
+// writeMergeHistory writes a base commit, one commit on each side changing a
+// different line of f, and their merge taking both changes. Every commit also
+// holds d/x. main points to the merge. It returns the commits by name.
+func writeMergeHistory(t *testing.T) map[string]object.ObjectHash {
+	t.Helper()
+	x, err := object.WriteBlobData(".", []byte("x\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commits := map[string]object.ObjectHash{}
+	add := func(name, content string, parents ...string) {
+		t.Helper()
+		blob, err := object.WriteBlobData(".", []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := object.WriteTree(".", map[string]object.ObjectHash{"f": blob, "d/x": x}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parentHashes := []object.ObjectHash{}
+		for _, p := range parents {
+			parentHashes = append(parentHashes, commits[p])
+		}
+		sig := object.Signature{Name: "T", Email: "t@x", When: time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)}
+		commits[name], err = object.WriteCommit(".", tree, parentHashes, sig, sig, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	add("base", "a\nb\n")
+	add("ours", "M\nb\n", "base")
+	add("theirs", "a\nT\n", "base")
+	add("merge", "M\nT\n", "ours", "theirs")
+
+	if err := refs.CreateRef(".", "main", commits["merge"]); err != nil {
+		t.Fatal(err)
+	}
+	return commits
+}
+
+func TestShow(t *testing.T) {
+	newRepo(t)
+	commits := writeMergeHistory(t)
+	blob := func(content string) string {
+		h, _ := object.NewHashBlob([]byte(content))
+		return h.Short(7)
+	}
+
+	header := func(name string, merge bool) string {
+		s := "commit " + commits[name].String() + "\n"
+		if merge {
+			s += "Merge: " + commits["ours"].Short(7) + " " + commits["theirs"].Short(7) + "\n"
+		}
+		return s + "Author: T <t@x>\nDate:   Wed, 31 Jan 2024 10:00:00 +0000\n\n    " + name + "\n\n"
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"combined diff of a merge", []string{"show"}, header("merge", true) +
+			"diff --cc f\n" +
+			"index " + blob("M\nb\n") + "," + blob("a\nT\n") + ".." + blob("M\nT\n") + "\n" +
+			" -a\n +M\n- b\n+ T\n"},
+		{"summary of a merge", []string{"show", "--stat", "HEAD"}, header("merge", true) +
+			" f | 2 +-\n 1 file changed, 1 insertion(+), 1 deletion(-)\n"},
+		{"diff against the parent", []string{"show", "--name-only", "HEAD^2"}, header("theirs", false) + "f\n"},
+		{"tree", []string{"show", "HEAD:"}, "tree HEAD:\n\nd/\nf\n\n"},
+		{"blobs", []string{"show", "HEAD:f", "HEAD~1:f"}, "M\nT\nM\nb\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runArbor(t, tt.args...)
+			if strings.TrimRight(got, "\n") != strings.TrimRight(tt.want, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
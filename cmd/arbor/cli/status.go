@@ -3,28 +3,46 @@ package cli
 import (
 	"fmt"
 
+	"github.com/matiasmartin00/arbor/internal/color"
 	"github.com/matiasmartin00/arbor/internal/status"
 	"github.com/spf13/cobra"
 )
 
 func NewStatusCommand() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "status",
+		Use:     "status [<path>...]",
 		Short:   "Show working tree status",
-		Args:    cobra.NoArgs,
+		Args:    cobra.ArbitraryArgs,
 		PreRunE: preRunErr,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			status, err := status.Status(repoPath)
+			paths, err := repoPaths(args)
 			if err != nil {
 				return err
 			}
 
+			status, err := status.Status(repoPath, paths)
+			if err != nil {
+				return err
+			}
+
+			stop, err := startOutput(false)
+			if err != nil {
+				return err
+			}
+			defer stop()
+
+			if status.Detached != nil {
+				fmt.Printf("%s\n\n", paint(color.Red, fmt.Sprintf("HEAD detached at %s", status.Detached.Short(7))))
+			} else {
+				fmt.Printf("On branch %s\n\n", status.Branch)
+			}
+
 			if len(status.ToBeCommitted) == 0 {
 				fmt.Println("No changes to be committed.")
 			} else {
 				fmt.Println("Changes to be commited: ")
 				for _, l := range status.ToBeCommitted {
-					fmt.Printf("  %s\n", l)
+					fmt.Printf("  %s\n", paint(color.Green, l))
 				}
 			}
 			fmt.Printf("\n\n")
@@ -34,7 +52,7 @@ func NewStatusCommand() *cobra.Command {
 			} else {
 				fmt.Println("Changes not staged for commit: ")
 				for _, l := range status.NotStaged {
-					fmt.Printf("  %s\n", l)
+					fmt.Printf("  %s\n", paint(color.Red, l))
 				}
 			}
 			fmt.Printf("\n\n")
@@ -44,7 +62,7 @@ func NewStatusCommand() *cobra.Command {
 			} else {
 				fmt.Println("Untracked files: ")
 				for _, u := range status.Untracked {
-					fmt.Printf("  %s\n", u)
+					fmt.Printf("  %s\n", paint(color.Red, u))
 				}
 			}
 
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/matiasmartin00/arbor/internal/checkout"
+	"github.com/spf13/cobra"
+)
+
+func NewSwitchCommand() *cobra.Command {
+	var opts checkout.SwitchOptions
+	var create string
+	cmd := &cobra.Command{
+		Use:   "switch [--force | --merge] (<branch> | -c <new-branch> [<start-point>])",
+		Short: "Switch to a branch",
+		Long: `Switches to a branch like checkout, carrying the local changes over the same
+way, but never detaches HEAD. With -c the branch is created first, at
+start-point or at HEAD.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(create) > 0 {
+				return cobra.MaximumNArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		PreRunE: preRunErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := create
+			if len(create) > 0 {
+				opts.Create = true
+				if len(args) > 0 {
+					opts.StartPoint = args[0]
+				}
+			} else {
+				name = args[0]
+			}
+
+			result, err := checkout.Switch(repoPath, name, opts)
+			if err != nil {
+				return err
+			}
+
+			if opts.Create {
+				fmt.Printf("Switched to a new branch %s\n", name)
+			} else {
+				fmt.Printf("Switched to branch %s\n", name)
+			}
+			return printCheckoutResult(result)
+		},
+	}
+
+	cmd.Flags().StringVarP(&create, "create", "c", "", "Create the branch and switch to it")
+	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Discard the local changes")
+	cmd.Flags().BoolVarP(&opts.Merge, "merge", "m", false, "Merge the local changes into the files that change")
+	cmd.MarkFlagsMutuallyExclusive("force", "merge")
+
+	return cmd
+}
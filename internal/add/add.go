@@ -19,10 +19,11 @@ type AddResult struct {
 // returns thepath->blobHash of the added files
 func Add(repoPath string, deleted bool, inputs []string) ([]AddResult, error) {
 	added := make(map[string]object.ObjectHash)
-	idx, err := index.Load(repoPath)
+	idx, lock, err := index.LoadForUpdate(repoPath)
 	if err != nil {
 		return nil, err
 	}
+	defer lock.Release()
 
 	collectFile := func(filePath string) error {
 		// ignore .arbor directory
@@ -33,8 +34,8 @@ func Add(repoPath string, deleted bool, inputs []string) ([]AddResult, error) {
 			return nil
 		}
 
-		// just add regular files
-		info, err := os.Stat(filePath)
+		// just add files and symlinks, which are stored as their target
+		info, err := os.Lstat(filePath)
 		if err != nil {
 			return err
 		}
@@ -43,7 +44,12 @@ func Add(repoPath string, deleted bool, inputs []string) ([]AddResult, error) {
 			return nil
 		}
 
-		hash, err := object.WriteBlob(repoPath, filePath)
+		data, mode, err := object.ReadWorktreeFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		hash, err := object.WriteBlobData(repoPath, data)
 		if err != nil {
 			return err
 		}
@@ -55,22 +61,25 @@ func Add(repoPath string, deleted bool, inputs []string) ([]AddResult, error) {
 		}
 
 		relPath = filepath.ToSlash(relPath) // use slash as separator in the index
+		if err := object.ValidatePath(relPath); err != nil {
+			return err
+		}
 
 		curIdxEntry, ok := idx[relPath]
 		// if not exists in index, it is a new file
 		if !ok {
-			idx.AddEntry(relPath, hash)
+			idx.AddEntryMode(relPath, hash, mode)
 			added[relPath] = hash
 			return nil
 		}
 
 		// if it is the same, then it don't have changes
-		if curIdxEntry.Hash.Equals(hash) {
+		if curIdxEntry.Hash.Equals(hash) && curIdxEntry.Mode == mode {
 			return nil
 		}
 
 		// exists but with changes
-		idx.AddEntry(relPath, hash)
+		idx.AddEntryMode(relPath, hash, mode)
 		added[relPath] = hash
 
 		return nil
@@ -86,7 +95,7 @@ func Add(repoPath string, deleted bool, inputs []string) ([]AddResult, error) {
 
 			for _, match := range matches {
 				// recursively add files in directories
-				info, err := os.Stat(match)
+				info, err := os.Lstat(match)
 				if err != nil {
 					return nil, err
 				}
@@ -115,10 +124,10 @@ func Add(repoPath string, deleted bool, inputs []string) ([]AddResult, error) {
 		}
 
 		// if not a glob, check if it exists
-		info, err := os.Stat(in)
+		info, err := os.Lstat(in)
 		if err != nil {
 			alt := filepath.Join(repoPath, in)
-			info, err = os.Stat(alt)
+			info, err = os.Lstat(alt)
 			if err == nil {
 				in = alt
 			} else {
@@ -154,7 +163,7 @@ func Add(repoPath string, deleted bool, inputs []string) ([]AddResult, error) {
 		stageDeleted(idx, added)
 	}
 
-	if err := idx.Save(repoPath); err != nil {
+	if err := idx.Commit(lock); err != nil {
 		return nil, err
 	}
 
@@ -163,7 +172,7 @@ func Add(repoPath string, deleted bool, inputs []string) ([]AddResult, error) {
 
 func stageDeleted(idx index.Index, added map[string]object.ObjectHash) {
 	for p := range idx {
-		if _, err := os.Stat(p); os.IsNotExist(err) {
+		if _, err := os.Lstat(p); os.IsNotExist(err) {
 			idx.Remove(p)
 			added[p] = nil
 		}
@@ -0,0 +1,56 @@
+package add
+
+import (
+	"github.com/matiasmartin00/arbor/internal/diff"
+	"github.com/matiasmartin00/arbor/internal/hunk"
+	"github.com/matiasmartin00/arbor/internal/index"
+	"github.com/matiasmartin00/arbor/internal/object"
+)
+
+// AddPatch walks the hunks of the changes in the working directory under paths
+// (repository relative) and stages the ones taken with p, as a new blob built
+// from the staged version and those hunks. New, deleted and binary files, and
+// symlinks, are left out.
+func AddPatch(repoPath string, paths []string, p *hunk.Prompter) ([]AddResult, error) {
+	idx, lock, err := index.LoadForUpdate(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	results, err := diff.DiffWorktreeVsIndex(repoPath, diff.Options{Paths: paths})
+	if err != nil {
+		return nil, err
+	}
+
+	added := map[string]object.ObjectHash{}
+	for _, dr := range results {
+		if dr.Lines == nil || dr.BHash == nil || dr.IsSymlink() {
+			continue
+		}
+
+		hunks, accepted, more, err := p.Select(dr, "Stage this hunk")
+		if err != nil {
+			return nil, err
+		}
+
+		if hunk.Taken(accepted) {
+			hash, err := object.WriteBlobData(repoPath, hunk.Apply(dr.Lines, hunks, accepted))
+			if err != nil {
+				return nil, err
+			}
+			idx.AddEntry(dr.File, hash)
+			added[dr.File] = hash
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	if err := idx.Commit(lock); err != nil {
+		return nil, err
+	}
+
+	return toAddResult(added), nil
+}
@@ -0,0 +1,669 @@
+package bisect
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/branch"
+	"github.com/matiasmartin00/arbor/internal/journal"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
+	"github.com/matiasmartin00/arbor/internal/revision"
+	"github.com/matiasmartin00/arbor/internal/utils"
+	"github.com/matiasmartin00/arbor/internal/worktree"
+)
+
+// state files, all of them under .arbor/
+const (
+	startFile    = "BISECT_START"        // commit checked out before bisecting
+	branchFile   = "BISECT_BRANCH"       // branch checked out before bisecting, missing when detached
+	badFile      = "BISECT_BAD"          // the known bad commit
+	goodFile     = "BISECT_GOOD"         // known good commits, one per line
+	skipFile     = "BISECT_SKIP"         // commits that can not be tested, one per line
+	expectedFile = "BISECT_EXPECTED_REV" // commit checked out for testing
+	logFile      = "BISECT_LOG"          // commands run so far
+)
+
+// exit codes of `bisect run` commands
+const (
+	skipExitCode   = 125
+	maxBadExitCode = 127
+)
+
+var errNotBisecting = fmt.Errorf("not bisecting, run `arbor bisect start <bad> <good>` first")
+
+type Term int
+
+const (
+	Good Term = iota
+	Bad
+	Skip
+)
+
+func (t Term) String() string {
+	terms := []string{"good", "bad", "skip"}
+	if t < 0 || int(t) >= len(terms) {
+		return ""
+	}
+	return terms[int(t)]
+}
+
+type Step struct {
+	// Done is set when the first bad commit is known (or only skipped commits are left)
+	Done bool
+	// FirstBad is the first bad commit when Done and it could be told apart
+	FirstBad object.ObjectHash
+	// Candidates holds the commits that may be the first bad one, when skipped commits hide it
+	Candidates []object.ObjectHash
+	// Current is the commit checked out for testing
+	Current object.ObjectHash
+	// Remaining is the number of commits left to test
+	Remaining int
+	// Steps is roughly how many more tests are needed
+	Steps int
+}
+
+type state struct {
+	start object.ObjectHash
+	bad   object.ObjectHash
+	goods []object.ObjectHash
+	skips []object.ObjectHash
+}
+
+// Start begins a bisect session between a bad commit and one or more good ones,
+// and checks out the first commit to test.
+func Start(repoPath, bad string, goods []string) (Step, error) {
+	if isBisecting(repoPath) {
+		return Step{}, fmt.Errorf("bisect already in progress, run `arbor bisect reset` first")
+	}
+
+	if len(goods) == 0 {
+		return Step{}, fmt.Errorf("at least one good commit is required")
+	}
+
+	startHash, err := refs.GetRefHash(repoPath)
+	if err != nil {
+		return Step{}, err
+	}
+	if startHash == nil {
+		return Step{}, fmt.Errorf("no commits yet")
+	}
+
+	st := state{start: startHash}
+
+	st.bad, err = revision.Resolve(repoPath, bad)
+	if err != nil {
+		return Step{}, err
+	}
+
+	for _, g := range goods {
+		h, err := revision.Resolve(repoPath, g)
+		if err != nil {
+			return Step{}, err
+		}
+		st.goods = append(st.goods, h)
+	}
+
+	// the commits are tested on a detached HEAD, reset goes back to the branch
+	current, err := branch.GetCurrentBranch(repoPath)
+	if err != nil && !errors.Is(err, branch.ErrDetachedHead) {
+		return Step{}, err
+	}
+
+	if err := writeHash(repoPath, startFile, startHash); err != nil {
+		return Step{}, errors.Join(err, clearState(repoPath))
+	}
+
+	if len(current) > 0 {
+		if err := utils.WriteFile(statePath(repoPath, branchFile), []byte(current+"\n")); err != nil {
+			return Step{}, errors.Join(err, clearState(repoPath))
+		}
+	}
+
+	if err := appendLog(repoPath, fmt.Sprintf("arbor bisect start %s %s", bad, strings.Join(goods, " "))); err != nil {
+		return Step{}, errors.Join(err, clearState(repoPath))
+	}
+
+	if err := saveState(repoPath, st); err != nil {
+		return Step{}, errors.Join(err, clearState(repoPath))
+	}
+
+	step, err := next(repoPath, st)
+	if err != nil {
+		// a session that could not check out its first commit did not start
+		return Step{}, errors.Join(err, clearState(repoPath))
+	}
+	return step, nil
+}
+
+// Mark classifies a commit (the one checked out when rev is empty) and checks out the next one to test.
+func Mark(repoPath string, term Term, rev string) (Step, error) {
+	st, err := loadState(repoPath)
+	if err != nil {
+		return Step{}, err
+	}
+
+	hash, err := markedHash(repoPath, rev)
+	if err != nil {
+		return Step{}, err
+	}
+
+	switch term {
+	case Good:
+		st.goods = append(st.goods, hash)
+	case Bad:
+		st.bad = hash
+	case Skip:
+		st.skips = append(st.skips, hash)
+	}
+
+	line := fmt.Sprintf("# %s: %s", term, describe(repoPath, hash))
+	if err := appendLog(repoPath, line, fmt.Sprintf("arbor bisect %s %s", term, hash)); err != nil {
+		return Step{}, err
+	}
+
+	if err := saveState(repoPath, st); err != nil {
+		return Step{}, err
+	}
+
+	return next(repoPath, st)
+}
+
+// Reset ends the session, restores the commit checked out before it started and clears the state.
+func Reset(repoPath string) error {
+	if !isBisecting(repoPath) {
+		return errNotBisecting
+	}
+
+	start, err := readHashes(repoPath, startFile)
+	if err != nil {
+		return err
+	}
+
+	if len(start) == 1 {
+		if err := restoreStart(repoPath, start[0]); err != nil {
+			return err
+		}
+	}
+
+	return clearState(repoPath)
+}
+
+// clearState removes the state files of the session.
+func clearState(repoPath string) error {
+	for _, f := range []string{startFile, branchFile, badFile, goodFile, skipFile, expectedFile, logFile} {
+		if err := utils.RemoveFile(statePath(repoPath, f)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreStart checks out the branch bisect started on, or detaches HEAD at the
+// commit it started at when there was no branch or it is gone.
+func restoreStart(repoPath string, start object.ObjectHash) error {
+	data, err := utils.ReadFile(statePath(repoPath, branchFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	name := strings.TrimSpace(string(data))
+	if len(name) > 0 && refs.ExistsRef(repoPath, name) {
+		hash, err := refs.GetRefHashByName(repoPath, name)
+		if err != nil {
+			return err
+		}
+		_, err = worktree.RestoreCommitWorktree(repoPath, hash, worktree.Options{Action: "bisect"}, journal.SetHead(name))
+		return err
+	}
+
+	_, err = worktree.RestoreCommitWorktree(repoPath, start, worktree.Options{Action: "bisect"}, journal.SetDetachedHead(start))
+	return err
+}
+
+// Log returns the commands run in the current session.
+func Log(repoPath string) ([]string, error) {
+	if !isBisecting(repoPath) {
+		return nil, errNotBisecting
+	}
+
+	data, err := utils.ReadFile(statePath(repoPath, logFile))
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n"), nil
+}
+
+// Run tests commits with command until the first bad commit is found.
+// Exit code 0 marks the commit good, 125 skips it, 1 to 127 mark it bad,
+// anything else aborts the run. progress is called after each test.
+func Run(repoPath string, command []string, progress func(tested object.ObjectHash, term Term, next Step)) (Step, error) {
+	if len(command) == 0 {
+		return Step{}, fmt.Errorf("bisect run requires a command")
+	}
+
+	st, err := loadState(repoPath)
+	if err != nil {
+		return Step{}, err
+	}
+
+	if err := appendLog(repoPath, "# arbor bisect run "+strings.Join(command, " ")); err != nil {
+		return Step{}, err
+	}
+
+	step, err := next(repoPath, st)
+	if err != nil {
+		return Step{}, err
+	}
+
+	for !step.Done {
+		tested := step.Current
+
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Dir = repoPath
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		term := Good
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return Step{}, fmt.Errorf("bisect run failed to start %q: %w", command[0], err)
+			}
+
+			code := exitErr.ExitCode()
+			switch {
+			case code == skipExitCode:
+				term = Skip
+			case code > 0 && code <= maxBadExitCode:
+				term = Bad
+			default:
+				return Step{}, fmt.Errorf("bisect run aborted: %q exited with code %d on %s", strings.Join(command, " "), code, tested.Short(7))
+			}
+		}
+
+		step, err = Mark(repoPath, term, tested.String())
+		if err != nil {
+			return Step{}, err
+		}
+
+		if progress != nil {
+			progress(tested, term, step)
+		}
+	}
+
+	return step, nil
+}
+
+// next finds the commit that best splits the remaining candidates in two and checks it out.
+func next(repoPath string, st state) (Step, error) {
+	if st.bad == nil {
+		return Step{}, fmt.Errorf("no bad commit marked yet")
+	}
+
+	candidates, err := candidates(repoPath, st)
+	if err != nil {
+		return Step{}, err
+	}
+
+	skipped := hashSet(st.skips)
+	testable := []object.ObjectHash{}
+	for _, c := range candidates {
+		if _, ok := skipped[c.String()]; !ok {
+			testable = append(testable, c)
+		}
+	}
+
+	if len(testable) == 0 {
+		if len(candidates) > 0 {
+			return Step{Done: true, Candidates: append(candidates, st.bad)}, nil
+		}
+
+		if err := appendLog(repoPath, fmt.Sprintf("# first bad commit: %s", describe(repoPath, st.bad))); err != nil {
+			return Step{}, err
+		}
+		return Step{Done: true, FirstBad: st.bad}, nil
+	}
+
+	// the commits between good and bad, bad included
+	inRange := hashSet(candidates)
+	inRange[st.bad.String()] = st.bad
+	total := len(inRange)
+
+	reach, err := reachCounts(repoPath, inRange)
+	if err != nil {
+		return Step{}, err
+	}
+
+	var best object.ObjectHash
+	bestScore := -1
+	for _, c := range testable {
+		score := min(reach[c.String()], total-reach[c.String()])
+		if score > bestScore || (score == bestScore && c.String() < best.String()) {
+			best = c
+			bestScore = score
+		}
+	}
+
+	if _, err := worktree.RestoreCommitWorktree(repoPath, best, worktree.Options{Action: "bisect"}, journal.SetDetachedHead(best)); err != nil {
+		return Step{}, err
+	}
+
+	if err := writeHash(repoPath, expectedFile, best); err != nil {
+		return Step{}, err
+	}
+
+	steps := 0
+	for n := len(testable); n > 1; n /= 2 {
+		steps++
+	}
+
+	return Step{
+		Current:   best,
+		Remaining: len(testable),
+		Steps:     steps,
+	}, nil
+}
+
+// candidates returns the commits that are ancestors of bad but not of any good commit, bad excluded.
+func candidates(repoPath string, st state) ([]object.ObjectHash, error) {
+	goodAncestors := map[string]object.ObjectHash{}
+	for _, g := range st.goods {
+		if err := ancestors(repoPath, g, goodAncestors, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := goodAncestors[st.bad.String()]; ok {
+		return nil, fmt.Errorf("bad commit %s is an ancestor of a good commit", st.bad.Short(7))
+	}
+
+	badAncestors := map[string]object.ObjectHash{}
+	if err := ancestors(repoPath, st.bad, badAncestors, goodAncestors); err != nil {
+		return nil, err
+	}
+
+	out := []object.ObjectHash{}
+	for k, v := range badAncestors {
+		if k == st.bad.String() {
+			continue
+		}
+		out = append(out, v)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].String() < out[j].String()
+	})
+	return out, nil
+}
+
+// ancestors adds start and all its ancestors to m, not walking past commits in stop.
+func ancestors(repoPath string, start object.ObjectHash, m, stop map[string]object.ObjectHash) error {
+	queue := []object.ObjectHash{start}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+
+		if _, ok := m[c.String()]; ok {
+			continue
+		}
+		if _, ok := stop[c.String()]; ok {
+			continue
+		}
+
+		m[c.String()] = c
+		commit, err := object.ReadCommit(repoPath, c)
+		if err != nil {
+			return err
+		}
+
+		queue = append(queue, commit.ParentHashes()...)
+	}
+
+	return nil
+}
+
+// reachCounts returns how many commits of set each commit of set reaches
+// through commits of set, itself included. Every commit is read once, and parents
+// go before their children so each one gets the union of the sets its parents reach.
+func reachCounts(repoPath string, set map[string]object.ObjectHash) (map[string]int, error) {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pos := make(map[string]int, len(keys))
+	for i, k := range keys {
+		pos[k] = i
+	}
+
+	parents := make([][]int, len(keys))
+	children := make([][]int, len(keys))
+	for i, k := range keys {
+		commit, err := object.ReadCommit(repoPath, set[k])
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range commit.ParentHashes() {
+			if j, ok := pos[p.String()]; ok {
+				parents[i] = append(parents[i], j)
+				children[j] = append(children[j], i)
+			}
+		}
+	}
+
+	// the commits reached by each one, as a bit set indexed like keys
+	words := (len(keys) + 63) / 64
+	reached := make([][]uint64, len(keys))
+	waiting := make([]int, len(keys))
+	ready := []int{}
+	for i := range keys {
+		waiting[i] = len(parents[i])
+		if waiting[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	counts := make(map[string]int, len(keys))
+	for len(ready) > 0 {
+		i := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+
+		r := make([]uint64, words)
+		r[i/64] |= 1 << (i % 64)
+		for _, p := range parents[i] {
+			for w := range r {
+				r[w] |= reached[p][w]
+			}
+		}
+		reached[i] = r
+
+		n := 0
+		for _, w := range r {
+			n += bits.OnesCount64(w)
+		}
+		counts[keys[i]] = n
+
+		for _, c := range children[i] {
+			waiting[c]--
+			if waiting[c] == 0 {
+				ready = append(ready, c)
+			}
+		}
+	}
+
+	return counts, nil
+}
+
+// Commits returns the commits recorded by the session by state file name, the
+// ones gc must keep. It is empty when there is no session.
+func Commits(repoPath string) (map[string][]object.ObjectHash, error) {
+	commits := map[string][]object.ObjectHash{}
+	if !isBisecting(repoPath) {
+		return commits, nil
+	}
+
+	for _, f := range []string{startFile, badFile, goodFile, skipFile, expectedFile} {
+		hashes, err := readHashes(repoPath, f)
+		if err != nil {
+			return nil, err
+		}
+		if len(hashes) > 0 {
+			commits[f] = hashes
+		}
+	}
+	return commits, nil
+}
+
+func markedHash(repoPath, rev string) (object.ObjectHash, error) {
+	if len(rev) > 0 {
+		return revision.Resolve(repoPath, rev)
+	}
+
+	expected, err := readHashes(repoPath, expectedFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(expected) == 0 {
+		return nil, fmt.Errorf("no commit checked out by bisect, pass the commit to mark")
+	}
+
+	return expected[0], nil
+}
+
+func describe(repoPath string, hash object.ObjectHash) string {
+	c, err := object.ReadCommit(repoPath, hash)
+	if err != nil {
+		return fmt.Sprintf("[%s]", hash)
+	}
+
+	subject, _, _ := strings.Cut(c.Message(), "\n")
+	return fmt.Sprintf("[%s] %s", hash, subject)
+}
+
+func isBisecting(repoPath string) bool {
+	return utils.Exists(statePath(repoPath, startFile))
+}
+
+func loadState(repoPath string) (state, error) {
+	if !isBisecting(repoPath) {
+		return state{}, errNotBisecting
+	}
+
+	st := state{}
+
+	start, err := readHashes(repoPath, startFile)
+	if err != nil {
+		return state{}, err
+	}
+	if len(start) > 0 {
+		st.start = start[0]
+	}
+
+	bad, err := readHashes(repoPath, badFile)
+	if err != nil {
+		return state{}, err
+	}
+	if len(bad) > 0 {
+		st.bad = bad[0]
+	}
+
+	st.goods, err = readHashes(repoPath, goodFile)
+	if err != nil {
+		return state{}, err
+	}
+
+	st.skips, err = readHashes(repoPath, skipFile)
+	if err != nil {
+		return state{}, err
+	}
+
+	return st, nil
+}
+
+func saveState(repoPath string, st state) error {
+	if err := writeHash(repoPath, badFile, st.bad); err != nil {
+		return err
+	}
+
+	if err := writeHashes(repoPath, goodFile, st.goods); err != nil {
+		return err
+	}
+
+	return writeHashes(repoPath, skipFile, st.skips)
+}
+
+func readHashes(repoPath, name string) ([]object.ObjectHash, error) {
+	data, err := utils.ReadFile(statePath(repoPath, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []object.ObjectHash{}, nil
+		}
+		return nil, err
+	}
+
+	hashes := []object.ObjectHash{}
+	for _, l := range strings.Split(string(data), "\n") {
+		if len(strings.TrimSpace(l)) == 0 {
+			continue
+		}
+		h, err := object.NewObjectHash(l)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt bisect state %s: %w", name, err)
+		}
+		hashes = append(hashes, h)
+	}
+
+	return hashes, nil
+}
+
+func writeHash(repoPath, name string, hash object.ObjectHash) error {
+	return writeHashes(repoPath, name, []object.ObjectHash{hash})
+}
+
+func writeHashes(repoPath, name string, hashes []object.ObjectHash) error {
+	var sb strings.Builder
+	for _, h := range hashes {
+		sb.WriteString(h.String())
+		sb.WriteString("\n")
+	}
+	return utils.WriteFile(statePath(repoPath, name), []byte(sb.String()))
+}
+
+func appendLog(repoPath string, lines ...string) error {
+	f, err := os.OpenFile(statePath(repoPath, logFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, l := range lines {
+		if _, err := f.WriteString(l + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hashSet(hashes []object.ObjectHash) map[string]object.ObjectHash {
+	m := make(map[string]object.ObjectHash, len(hashes))
+	for _, h := range hashes {
+		m[h.String()] = h
+	}
+	return m
+}
+
+func statePath(repoPath, name string) string {
+	return filepath.Join(utils.GetRepoDir(repoPath), name)
+}
@@ -0,0 +1,178 @@
+package bisect
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/add"
+	"github.com/matiasmartin00/arbor/internal/commit"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/repo"
+)
+
+// newHistory creates a repository in a temporary directory, moves into it and
+// commits n versions of f, holding 0 to n-1. It returns the commits in order.
+func newHistory(t *testing.T, n int) []object.ObjectHash {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("ARBOR_CONFIG_USER__NAME", "T")
+	t.Setenv("ARBOR_CONFIG_USER__EMAIL", "t@x")
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+
+	commits := []object.ObjectHash{}
+	for i := range n {
+		if err := os.WriteFile("f", []byte(fmt.Sprintf("%d\n", i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := add.Add(".", false, []string{"f"}); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := commit.Commit(".", commit.CommitOptions{Message: fmt.Sprint(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits = append(commits, hash)
+	}
+	return commits
+}
+
+func indexOf(commits []object.ObjectHash, hash object.ObjectHash) int {
+	for i, c := range commits {
+		if c.Equals(hash) {
+			return i
+		}
+	}
+	return -1
+}
+
+// result returns the position of the first bad commit, or of the candidates in
+// order when skipped commits hide it.
+func result(commits []object.ObjectHash, step Step) []int {
+	got := []int{}
+	if step.FirstBad != nil {
+		got = append(got, indexOf(commits, step.FirstBad))
+	}
+	for _, c := range step.Candidates {
+		got = append(got, indexOf(commits, c))
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestNarrowing(t *testing.T) {
+	tests := []struct {
+		name     string
+		firstBad int
+		skip     []int
+		// want is the first bad commit, or the candidates when skipped commits hide it
+		want []int
+	}{
+		{"middle", 5, nil, []int{5}},
+		{"first", 1, nil, []int{1}},
+		{"last", 9, nil, []int{9}},
+		{"skipped around", 5, []int{4, 5}, []int{4, 5, 6}},
+		{"skipped elsewhere", 5, []int{2, 7}, []int{5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commits := newHistory(t, 10)
+			step, err := Start(".", commits[9].String(), []string{commits[0].String()})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for tests := 0; !step.Done; tests++ {
+				if tests > len(commits) {
+					t.Fatal("bisect does not converge")
+				}
+
+				i := indexOf(commits, step.Current)
+				term := Good
+				switch {
+				case slices.Contains(tt.skip, i):
+					term = Skip
+				case i >= tt.firstBad:
+					term = Bad
+				}
+				if step, err = Mark(".", term, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := result(commits, step); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartFailureLeavesNoSession(t *testing.T) {
+	commits := newHistory(t, 3)
+
+	// the bad commit is an ancestor of the good one
+	if _, err := Start(".", commits[0].String(), []string{commits[2].String()}); err == nil {
+		t.Fatal("started with a bad commit older than the good one")
+	}
+	if isBisecting(".") {
+		t.Fatal("the state of the failed start was left behind")
+	}
+
+	if _, err := Start(".", commits[2].String(), []string{commits[0].String()}); err != nil {
+		t.Fatalf("a new session can not start: %v", err)
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		// code is the exit code of the test on the commits from the first bad one on
+		code    int
+		want    []int
+		aborted bool
+	}{
+		{"bad", 1, []int{5}, false},
+		{"highest bad", 127, []int{5}, false},
+		{"skip", 125, []int{5, 6, 7, 8, 9}, false},
+		{"abort", 128, nil, true},
+		{"abort high", 255, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commits := newHistory(t, 10)
+			if _, err := Start(".", commits[9].String(), []string{commits[0].String()}); err != nil {
+				t.Fatal(err)
+			}
+
+			// the first bad commit fails with code, the later ones with 1
+			script := fmt.Sprintf(`n=$(cat f); [ "$n" -eq 5 ] && exit %d; [ "$n" -gt 5 ] && exit 1; exit 0`, tt.code)
+			if tt.code == 125 {
+				script = `n=$(cat f); [ "$n" -ge 5 ] && exit 125; exit 0`
+			}
+
+			step, err := Run(".", []string{"sh", "-c", script}, nil)
+			if tt.aborted {
+				if err == nil || !strings.Contains(err.Error(), "aborted") {
+					t.Fatalf("got %v, want the run aborted", err)
+				}
+				if !isBisecting(".") {
+					t.Error("an aborted run ended the session")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := result(commits, step); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package blame
+
+import (
+	"container/heap"
+	"fmt"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/diff"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/revision"
+)
+
+type BlameLine struct {
+	Hash    object.ObjectHash
+	Author  string
+	Email   string
+	Date    time.Time
+	Summary string
+	// OrigLine is the line number (1-based) in the commit that introduced the line
+	OrigLine int
+	// FinalLine is the line number (1-based) in the blamed revision
+	FinalLine int
+	Content   string
+}
+
+type BlameResult struct {
+	Path  string
+	Lines []BlameLine
+}
+
+// suspect is a commit with the lines it may be blamed for.
+// lines maps a line index in the commit version of the file to the final line index.
+type suspect struct {
+	commit object.Commit
+	lines  map[int]int
+}
+
+// Blame annotates each line of path at rev with the commit that last changed it.
+// History is walked from rev back through parents, newest commit first, passing each
+// line to the parent that already had it. start and end (1-based, inclusive) restrict
+// the lines to annotate, 0 means from the first line or up to the last line.
+func Blame(repoPath, rev, path string, start, end int) (BlameResult, error) {
+	if len(rev) == 0 {
+		rev = "HEAD"
+	}
+
+	hash, err := revision.Resolve(repoPath, rev)
+	if err != nil {
+		return BlameResult{}, err
+	}
+
+	b := &blamer{
+		repoPath: repoPath,
+		path:     path,
+		contents: map[string][]string{},
+		pending:  map[string]*suspect{},
+		queue:    &suspectQueue{},
+	}
+
+	head, err := object.ReadCommit(repoPath, hash)
+	if err != nil {
+		return BlameResult{}, err
+	}
+
+	finalLines, _, err := b.fileLines(head)
+	if err != nil {
+		return BlameResult{}, err
+	}
+
+	if finalLines == nil {
+		return BlameResult{}, fmt.Errorf("no such path '%s' in %s", path, rev)
+	}
+
+	if len(finalLines) == 0 {
+		return BlameResult{Path: path, Lines: []BlameLine{}}, nil
+	}
+
+	if start <= 0 {
+		start = 1
+	}
+	if end <= 0 || end > len(finalLines) {
+		end = len(finalLines)
+	}
+	if start > end {
+		return BlameResult{}, fmt.Errorf("invalid line range %d,%d: file has %d lines", start, end, len(finalLines))
+	}
+
+	tracked := map[int]int{}
+	for i := start - 1; i < end; i++ {
+		tracked[i] = i
+	}
+
+	out := make([]BlameLine, len(finalLines))
+	b.add(head, tracked)
+
+	for b.queue.Len() > 0 {
+		c := heap.Pop(b.queue).(object.Commit)
+		s := b.pending[c.Hash().String()]
+		delete(b.pending, c.Hash().String())
+
+		blamed, err := b.passToParents(s)
+		if err != nil {
+			return BlameResult{}, err
+		}
+
+		for idx, final := range blamed {
+			out[final] = BlameLine{
+				Hash:      c.Hash(),
+				Author:    c.Author(),
+				Email:     c.Email(),
+				Date:      c.Timestamp(),
+				Summary:   subject(c.Message()),
+				OrigLine:  idx + 1,
+				FinalLine: final + 1,
+				Content:   finalLines[final],
+			}
+		}
+	}
+
+	return BlameResult{
+		Path:  path,
+		Lines: out[start-1 : end],
+	}, nil
+}
+
+type blamer struct {
+	repoPath string
+	path     string
+	// contents caches file lines by blob hash
+	contents map[string][]string
+	pending  map[string]*suspect
+	queue    *suspectQueue
+}
+
+// add gives lines to a commit, queueing it if it was not waiting already.
+func (b *blamer) add(c object.Commit, lines map[int]int) {
+	if len(lines) == 0 {
+		return
+	}
+
+	s, ok := b.pending[c.Hash().String()]
+	if !ok {
+		s = &suspect{commit: c, lines: map[int]int{}}
+		b.pending[c.Hash().String()] = s
+		heap.Push(b.queue, c)
+	}
+
+	for idx, final := range lines {
+		s.lines[idx] = final
+	}
+}
+
+// passToParents hands every line that a parent already had over to that parent,
+// and returns the ones left, which were introduced by the commit itself.
+func (b *blamer) passToParents(s *suspect) (map[int]int, error) {
+	lines, blobHash, err := b.fileLines(s.commit)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := s.lines
+	for _, ph := range s.commit.ParentHashes() {
+		if len(remaining) == 0 {
+			break
+		}
+
+		parent, err := object.ReadCommit(b.repoPath, ph)
+		if err != nil {
+			return nil, err
+		}
+
+		parentLines, parentBlob, err := b.fileLines(parent)
+		if err != nil {
+			return nil, err
+		}
+
+		if parentLines == nil {
+			continue
+		}
+
+		// same content, every line comes from the parent
+		if parentBlob.Equals(blobHash) {
+			b.add(parent, remaining)
+			remaining = map[int]int{}
+			break
+		}
+
+		passed := map[int]int{}
+		left := map[int]int{}
+		toParent := matchingLines(parentLines, lines)
+		for idx, final := range remaining {
+			if pIdx, ok := toParent[idx]; ok {
+				passed[pIdx] = final
+				continue
+			}
+			left[idx] = final
+		}
+
+		b.add(parent, passed)
+		remaining = left
+	}
+
+	return remaining, nil
+}
+
+// fileLines returns the lines of the blamed path in a commit, nil if the file is not there.
+func (b *blamer) fileLines(c object.Commit) ([]string, object.ObjectHash, error) {
+	entry, err := object.FindTreeEntry(b.repoPath, c.TreeHash(), b.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if entry == nil || entry.Type != object.BlobType {
+		return nil, nil, nil
+	}
+
+	if lines, ok := b.contents[entry.Hash.String()]; ok {
+		return lines, entry.Hash, nil
+	}
+
+	blob, err := object.ReadBlob(b.repoPath, entry.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lines, err := blob.SplitLines()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if lines == nil {
+		lines = []string{}
+	}
+
+	b.contents[entry.Hash.String()] = lines
+	return lines, entry.Hash, nil
+}
+
+// matchingLines maps line indexes of b to the index of the same line in a,
+// for the lines left untouched by the diff from a to b.
+func matchingLines(a, b []string) map[int]int {
+	m := map[int]int{}
+	i, j := 0, 0
+	for _, ld := range diff.DiffLines(a, b) {
+		switch ld.Result {
+		case diff.EqLine:
+			m[j] = i
+			i++
+			j++
+		case diff.RemovedLine:
+			i++
+		case diff.AddedLine:
+			j++
+		}
+	}
+	return m
+}
+
+func subject(message string) string {
+	for i, r := range message {
+		if r == '\n' {
+			return message[:i]
+		}
+	}
+	return message
+}
+
+// suspectQueue is a priority queue of commits, the most recent commit first,
+// so children are usually processed before their parents.
+type suspectQueue []object.Commit
+
+func (q suspectQueue) Len() int {
+	return len(q)
+}
+
+func (q suspectQueue) Less(i, j int) bool {
+	ti, tj := q[i].CommitTime(), q[j].CommitTime()
+	if ti.Equal(tj) {
+		return q[i].Hash().String() < q[j].Hash().String()
+	}
+	return ti.After(tj)
+}
+
+func (q suspectQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *suspectQueue) Push(x any) {
+	*q = append(*q, x.(object.Commit))
+}
+
+func (q *suspectQueue) Pop() any {
+	old := *q
+	n := len(old)
+	c := old[n-1]
+	*q = old[:n-1]
+	return c
+}
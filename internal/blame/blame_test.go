@@ -0,0 +1,165 @@
+package blame
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
+	"github.com/matiasmartin00/arbor/internal/repo"
+)
+
+// history writes commits of the single file f in a new repository, main
+// pointing to the last one written.
+type history struct {
+	t       *testing.T
+	commits map[string]object.ObjectHash
+}
+
+// newHistory creates a repository in a temporary directory and moves into it.
+func newHistory(t *testing.T) *history {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+	return &history{t: t, commits: map[string]object.ObjectHash{}}
+}
+
+// commit writes the commit name by author with f holding content.
+func (h *history) commit(name, author, content string, parents ...string) {
+	h.t.Helper()
+	blob, err := object.WriteBlobData(".", []byte(content))
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	tree, err := object.WriteTree(".", map[string]object.ObjectHash{"f": blob}, nil)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	parentHashes := []object.ObjectHash{}
+	for _, p := range parents {
+		parentHashes = append(parentHashes, h.commits[p])
+	}
+	sig := object.Signature{Name: author, Email: author + "@x", When: time.Unix(int64(len(h.commits)+1), 0).UTC()}
+	hash, err := object.WriteCommit(".", tree, parentHashes, sig, sig, name+"\n\nbody")
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	h.commits[name] = hash
+
+	if err := refs.CreateRef(".", "main", hash); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// annotations renders the lines as "<commit> <orig line> <content>".
+func (h *history) annotations(lines []BlameLine) string {
+	names := map[string]string{}
+	for name, hash := range h.commits {
+		names[hash.String()] = name
+	}
+
+	out := []string{}
+	for _, l := range lines {
+		out = append(out, strings.Join([]string{names[l.Hash.String()], strconv.Itoa(l.OrigLine), strconv.Itoa(l.FinalLine), l.Content}, " "))
+	}
+	return strings.Join(out, "\n")
+}
+
+func TestBlame(t *testing.T) {
+	h := newHistory(t)
+	h.commit("one", "alice", "a\nb\nc\n")
+	h.commit("two", "bob", "a\nB\nc\nd\n", "one")
+	h.commit("three", "carol", "x\na\nB\nc\nd\n", "two")
+
+	tests := []struct {
+		name       string
+		rev        string
+		start, end int
+		want       []string
+	}{
+		{"whole file", "", 0, 0, []string{
+			"three 1 1 x",
+			"one 1 2 a",
+			"two 2 3 B",
+			"one 3 4 c",
+			"two 4 5 d",
+		}},
+		{"line range", "HEAD", 2, 3, []string{
+			"one 1 2 a",
+			"two 2 3 B",
+		}},
+		{"open range", "", 4, 0, []string{
+			"one 3 4 c",
+			"two 4 5 d",
+		}},
+		{"older revision", "HEAD~1", 0, 0, []string{
+			"one 1 1 a",
+			"two 2 2 B",
+			"one 3 3 c",
+			"two 4 4 d",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Blame(".", tt.rev, "f", tt.start, tt.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := h.annotations(result.Lines), strings.Join(tt.want, "\n"); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+
+	result, err := Blame(".", "", "f", 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := result.Lines[0]; l.Author != "carol" || l.Email != "carol@x" || l.Summary != "three" || l.Date.Unix() != 3 {
+		t.Errorf("got %s <%s> at %v with summary %q", l.Author, l.Email, l.Date, l.Summary)
+	}
+
+	invalid := []struct {
+		path, rev  string
+		start, end int
+	}{
+		{"missing", "", 0, 0},
+		{"f", "", 4, 2},
+		{"f", "unknown", 0, 0},
+	}
+	for _, tt := range invalid {
+		if _, err := Blame(".", tt.rev, tt.path, tt.start, tt.end); err == nil {
+			t.Errorf("blamed %s at %q lines %d,%d", tt.path, tt.rev, tt.start, tt.end)
+		}
+	}
+}
+
+func TestBlameMerge(t *testing.T) {
+	h := newHistory(t)
+	h.commit("base", "alice", "a\n")
+	h.commit("left", "alice", "a\nb\n", "base")
+	h.commit("right", "bob", "c\na\n", "base")
+	h.commit("merge", "carol", "c\na\nb\nm\n", "left", "right")
+
+	result, err := Blame(".", "", "f", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each line goes to the side of the merge that brought it
+	want := strings.Join([]string{
+		"right 1 1 c",
+		"base 1 2 a",
+		"left 2 3 b",
+		"merge 4 4 m",
+	}, "\n")
+	if got := h.annotations(result.Lines); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package branch
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -15,6 +16,9 @@ var (
 	errInvalidBranchName = fmt.Errorf("invalid branch name")
 	errBranchExists      = fmt.Errorf("branch already exists")
 	errNoCommits         = fmt.Errorf("no commits yet")
+
+	// ErrDetachedHead is returned by GetCurrentBranch when HEAD points to a commit
+	ErrDetachedHead = fmt.Errorf("HEAD is detached, not on any branch")
 )
 
 type BranchData struct {
@@ -23,8 +27,8 @@ type BranchData struct {
 }
 
 func CreateBranch(repoPath, name string) error {
-	if strings.Contains(name, "/") {
-		return errInvalidBranchName
+	if err := CheckNewBranch(repoPath, name); err != nil {
+		return err
 	}
 
 	// get current commit hash
@@ -37,11 +41,6 @@ func CreateBranch(repoPath, name string) error {
 		return errNoCommits
 	}
 
-	// check if branch exists
-	if refs.ExistsRef(repoPath, name) {
-		return errBranchExists
-	}
-
 	// create ref
 	if err := refs.CreateRef(repoPath, name, hash); err != nil {
 		return err
@@ -50,6 +49,19 @@ func CreateBranch(repoPath, name string) error {
 	return nil
 }
 
+// CheckNewBranch fails when name is not valid or the branch already exists.
+func CheckNewBranch(repoPath, name string) error {
+	if strings.Contains(name, "/") || strings.HasPrefix(name, utils.TempPrefix) {
+		return errInvalidBranchName
+	}
+
+	// check if branch exists
+	if refs.ExistsRef(repoPath, name) {
+		return errBranchExists
+	}
+	return nil
+}
+
 // listBranches returns a list of branch names and mark the current one with '*'
 func ListBranches(repoPath string) ([]BranchData, error) {
 	refsDir := utils.GetRefsDir(repoPath)
@@ -63,7 +75,8 @@ func ListBranches(repoPath string) ([]BranchData, error) {
 			return err
 		}
 
-		if d.IsDir() {
+		// a write interrupted by a crash may leave a temporary file behind
+		if d.IsDir() || strings.HasPrefix(d.Name(), utils.TempPrefix) {
 			return nil
 		}
 
@@ -79,8 +92,9 @@ func ListBranches(repoPath string) ([]BranchData, error) {
 		return nil, err
 	}
 
+	// no branch is active when HEAD is detached
 	current, err := GetCurrentBranch(repoPath)
-	if err != nil {
+	if err != nil && !errors.Is(err, ErrDetachedHead) {
 		return nil, err
 	}
 
@@ -93,6 +107,7 @@ func ListBranches(repoPath string) ([]BranchData, error) {
 	return branches, nil
 }
 
+// GetCurrentBranch returns the branch HEAD points to, or ErrDetachedHead.
 func GetCurrentBranch(repoPath string) (string, error) {
 	headRaw, err := refs.GetHEAD(repoPath)
 	if err != nil {
@@ -100,7 +115,7 @@ func GetCurrentBranch(repoPath string) (string, error) {
 	}
 
 	if !refs.IsRef(headRaw) {
-		return "", nil
+		return "", ErrDetachedHead
 	}
 
 	return strings.TrimPrefix(headRaw, "refs/heads/"), nil
@@ -1,29 +1,196 @@
 package checkout
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/branch"
+	"github.com/matiasmartin00/arbor/internal/hooks"
+	"github.com/matiasmartin00/arbor/internal/journal"
 	"github.com/matiasmartin00/arbor/internal/object"
 	"github.com/matiasmartin00/arbor/internal/refs"
+	"github.com/matiasmartin00/arbor/internal/revision"
 	"github.com/matiasmartin00/arbor/internal/worktree"
 )
 
-func Checkout(repoPath, commitHashOrRef string) error {
-	if refs.ExistsRef(repoPath, commitHashOrRef) {
-		hash, err := refs.GetRefHashByName(repoPath, commitHashOrRef)
+type CheckoutOptions struct {
+	// Force discards the local changes
+	Force bool
+	// Merge merges the local changes into the files that change
+	Merge bool
+}
+
+type SwitchOptions struct {
+	CheckoutOptions
+	// Create makes the branch, starting at StartPoint or HEAD when empty
+	Create     bool
+	StartPoint string
+}
+
+type CheckoutResult struct {
+	// Conflicts are the files whose local changes were merged with conflicts
+	Conflicts []string
+	// Detached is the commit HEAD now points to, nil when it is on a branch
+	Detached object.ObjectHash
+	// Left are the commits of the detached HEAD that no branch reaches, newest first
+	Left []object.ObjectHash
+}
+
+// Checkout moves to a branch, or detaches HEAD at any other revision, carrying
+// the local changes over as opts says. HEAD stays on the current branch.
+func Checkout(repoPath, target string, opts CheckoutOptions) (CheckoutResult, error) {
+	if target == "HEAD" {
+		current, err := branch.GetCurrentBranch(repoPath)
+		if err != nil && !errors.Is(err, branch.ErrDetachedHead) {
+			return CheckoutResult{}, err
+		}
+		if err == nil {
+			target = current
+		}
+	}
+
+	if refs.ExistsRef(repoPath, target) {
+		hash, err := refs.GetRefHashByName(repoPath, target)
 		if err != nil {
-			return err
+			return CheckoutResult{}, err
 		}
+		return move(repoPath, hash, target, opts, journal.SetHead(target))
+	}
+
+	hash, err := revision.Resolve(repoPath, target)
+	if err != nil {
+		return CheckoutResult{}, err
+	}
 
-		if err := worktree.RestoreCommitWorktree(repoPath, hash); err != nil {
-			return err
+	result, err := move(repoPath, hash, target, opts, journal.SetDetachedHead(hash))
+	if err != nil {
+		return CheckoutResult{}, err
+	}
+	result.Detached = hash
+	return result, nil
+}
+
+// Switch moves to a branch, unlike Checkout it never detaches HEAD.
+func Switch(repoPath, name string, opts SwitchOptions) (CheckoutResult, error) {
+	if !opts.Create {
+		if refs.NotExistsRef(repoPath, name) {
+			return CheckoutResult{}, fmt.Errorf("branch %s does not exist, use `arbor checkout` to detach HEAD at a commit", name)
 		}
+		return Checkout(repoPath, name, opts.CheckoutOptions)
+	}
+
+	if err := branch.CheckNewBranch(repoPath, name); err != nil {
+		return CheckoutResult{}, err
+	}
 
-		return refs.UpdateHEAD(repoPath, commitHashOrRef)
+	start := opts.StartPoint
+	if len(start) == 0 {
+		start = "HEAD"
 	}
+	hash, err := revision.Resolve(repoPath, start)
+	if err != nil {
+		return CheckoutResult{}, err
+	}
+
+	// the branch is created along with the checkout, or not at all
+	return move(repoPath, hash, name, opts.CheckoutOptions, journal.SetBranch(name, hash), journal.SetHead(name))
+}
 
-	hash, err := object.NewObjectHash(commitHashOrRef)
+// move checks out the commit hash with the ref updates and runs the
+// post-checkout hook.
+func move(repoPath string, hash object.ObjectHash, name string, opts CheckoutOptions, updates ...journal.RefUpdate) (CheckoutResult, error) {
+	prevHash, err := refs.GetRefHash(repoPath)
 	if err != nil {
-		return err
+		return CheckoutResult{}, err
 	}
 
-	return worktree.RestoreCommitWorktree(repoPath, hash)
+	_, err = branch.GetCurrentBranch(repoPath)
+	wasDetached := errors.Is(err, branch.ErrDetachedHead)
+	if err != nil && !wasDetached {
+		return CheckoutResult{}, err
+	}
+
+	conflicts, err := worktree.RestoreCommitWorktree(repoPath, hash, worktree.Options{
+		Action:     "checkout",
+		Force:      opts.Force,
+		Merge:      opts.Merge,
+		TargetName: name,
+	}, updates...)
+	if err != nil {
+		return CheckoutResult{}, err
+	}
+
+	result := CheckoutResult{Conflicts: conflicts}
+	if wasDetached && prevHash != nil {
+		result.Left, err = unreachable(repoPath, prevHash, hash)
+		if err != nil {
+			return CheckoutResult{}, err
+		}
+	}
+
+	// "1" tells the hook that a branch was checked out
+	flag := "0"
+	if refs.ExistsRef(repoPath, name) {
+		flag = "1"
+	}
+	hooks.RunPost(repoPath, hooks.PostCheckout, hookHash(prevHash), hookHash(hash), flag)
+	return result, nil
+}
+
+// unreachable lists the commits of from that neither the branches nor to reach,
+// newest first.
+func unreachable(repoPath string, from, to object.ObjectHash) ([]object.ObjectHash, error) {
+	branches, err := branch.ListBranches(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := []object.ObjectHash{to}
+	for _, b := range branches {
+		h, err := refs.GetRefHashByName(repoPath, b.Name)
+		if err != nil {
+			return nil, err
+		}
+		if h != nil {
+			kept = append(kept, h)
+		}
+	}
+
+	seen := map[string]bool{}
+	if _, err := walk(repoPath, kept, seen); err != nil {
+		return nil, err
+	}
+	return walk(repoPath, []object.ObjectHash{from}, seen)
+}
+
+// walk returns the commits reachable from starts that are not in seen, and adds
+// them to it.
+func walk(repoPath string, starts []object.ObjectHash, seen map[string]bool) ([]object.ObjectHash, error) {
+	found := []object.ObjectHash{}
+	queue := append([]object.ObjectHash{}, starts...)
+	for len(queue) > 0 {
+		h := queue[0]
+		queue = queue[1:]
+		if seen[h.String()] {
+			continue
+		}
+		seen[h.String()] = true
+		found = append(found, h)
+
+		c, err := object.ReadCommit(repoPath, h)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, c.ParentHashes()...)
+	}
+	return found, nil
+}
+
+// hookHash returns the hash as given to hooks, all zeros when there is no commit.
+func hookHash(hash object.ObjectHash) string {
+	if hash == nil {
+		return strings.Repeat("0", 40)
+	}
+	return hash.String()
 }
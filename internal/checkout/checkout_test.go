@@ -0,0 +1,247 @@
+package checkout
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/add"
+	"github.com/matiasmartin00/arbor/internal/branch"
+	"github.com/matiasmartin00/arbor/internal/commit"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
+	"github.com/matiasmartin00/arbor/internal/repo"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// newRepo creates a repository in a temporary directory and moves into it.
+func newRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("ARBOR_CONFIG_USER__NAME", "T")
+	t.Setenv("ARBOR_CONFIG_USER__EMAIL", "t@x")
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commitFile writes and stages f with content and commits it.
+func commitFile(t *testing.T, content string) object.ObjectHash {
+	t.Helper()
+	if err := os.WriteFile("f", []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := add.Add(".", false, []string{"f"}); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := commit.Commit(".", commit.CommitOptions{Message: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func assertHead(t *testing.T, wantBranch string, wantHash object.ObjectHash) {
+	t.Helper()
+	name, err := branch.GetCurrentBranch(".")
+	switch {
+	case len(wantBranch) == 0 && !errors.Is(err, branch.ErrDetachedHead):
+		t.Errorf("HEAD is on %q (%v), want it detached", name, err)
+	case len(wantBranch) > 0 && (err != nil || name != wantBranch):
+		t.Errorf("HEAD is on %q (%v), want %s", name, err, wantBranch)
+	}
+
+	hash, err := refs.GetRefHash(".")
+	if err != nil || !hash.Equals(wantHash) {
+		t.Errorf("HEAD is at %v (%v), want %s", hash, err, wantHash)
+	}
+}
+
+func TestCheckoutDetachesHead(t *testing.T) {
+	newRepo(t)
+	first := commitFile(t, "one\n")
+	second := commitFile(t, "two\n")
+
+	result, err := Checkout(".", first.String(), CheckoutOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Detached.Equals(first) {
+		t.Errorf("detached at %v, want %s", result.Detached, first)
+	}
+	assertHead(t, "", first)
+
+	// a commit on the detached HEAD moves HEAD and leaves main alone
+	detached := commitFile(t, "detached\n")
+	assertHead(t, "", detached)
+	if h, err := refs.GetRefHashByName(".", "main"); err != nil || !h.Equals(second) {
+		t.Errorf("main is at %v (%v), want %s", h, err, second)
+	}
+
+	// leaving it reports the commit no branch reaches
+	result, err = Switch(".", "main", SwitchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Left) != 1 || !result.Left[0].Equals(detached) {
+		t.Errorf("left %v, want %s", result.Left, detached)
+	}
+	assertHead(t, "main", second)
+	if data, _ := os.ReadFile("f"); string(data) != "two\n" {
+		t.Errorf("f holds %q, want two", data)
+	}
+}
+
+func TestCheckoutHeadKeepsBranch(t *testing.T) {
+	newRepo(t)
+	first := commitFile(t, "one\n")
+
+	result, err := Checkout(".", "HEAD", CheckoutOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Detached != nil {
+		t.Errorf("detached at %s, want HEAD kept on main", result.Detached)
+	}
+	assertHead(t, "main", first)
+
+	// a detached HEAD stays detached
+	second := commitFile(t, "two\n")
+	if _, err := Checkout(".", first.String(), CheckoutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	result, err = Checkout(".", "HEAD", CheckoutOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Detached.Equals(first) {
+		t.Errorf("detached at %v, want %s", result.Detached, first)
+	}
+	assertHead(t, "", first)
+	if h, err := refs.GetRefHashByName(".", "main"); err != nil || !h.Equals(second) {
+		t.Errorf("main is at %v (%v), want %s", h, err, second)
+	}
+}
+
+func TestSwitch(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		opts   SwitchOptions
+		branch string
+		at     int
+		err    string
+	}{
+		{name: "branch", target: "topic", branch: "topic", at: 0},
+		{name: "missing branch", target: "nope", err: "branch nope does not exist"},
+		{name: "commit", target: "HEAD~1", err: "branch HEAD~1 does not exist"},
+		{name: "create", target: "new", opts: SwitchOptions{Create: true}, branch: "new", at: 1},
+		{name: "create at", target: "new", opts: SwitchOptions{Create: true, StartPoint: "HEAD~1"}, branch: "new", at: 0},
+		{name: "create existing", target: "topic", opts: SwitchOptions{Create: true}, err: "already exists"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newRepo(t)
+			commits := []object.ObjectHash{commitFile(t, "one\n")}
+			if err := branch.CreateBranch(".", "topic"); err != nil {
+				t.Fatal(err)
+			}
+			commits = append(commits, commitFile(t, "two\n"))
+
+			result, err := Switch(".", tt.target, tt.opts)
+			if len(tt.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %v, want %q", err, tt.err)
+				}
+				assertHead(t, "main", commits[1])
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Detached != nil || len(result.Left) > 0 {
+				t.Errorf("got %+v, want HEAD on a branch", result)
+			}
+			assertHead(t, tt.branch, commits[tt.at])
+		})
+	}
+}
+
+func TestPostCheckoutHook(t *testing.T) {
+	newRepo(t)
+	first := commitFile(t, "one\n")
+	second := commitFile(t, "two\n")
+
+	hooksDir := filepath.Join(utils.GetRepoDir("."), "hooks")
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	hook := "#!/bin/sh\necho \"$1 $2 $3\" >> \"$ARBOR_DIR/checkouts\"\n"
+	if err := os.WriteFile(filepath.Join(hooksDir, "post-checkout"), []byte(hook), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Checkout(".", first.String(), CheckoutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Checkout(".", "main", CheckoutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(utils.GetRepoDir("."), "checkouts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := second.String() + " " + first.String() + " 0\n" + first.String() + " " + second.String() + " 1\n"
+	if string(data) != want {
+		t.Errorf("the hook got\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestCheckoutRestoresModes(t *testing.T) {
+	newRepo(t)
+	commitFile(t, "one\n")
+	if err := branch.CreateBranch(".", "plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile("run.sh", []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("run.sh", "link"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := add.Add(".", false, []string{"run.sh", "link"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := commit.Commit(".", commit.CommitOptions{Message: "modes"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Checkout(".", "plain", CheckoutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"run.sh", "link"} {
+		if _, err := os.Lstat(p); !os.IsNotExist(err) {
+			t.Errorf("%s is left from main", p)
+		}
+	}
+
+	if _, err := Checkout(".", "main", CheckoutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat("run.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0o111 == 0 {
+		t.Errorf("run.sh has mode %v, want it executable", info.Mode())
+	}
+	if target, err := os.Readlink("link"); err != nil || target != "run.sh" {
+		t.Errorf("link points to %q (%v), want run.sh", target, err)
+	}
+}
@@ -0,0 +1,56 @@
+// Package color paints terminal output with ANSI escape codes.
+package color
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/config"
+)
+
+const (
+	Reset  = "\x1b[m"
+	Bold   = "\x1b[1m"
+	Red    = "\x1b[31m"
+	Green  = "\x1b[32m"
+	Yellow = "\x1b[33m"
+	Cyan   = "\x1b[36m"
+)
+
+// modes of --color and color.ui
+const (
+	ModeAuto   = "auto"
+	ModeAlways = "always"
+	ModeNever  = "never"
+)
+
+// Enabled reports if output is colored for mode, or color.ui when mode is empty.
+// auto (the default) colors only when the output is a terminal.
+func Enabled(repoPath, mode string, terminal bool) (bool, error) {
+	if len(mode) == 0 {
+		v, err := config.GetDefault(repoPath, "color.ui", ModeAuto)
+		if err != nil {
+			return false, err
+		}
+		mode = v
+	}
+
+	switch strings.ToLower(mode) {
+	case ModeAlways, "true", "yes", "on":
+		return true, nil
+	case ModeNever, "false", "no", "off":
+		return false, nil
+	case ModeAuto:
+		return terminal, nil
+	default:
+		return false, fmt.Errorf("invalid color mode %q, expected auto, always or never", mode)
+	}
+}
+
+// Paint wraps s in the escape code when on, an empty code leaves s as it is.
+func Paint(on bool, code, s string) string {
+	if !on || len(code) == 0 || len(s) == 0 {
+		return s
+	}
+	return code + s + Reset
+}
@@ -1,9 +1,17 @@
 package commit
 
 import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/config"
+	"github.com/matiasmartin00/arbor/internal/date"
+	"github.com/matiasmartin00/arbor/internal/hooks"
 	"github.com/matiasmartin00/arbor/internal/object"
 	"github.com/matiasmartin00/arbor/internal/refs"
 	"github.com/matiasmartin00/arbor/internal/tree"
+	"github.com/matiasmartin00/arbor/internal/utils"
 )
 
 const (
@@ -13,7 +21,41 @@ const (
 	HeaderCommitter = "committer"
 )
 
-func Commit(repoPath, message string) (object.ObjectHash, error) {
+var (
+	errEmptyMessage     = fmt.Errorf("aborting commit due to empty commit message")
+	errNothingToCommit  = fmt.Errorf("nothing to commit, the tree is the same as the parent's (use --allow-empty to commit anyway)")
+	errAmendNoCommit    = fmt.Errorf("nothing to amend, there are no commits yet")
+	errAmendWhileMerges = fmt.Errorf("a merge is in progress, commit it before amending")
+)
+
+type CommitOptions struct {
+	Message string
+	// Author overrides the configured author, as "Name <email>"
+	Author string
+	// Date overrides the author date, see date.Parse for the accepted formats
+	Date string
+	// Amend replaces the tip commit instead of adding a new one on top of it
+	Amend bool
+	// AllowEmpty allows a commit whose tree is the same as its parent's
+	AllowEmpty bool
+	// NoVerify skips the pre-commit and commit-msg hooks
+	NoVerify bool
+}
+
+func Commit(repoPath string, opts CommitOptions) (object.ObjectHash, error) {
+	opts.Message = CleanupMessage(opts.Message, false)
+	if len(opts.Message) == 0 {
+		return nil, errEmptyMessage
+	}
+
+	if !opts.NoVerify {
+		msg, err := runPreCommitHooks(repoPath, opts.Message)
+		if err != nil {
+			return nil, err
+		}
+		opts.Message = msg
+	}
+
 	// write tree
 	treeHash, err := tree.WriteTree(repoPath)
 
@@ -22,13 +64,65 @@ func Commit(repoPath, message string) (object.ObjectHash, error) {
 	}
 
 	// get parent
-	parentHash, err := refs.GetRefHash(repoPath)
+	headHash, err := refs.GetRefHash(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// a pending merge adds the merged commit as second parent
+	mergeHash, err := refs.GetMergeHead(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var amended object.Commit
+	parents := []object.ObjectHash{}
+	switch {
+	case opts.Amend:
+		if headHash == nil {
+			return nil, errAmendNoCommit
+		}
+		if mergeHash != nil {
+			return nil, errAmendWhileMerges
+		}
+
+		// the amended commit takes the place of HEAD, so it keeps HEAD's parents
+		amended, err = object.ReadCommit(repoPath, headHash)
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, amended.ParentHashes()...)
+	case headHash != nil:
+		parents = append(parents, headHash)
+	}
+
+	if mergeHash != nil {
+		parents = append(parents, mergeHash)
+	}
+
+	// merge commits are recorded even if they leave the tree untouched
+	if !opts.AllowEmpty && mergeHash == nil {
+		empty, err := isEmptyCommit(repoPath, treeHash, parents)
+		if err != nil {
+			return nil, err
+		}
+		if empty {
+			return nil, errNothingToCommit
+		}
+	}
+
+	author, err := commitAuthor(repoPath, opts, amended)
+	if err != nil {
+		return nil, err
+	}
+
+	committer, err := config.Committer(repoPath)
 	if err != nil {
 		return nil, err
 	}
 
 	// write commit object
-	commitHash, err := object.WriteCommit(repoPath, treeHash, parentHash, message)
+	commitHash, err := object.WriteCommit(repoPath, treeHash, parents, author, committer, opts.Message)
 	if err != nil {
 		return nil, err
 	}
@@ -38,5 +132,99 @@ func Commit(repoPath, message string) (object.ObjectHash, error) {
 		return nil, err
 	}
 
+	if err := refs.ClearMergeHead(repoPath); err != nil {
+		return nil, err
+	}
+
+	hooks.RunPost(repoPath, hooks.PostCommit)
+
 	return commitHash, nil
 }
+
+// runPreCommitHooks runs the pre-commit and commit-msg hooks, and returns the
+// message as left by commit-msg.
+func runPreCommitHooks(repoPath, message string) (string, error) {
+	if err := hooks.Run(repoPath, hooks.PreCommit); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(utils.GetRepoDir(repoPath), editMsgFile)
+	if err := utils.WriteFile(path, []byte(message+"\n")); err != nil {
+		return "", err
+	}
+
+	// the hook gets a path relative to the repository root, where it runs
+	if err := hooks.Run(repoPath, hooks.CommitMsg, filepath.Join(utils.GetRepoDir(""), editMsgFile)); err != nil {
+		return "", err
+	}
+
+	data, err := utils.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	msg := CleanupMessage(string(data), false)
+	if len(msg) == 0 {
+		return "", errEmptyMessage
+	}
+	return msg, nil
+}
+
+// isEmptyCommit reports if treeHash is the same as the first parent's tree,
+// or the empty tree for a root commit.
+func isEmptyCommit(repoPath string, treeHash object.ObjectHash, parents []object.ObjectHash) (bool, error) {
+	if len(parents) == 0 {
+		entries, err := object.ReadTreeEntries(repoPath, treeHash)
+		if err != nil {
+			return false, err
+		}
+		return len(entries) == 0, nil
+	}
+
+	parent, err := object.ReadCommit(repoPath, parents[0])
+	if err != nil {
+		return false, err
+	}
+
+	return parent.TreeHash().Equals(treeHash), nil
+}
+
+// commitAuthor returns the configured author, or the author of the amended commit,
+// with the overrides of opts applied.
+func commitAuthor(repoPath string, opts CommitOptions, amended object.Commit) (object.Signature, error) {
+	var author object.Signature
+	switch {
+	case len(opts.Author) > 0:
+		name, email, err := config.ParseIdentity(opts.Author)
+		if err != nil {
+			return object.Signature{}, err
+		}
+		when, err := config.AuthorDate()
+		if err != nil {
+			return object.Signature{}, err
+		}
+		author = object.Signature{Name: name, Email: email, When: when}
+	case amended != nil:
+		author = object.Signature{
+			Name:  amended.Author(),
+			Email: amended.Email(),
+			When:  amended.Timestamp(),
+		}
+	default:
+		configured, err := config.Author(repoPath)
+		if err != nil {
+			return object.Signature{}, err
+		}
+		author = configured
+	}
+
+	if len(opts.Date) > 0 {
+		when, err := date.Parse(opts.Date, time.Now())
+		if err != nil {
+			return object.Signature{}, err
+		}
+		author.When = when
+	}
+
+	return author, nil
+}
@@ -0,0 +1,216 @@
+package commit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/add"
+	"github.com/matiasmartin00/arbor/internal/hooks"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
+	"github.com/matiasmartin00/arbor/internal/repo"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// newRepo creates a repository in a temporary directory and moves into it.
+func newRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("ARBOR_CONFIG_USER__NAME", "T")
+	t.Setenv("ARBOR_CONFIG_USER__EMAIL", "t@x")
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commitFile writes and stages the file and commits it with opts.
+func commitFile(t *testing.T, p, content string, opts CommitOptions) object.Commit {
+	t.Helper()
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := add.Add(".", false, []string{p}); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := Commit(".", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := object.ReadCommit(".", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestEmptyCommit(t *testing.T) {
+	newRepo(t)
+
+	if _, err := Commit(".", CommitOptions{Message: "nothing"}); !errors.Is(err, errNothingToCommit) {
+		t.Errorf("got %v, want nothing to commit in an empty repository", err)
+	}
+
+	first := commitFile(t, "f", "1\n", CommitOptions{Message: "first"})
+	if _, err := Commit(".", CommitOptions{Message: "again"}); !errors.Is(err, errNothingToCommit) {
+		t.Errorf("got %v, want nothing to commit on an unchanged tree", err)
+	}
+
+	hash, err := Commit(".", CommitOptions{Message: "again", AllowEmpty: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := object.ReadCommit(".", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.TreeHash().Equals(first.TreeHash()) || !c.ParentHash().Equals(first.Hash()) {
+		t.Error("the allowed empty commit does not follow the first one with its tree")
+	}
+
+	if _, err := Commit(".", CommitOptions{Message: "  \n\n "}); !errors.Is(err, errEmptyMessage) {
+		t.Errorf("got %v, want the empty message refused", err)
+	}
+}
+
+func TestAmend(t *testing.T) {
+	newRepo(t)
+
+	if _, err := Commit(".", CommitOptions{Message: "x", Amend: true}); !errors.Is(err, errAmendNoCommit) {
+		t.Fatalf("got %v, want nothing to amend", err)
+	}
+
+	first := commitFile(t, "f", "1\n", CommitOptions{Message: "first"})
+	second := commitFile(t, "f", "2\n", CommitOptions{
+		Message: "second",
+		Author:  "Alice <alice@example.com>",
+		Date:    "2024-01-31 10:00:00 +0100",
+	})
+	amended := commitFile(t, "g", "g\n", CommitOptions{Message: "second, fixed", Amend: true})
+
+	if !amended.ParentHash().Equals(first.Hash()) || len(amended.ParentHashes()) != 1 {
+		t.Errorf("got parents %v, want the parent of the amended commit", amended.ParentHashes())
+	}
+	if amended.Author() != "Alice" || amended.Email() != "alice@example.com" || !amended.Timestamp().Equal(second.Timestamp()) {
+		t.Errorf("got author %s <%s> at %v, want the amended author kept", amended.Author(), amended.Email(), amended.Timestamp())
+	}
+	if amended.Message() != "second, fixed" {
+		t.Errorf("got message %q", amended.Message())
+	}
+	if head, _ := refs.GetRefHash("."); !head.Equals(amended.Hash()) {
+		t.Error("HEAD does not point to the amended commit")
+	}
+
+	// the tree is the parent's again, amending into it is an empty commit
+	if err := os.WriteFile("f", []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove("g"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := add.Add(".", true, []string{"."}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Commit(".", CommitOptions{Message: "undo", Amend: true}); !errors.Is(err, errNothingToCommit) {
+		t.Errorf("got %v, want nothing to commit", err)
+	}
+
+	if err := refs.WriteMergeHead(".", first.Hash()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Commit(".", CommitOptions{Message: "x", Amend: true, AllowEmpty: true}); !errors.Is(err, errAmendWhileMerges) {
+		t.Errorf("got %v, want amending refused during a merge", err)
+	}
+}
+
+func TestAuthor(t *testing.T) {
+	date := time.Date(2024, 1, 31, 10, 0, 0, 0, time.FixedZone("", 3600))
+
+	tests := []struct {
+		name      string
+		opts      CommitOptions
+		env       string
+		wantName  string
+		wantEmail string
+		wantDate  time.Time
+	}{
+		{"configured", CommitOptions{}, "", "T", "t@x", time.Time{}},
+		{"environment date", CommitOptions{}, "2024-01-31 10:00:00 +0100", "T", "t@x", date},
+		{"author", CommitOptions{Author: "Alice <a@x>"}, "", "Alice", "a@x", time.Time{}},
+		{"author and environment date", CommitOptions{Author: "Alice <a@x>"}, "2024-01-31 10:00:00 +0100", "Alice", "a@x", date},
+		{"date over environment", CommitOptions{Author: "Alice <a@x>", Date: "2024-01-31 10:00:00 +0100"}, "2020-01-01", "Alice", "a@x", date},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newRepo(t)
+			if len(tt.env) > 0 {
+				t.Setenv("ARBOR_AUTHOR_DATE", tt.env)
+			}
+
+			before := time.Now().Add(-time.Second)
+			tt.opts.Message = "m"
+			c := commitFile(t, "f", "f\n", tt.opts)
+
+			if c.Author() != tt.wantName || c.Email() != tt.wantEmail {
+				t.Errorf("got %s <%s>, want %s <%s>", c.Author(), c.Email(), tt.wantName, tt.wantEmail)
+			}
+			if tt.wantDate.IsZero() {
+				if c.Timestamp().Before(before) {
+					t.Errorf("got date %v, want now", c.Timestamp())
+				}
+			} else if !c.Timestamp().Equal(tt.wantDate) {
+				t.Errorf("got date %v, want %v", c.Timestamp(), tt.wantDate)
+			}
+		})
+	}
+}
+
+func TestHooks(t *testing.T) {
+	newRepo(t)
+	hooksDir := filepath.Join(utils.GetRepoDir("."), "hooks")
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeHook := func(name, script string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(hooksDir, name), []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeHook(hooks.PreCommit, `grep -q TODO f && exit 1; exit 0`)
+	writeHook(hooks.CommitMsg, `printf '%s\n\nRefs: T-1\n' "$(cat "$1")" > "$1"`)
+	writeHook(hooks.PostCommit, `echo done > post`)
+
+	if err := os.WriteFile("f", []byte("TODO\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := add.Add(".", false, []string{"f"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Commit(".", CommitOptions{Message: "wip"}); err == nil {
+		t.Fatal("committed past a failing pre-commit hook")
+	}
+	if head, _ := refs.GetRefHash("."); head != nil {
+		t.Fatal("the refused commit moved HEAD")
+	}
+	if _, err := os.Stat("post"); err == nil {
+		t.Error("post-commit ran for a refused commit")
+	}
+
+	c := commitFile(t, "f", "done\n", CommitOptions{Message: "done"})
+	if c.Message() != "done\n\nRefs: T-1" {
+		t.Errorf("got message %q, want the one left by commit-msg", c.Message())
+	}
+	if _, err := os.Stat("post"); err != nil {
+		t.Errorf("post-commit did not run: %v", err)
+	}
+
+	c = commitFile(t, "f", "TODO\n", CommitOptions{Message: "skip", NoVerify: true})
+	if c.Message() != "skip" {
+		t.Errorf("got message %q, want the hooks skipped", c.Message())
+	}
+}
@@ -0,0 +1,113 @@
+package commit
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/config"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+const (
+	editMsgFile   = "COMMIT_EDITMSG"
+	defaultEditor = "vi"
+)
+
+const editHelp = `
+# Please enter the commit message for your changes. Lines starting
+# with '#' will be ignored, and an empty message aborts the commit.
+`
+
+// EditMessage opens the user editor on .arbor/COMMIT_EDITMSG with initial as content,
+// and returns the message once the editor exits, without comments.
+// The editor is $ARBOR_EDITOR, core.editor, $VISUAL, $EDITOR or vi, in that order.
+func EditMessage(repoPath, initial string) (string, error) {
+	path := filepath.Join(utils.GetRepoDir(repoPath), editMsgFile)
+	content := initial
+	if len(content) > 0 && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if err := utils.WriteFile(path, []byte(content+editHelp)); err != nil {
+		return "", err
+	}
+
+	if err := EditFile(repoPath, path); err != nil {
+		return "", err
+	}
+
+	data, err := utils.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return CleanupMessage(string(data), true), nil
+}
+
+// EditFile opens the user editor on path and waits for it to exit, see EditMessage.
+func EditFile(repoPath, path string) error {
+	editor, err := editorCommand(repoPath)
+	if err != nil {
+		return err
+	}
+
+	// through the shell, so editors with arguments ("code --wait") work
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+	return nil
+}
+
+// CleanupMessage removes trailing spaces, repeated and surrounding blank lines,
+// and with stripComments the lines starting with '#'.
+func CleanupMessage(msg string, stripComments bool) string {
+	out := []string{}
+	blank := false
+	for _, l := range strings.Split(msg, "\n") {
+		if stripComments && strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		l = strings.TrimRight(l, " \t\r")
+		if len(l) == 0 {
+			blank = len(out) > 0
+			continue
+		}
+
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, l)
+	}
+
+	return strings.Join(out, "\n")
+}
+
+func editorCommand(repoPath string) (string, error) {
+	if v := os.Getenv("ARBOR_EDITOR"); len(v) > 0 {
+		return v, nil
+	}
+
+	v, ok, err := config.Get(repoPath, "core.editor")
+	if err != nil {
+		return "", err
+	}
+	if ok && len(v) > 0 {
+		return v, nil
+	}
+
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if v := os.Getenv(env); len(v) > 0 {
+			return v, nil
+		}
+	}
+
+	return defaultEditor, nil
+}
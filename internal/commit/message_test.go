@@ -0,0 +1,76 @@
+package commit
+
+import (
+	"testing"
+)
+
+func TestCleanupMessage(t *testing.T) {
+	tests := []struct {
+		name          string
+		msg           string
+		stripComments bool
+		want          string
+	}{
+		{"surrounding blank lines", "\n\nsubject\n\n", false, "subject"},
+		{"trailing spaces", "subject  \n\nbody\t\n", false, "subject\n\nbody"},
+		{"repeated blank lines", "subject\n\n\n\nbody", false, "subject\n\nbody"},
+		{"comments kept", "subject\n# not a comment", false, "subject\n# not a comment"},
+		{"comments stripped", "subject\n# comment\n\nbody\n# comment", true, "subject\n\nbody"},
+		{"only comments", "# comment\n\n", true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CleanupMessage(tt.msg, tt.stripComments); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	newRepo(t)
+
+	// the editor appends a line, and sees the initial message and the help
+	t.Setenv("ARBOR_EDITOR", `grep -q "^# Please enter" "$1" && grep -q "^initial$" "$1" && echo added >>`)
+	msg, err := EditMessage(".", "initial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg != "initial\n\nadded" {
+		t.Errorf("got %q, want the edited message without the help", msg)
+	}
+
+	t.Setenv("ARBOR_EDITOR", "false")
+	if _, err := EditMessage(".", ""); err == nil {
+		t.Error("a failing editor did not fail")
+	}
+}
+
+func TestEditorCommand(t *testing.T) {
+	newRepo(t)
+	t.Setenv("ARBOR_EDITOR", "")
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "")
+
+	check := func(want string) {
+		t.Helper()
+		got, err := editorCommand(".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got editor %q, want %q", got, want)
+		}
+	}
+
+	check(defaultEditor)
+	t.Setenv("EDITOR", "ed")
+	check("ed")
+	t.Setenv("VISUAL", "vis")
+	check("vis")
+	t.Setenv("ARBOR_CONFIG_CORE__EDITOR", "configured")
+	check("configured")
+	t.Setenv("ARBOR_EDITOR", "arbor")
+	check("arbor")
+}
@@ -0,0 +1,262 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+const (
+	configFile       = "config"
+	globalConfigFile = ".arborconfig"
+	// GlobalEnv overrides the location of the per-user config file
+	GlobalEnv = "ARBOR_CONFIG_GLOBAL"
+	// envPrefix and envSep build the variables overriding single keys, see EnvName
+	envPrefix = "ARBOR_CONFIG_"
+	envSep    = "__"
+)
+
+// Scope is where a value comes from, later scopes take precedence.
+type Scope int
+
+const (
+	Global Scope = iota
+	Local
+	Env
+)
+
+func (s Scope) String() string {
+	scopes := []string{"global", "local", "env"}
+	if s < 0 || int(s) >= len(scopes) {
+		return ""
+	}
+	return scopes[int(s)]
+}
+
+type Entry struct {
+	Key   string
+	Value string
+	Scope Scope
+}
+
+// Get returns the value of key with the highest precedence: environment, then
+// .arbor/config, then the per-user config.
+func Get(repoPath, key string) (string, bool, error) {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return "", false, err
+	}
+
+	if v, ok := os.LookupEnv(EnvName(key)); ok {
+		return v, true, nil
+	}
+
+	for _, scope := range []Scope{Local, Global} {
+		f, err := load(repoPath, scope)
+		if err != nil {
+			return "", false, err
+		}
+
+		if v, ok := f.get(key); ok {
+			return v, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// GetDefault returns the value of key or def when it is not set.
+func GetDefault(repoPath, key, def string) (string, error) {
+	v, ok, err := Get(repoPath, key)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return def, nil
+	}
+	return v, nil
+}
+
+// List returns every entry of every scope, lowest precedence first.
+func List(repoPath string) ([]Entry, error) {
+	entries := []Entry{}
+	for _, scope := range []Scope{Global, Local} {
+		f, err := load(repoPath, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, kv := range f.entries() {
+			entries = append(entries, Entry{Key: kv[0], Value: kv[1], Scope: scope})
+		}
+	}
+
+	envEntries := []Entry{}
+	for _, e := range os.Environ() {
+		name, value, _ := strings.Cut(e, "=")
+		if !strings.HasPrefix(name, envPrefix) || name == GlobalEnv {
+			continue
+		}
+
+		key, ok := envKey(name)
+		if !ok {
+			continue
+		}
+		envEntries = append(envEntries, Entry{Key: key, Value: value, Scope: Env})
+	}
+
+	sort.Slice(envEntries, func(i, j int) bool {
+		return envEntries[i].Key < envEntries[j].Key
+	})
+
+	return append(entries, envEntries...), nil
+}
+
+// Set writes key = value in the config file of scope.
+func Set(repoPath string, scope Scope, key, value string) error {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if scope == Env {
+		return fmt.Errorf("environment values can not be written, export %s instead", EnvName(key))
+	}
+
+	f, err := load(repoPath, scope)
+	if err != nil {
+		return err
+	}
+
+	f.set(key, value)
+	return f.save()
+}
+
+// Unset removes key from the config file of scope.
+func Unset(repoPath string, scope Scope, key string) error {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if scope == Env {
+		return fmt.Errorf("environment values can not be removed, unset %s instead", EnvName(key))
+	}
+
+	f, err := load(repoPath, scope)
+	if err != nil {
+		return err
+	}
+
+	if !f.unset(key) {
+		return fmt.Errorf("key %s is not set in %s config", key, scope)
+	}
+	return f.save()
+}
+
+// EnvName returns the environment variable that overrides key: ARBOR_CONFIG_
+// and the section, subsection and name upper cased and joined by "__", with
+// "-" written as "_". user.name is ARBOR_CONFIG_USER__NAME and
+// branch.main.remote is ARBOR_CONFIG_BRANCH__MAIN__REMOTE.
+func EnvName(key string) string {
+	parts := strings.Split(key, ".")
+	section, name := parts[0], parts[len(parts)-1]
+	if len(parts) > 2 {
+		section, name = parts[0]+envSep+strings.Join(parts[1:len(parts)-1], "."), parts[len(parts)-1]
+	}
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(section+envSep+name, "-", "_"))
+}
+
+// envKey reads a variable named by EnvName back into a key. Subsections come
+// back lower cased, the environment does not keep their case.
+func envKey(variable string) (string, bool) {
+	parts := strings.Split(strings.TrimPrefix(variable, envPrefix), envSep)
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", false
+	}
+
+	for i, p := range parts {
+		if len(p) == 0 {
+			return "", false
+		}
+		parts[i] = strings.ToLower(p)
+	}
+
+	// subsections may hold anything, sections and names only letters, digits and "-"
+	parts[0] = strings.ReplaceAll(parts[0], "_", "-")
+	parts[len(parts)-1] = strings.ReplaceAll(parts[len(parts)-1], "_", "-")
+	return strings.Join(parts, "."), true
+}
+
+// Path returns the config file of scope.
+func Path(repoPath string, scope Scope) (string, error) {
+	switch scope {
+	case Local:
+		return filepath.Join(utils.GetRepoDir(repoPath), configFile), nil
+	case Global:
+		if p, ok := os.LookupEnv(GlobalEnv); ok {
+			return p, nil
+		}
+
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("can not find the home directory for the global config: %w", err)
+		}
+		return filepath.Join(home, globalConfigFile), nil
+	default:
+		return "", fmt.Errorf("scope %s has no config file", scope)
+	}
+}
+
+// normalizeKey checks a "section.name" or "section.subsection.name" key and
+// lower cases the section and name, which are case insensitive.
+func normalizeKey(key string) (string, error) {
+	first := strings.Index(key, ".")
+	last := strings.LastIndex(key, ".")
+	if first <= 0 || last == len(key)-1 {
+		return "", fmt.Errorf("invalid config key %q, expected <section>.<name>", key)
+	}
+
+	section := strings.ToLower(key[:first])
+	name := strings.ToLower(key[last+1:])
+	if !validKeyPart(section) || !validKeyPart(name) {
+		return "", fmt.Errorf("invalid config key %q, the section and the name can only hold letters, digits and '-'", key)
+	}
+
+	if first == last {
+		return section + "." + name, nil
+	}
+
+	return section + key[first:last] + "." + name, nil
+}
+
+func validKeyPart(s string) bool {
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func load(repoPath string, scope Scope) (*file, error) {
+	path, err := Path(repoPath, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := utils.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &file{path: path}, nil
+		}
+		return nil, err
+	}
+
+	return parse(path, data)
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/repo"
+)
+
+// newRepo creates a repository in a temporary directory, moves into it and
+// points the global config at a file there. No identity is set.
+func newRepo(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv(GlobalEnv, filepath.Join(dir, "global"))
+	for _, e := range os.Environ() {
+		name, _, _ := strings.Cut(e, "=")
+		if strings.HasPrefix(name, envPrefix) && name != GlobalEnv || strings.HasPrefix(name, "ARBOR_AUTHOR_") {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"user.name", "ARBOR_CONFIG_USER__NAME"},
+		{"core.auto-crlf", "ARBOR_CONFIG_CORE__AUTO_CRLF"},
+		{"branch.main.remote", "ARBOR_CONFIG_BRANCH__MAIN__REMOTE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got := EnvName(tt.key)
+			if got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+			if key, ok := envKey(got); !ok || key != tt.key {
+				t.Errorf("%s reads back as %q (valid %v)", got, key, ok)
+			}
+		})
+	}
+
+	for _, v := range []string{"ARBOR_CONFIG_USER", "ARBOR_CONFIG_USER____NAME", "ARBOR_CONFIG_A__B__C__D"} {
+		if key, ok := envKey(v); ok {
+			t.Errorf("%s reads as the key %q", v, key)
+		}
+	}
+}
+
+func TestNormalizeKey(t *testing.T) {
+	if got, err := normalizeKey("User.Name"); err != nil || got != "user.name" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if got, err := normalizeKey("Branch.Feature.X.Remote"); err != nil || got != "branch.Feature.X.remote" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	for _, key := range []string{"user", ".name", "user.", "us_er.name", "user.na me"} {
+		if _, err := normalizeKey(key); err == nil {
+			t.Errorf("%q is a valid key", key)
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	newRepo(t)
+
+	get := func() string {
+		t.Helper()
+		v, _, err := Get(".", "user.name")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	if err := Set(".", Global, "user.name", "global"); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(); got != "global" {
+		t.Errorf("got %q, want the global value", got)
+	}
+
+	if err := Set(".", Local, "user.name", "local"); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(); got != "local" {
+		t.Errorf("got %q, want the repository value over the global one", got)
+	}
+
+	t.Setenv("ARBOR_CONFIG_USER__NAME", "env")
+	if got := get(); got != "env" {
+		t.Errorf("got %q, want the environment over the files", got)
+	}
+
+	entries, err := List(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scopes := []string{}
+	for _, e := range entries {
+		scopes = append(scopes, e.Scope.String()+":"+e.Value)
+	}
+	if got := strings.Join(scopes, " "); got != "global:global local:local env:env" {
+		t.Errorf("got entries %s, lowest precedence first", got)
+	}
+
+	if err := Set(".", Env, "user.name", "x"); err == nil {
+		t.Error("wrote an environment value")
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	t.Run("fallback", func(t *testing.T) {
+		newRepo(t)
+		t.Setenv("USER", "alice")
+
+		sig, err := Author(".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig.Name != "alice" || sig.Email != "alice@localhost" {
+			t.Errorf("got %s <%s>, want alice <alice@localhost>", sig.Name, sig.Email)
+		}
+
+		t.Setenv("USER", "")
+		sig, err = Committer(".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig.Name != "anonymous" || sig.Email != "anonymous@localhost" {
+			t.Errorf("got %s <%s>, want anonymous <anonymous@localhost>", sig.Name, sig.Email)
+		}
+	})
+
+	t.Run("config and environment", func(t *testing.T) {
+		newRepo(t)
+		if err := Set(".", Local, "user.name", "Alice"); err != nil {
+			t.Fatal(err)
+		}
+		if err := Set(".", Local, "user.email", "alice@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("ARBOR_AUTHOR_NAME", "Bob")
+		t.Setenv("ARBOR_AUTHOR_DATE", "@1700000000")
+
+		sig, err := Author(".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig.Name != "Bob" || sig.Email != "alice@example.com" || sig.When.Unix() != 1700000000 {
+			t.Errorf("got %s <%s> at %v", sig.Name, sig.Email, sig.When)
+		}
+
+		sig, err = Committer(".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig.Name != "Alice" {
+			t.Errorf("the author variables changed the committer to %s", sig.Name)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		newRepo(t)
+		t.Setenv("ARBOR_AUTHOR_NAME", "Bob <bob>")
+		if _, err := Author("."); err == nil {
+			t.Error("accepted a name with '<' and '>'")
+		}
+	})
+}
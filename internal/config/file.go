@@ -0,0 +1,205 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// file is an INI-like config file:
+//
+//	# comment
+//	[user]
+//		name = Alice
+//		email = alice@example.com
+//	[branch "main"]
+//		remote = origin
+//
+// Lines are kept as read, so comments and layout survive a set or unset.
+type file struct {
+	path  string
+	lines []line
+}
+
+type line struct {
+	raw string
+	// section of the line, in key form: "user" or "branch.main"
+	section string
+	// name and value are set for key lines only
+	name  string
+	value string
+	isKey bool
+}
+
+func parse(path string, data []byte) (*file, error) {
+	f := &file{path: path}
+	section := ""
+	for n, raw := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		l := line{raw: raw}
+		trimmed := strings.TrimSpace(raw)
+
+		switch {
+		case len(trimmed) == 0 || trimmed[0] == '#' || trimmed[0] == ';':
+			// blank or comment
+		case trimmed[0] == '[':
+			s, err := parseSection(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, n+1, err)
+			}
+			section = s
+		default:
+			if len(section) == 0 {
+				return nil, fmt.Errorf("%s:%d: key outside of a section", path, n+1)
+			}
+
+			name, value, ok := strings.Cut(trimmed, "=")
+			if !ok {
+				// a bare name is a boolean set to true
+				value = "true"
+			}
+			l.name = strings.ToLower(strings.TrimSpace(name))
+			l.value = unquote(strings.TrimSpace(value))
+			l.isKey = true
+		}
+
+		l.section = section
+		f.lines = append(f.lines, l)
+	}
+
+	return f, nil
+}
+
+// parseSection reads `[section]` or `[section "subsection"]`.
+func parseSection(s string) (string, error) {
+	if !strings.HasSuffix(s, "]") {
+		return "", fmt.Errorf("invalid section header %q", s)
+	}
+
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	name, sub, ok := strings.Cut(inner, " ")
+	if !ok {
+		return strings.ToLower(name), nil
+	}
+
+	sub = strings.TrimSpace(sub)
+	if len(sub) < 2 || sub[0] != '"' || sub[len(sub)-1] != '"' {
+		return "", fmt.Errorf("invalid section header %q", s)
+	}
+
+	return strings.ToLower(name) + "." + sub[1:len(sub)-1], nil
+}
+
+// unquote reads a value written by quote: between double quotes, `\"` and `\\`
+// stand for a quote and a backslash.
+func unquote(v string) string {
+	if len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+		return v
+	}
+
+	var sb strings.Builder
+	inner := v[1 : len(v)-1]
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '\\' && i+1 < len(inner) {
+			i++
+		}
+		sb.WriteByte(inner[i])
+	}
+	return sb.String()
+}
+
+// quote wraps values that would not read back as they are in double quotes,
+// escaping quotes and backslashes.
+func quote(v string) string {
+	if v != strings.TrimSpace(v) || strings.ContainsAny(v, "#;\"\\") {
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+		return `"` + r.Replace(v) + `"`
+	}
+	return v
+}
+
+func splitKey(key string) (string, string) {
+	i := strings.LastIndex(key, ".")
+	return key[:i], key[i+1:]
+}
+
+// get returns the last value of key, later lines win like in git.
+func (f *file) get(key string) (string, bool) {
+	section, name := splitKey(key)
+	value, found := "", false
+	for _, l := range f.lines {
+		if l.isKey && l.section == section && l.name == name {
+			value, found = l.value, true
+		}
+	}
+	return value, found
+}
+
+func (f *file) entries() [][2]string {
+	out := [][2]string{}
+	for _, l := range f.lines {
+		if l.isKey {
+			out = append(out, [2]string{l.section + "." + l.name, l.value})
+		}
+	}
+	return out
+}
+
+func (f *file) set(key, value string) {
+	section, name := splitKey(key)
+	newLine := line{
+		raw:     fmt.Sprintf("\t%s = %s", name, quote(value)),
+		section: section,
+		name:    name,
+		value:   value,
+		isKey:   true,
+	}
+
+	// replace the last definition
+	for i := len(f.lines) - 1; i >= 0; i-- {
+		l := f.lines[i]
+		if l.isKey && l.section == section && l.name == name {
+			f.lines[i] = newLine
+			return
+		}
+	}
+
+	// add it at the end of its section
+	for i := len(f.lines) - 1; i >= 0; i-- {
+		if f.lines[i].section == section {
+			f.lines = append(f.lines[:i+1], append([]line{newLine}, f.lines[i+1:]...)...)
+			return
+		}
+	}
+
+	header := "[" + section + "]"
+	if dot := strings.Index(section, "."); dot != -1 {
+		header = fmt.Sprintf("[%s \"%s\"]", section[:dot], section[dot+1:])
+	}
+	f.lines = append(f.lines, line{raw: header, section: section}, newLine)
+}
+
+// unset removes every definition of key, reporting if there was any.
+func (f *file) unset(key string) bool {
+	section, name := splitKey(key)
+	kept := f.lines[:0]
+	removed := false
+	for _, l := range f.lines {
+		if l.isKey && l.section == section && l.name == name {
+			removed = true
+			continue
+		}
+		kept = append(kept, l)
+	}
+	f.lines = kept
+	return removed
+}
+
+func (f *file) save() error {
+	var sb strings.Builder
+	for _, l := range f.lines {
+		sb.WriteString(l.raw)
+		sb.WriteString("\n")
+	}
+	return utils.WriteFile(f.path, []byte(sb.String()))
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	data := `# comment
+; another comment
+[user]
+	name = Alice
+	Email=alice@example.com
+
+[branch "Main"]
+	remote = origin
+	merge = "  spaced  "
+[core]
+	bare
+	quoted = "a \"b\" c:\\d"
+	name = first
+	name = last
+`
+	f, err := parse("config", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"user.name", "Alice"},
+		{"user.email", "alice@example.com"},
+		{"branch.Main.remote", "origin"},
+		{"branch.Main.merge", "  spaced  "},
+		{"core.bare", "true"},
+		{"core.quoted", `a "b" c:\d`},
+		{"core.name", "last"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok := f.get(tt.key)
+			if !ok || got != tt.want {
+				t.Errorf("got %q (set %v), want %q", got, ok, tt.want)
+			}
+		})
+	}
+
+	if _, ok := f.get("branch.main.remote"); ok {
+		t.Error("subsections are case insensitive")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"key outside of a section", "name = x\n"},
+		{"unclosed section", "[user\n"},
+		{"unquoted subsection", "[branch main]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parse("config", []byte(tt.data)); err == nil {
+				t.Errorf("parsed %q", tt.data)
+			}
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for _, v := range []string{"plain", " leading", "trailing ", "a # b", "a ; b", `"quoted"`, `back\slash`, ""} {
+		if got := unquote(quote(v)); got != v {
+			t.Errorf("%q reads back as %q", v, got)
+		}
+	}
+	if got := quote("plain"); got != "plain" {
+		t.Errorf("plain value quoted as %q", got)
+	}
+}
+
+func TestSetKeepsLayout(t *testing.T) {
+	f, err := parse("config", []byte("# keep me\n[user]\n\tname = Alice\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.set("user.name", "Bob")
+	f.set("user.email", "bob@example.com")
+	f.set("branch.main.remote", "origin")
+	if !f.unset("user.email") || f.unset("user.email") {
+		t.Error("unset does not report the removed key")
+	}
+
+	raw := []string{}
+	for _, l := range f.lines {
+		raw = append(raw, l.raw)
+	}
+	want := []string{"# keep me", "[user]", "\tname = Bob", `[branch "main"]`, "\tremote = origin"}
+	if !slices.Equal(raw, want) {
+		t.Errorf("got lines %q, want %q", raw, want)
+	}
+}
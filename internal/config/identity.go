@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/date"
+	"github.com/matiasmartin00/arbor/internal/object"
+)
+
+const (
+	userNameKey  = "user.name"
+	userEmailKey = "user.email"
+)
+
+// Author returns the author of new commits: ARBOR_AUTHOR_NAME and ARBOR_AUTHOR_EMAIL,
+// falling back to user.name and user.email, then to $USER@localhost. The date is
+// now, or ARBOR_AUTHOR_DATE.
+func Author(repoPath string) (object.Signature, error) {
+	return identity(repoPath, "ARBOR_AUTHOR")
+}
+
+// Committer returns the committer of new commits: ARBOR_COMMITTER_NAME and ARBOR_COMMITTER_EMAIL,
+// falling back to user.name and user.email, then to $USER@localhost. The date is
+// now, or ARBOR_COMMITTER_DATE.
+func Committer(repoPath string) (object.Signature, error) {
+	return identity(repoPath, "ARBOR_COMMITTER")
+}
+
+func identity(repoPath, envPrefix string) (object.Signature, error) {
+	name, err := identityValue(repoPath, envPrefix+"_NAME", userNameKey)
+	if err != nil {
+		return object.Signature{}, err
+	}
+
+	email, err := identityValue(repoPath, envPrefix+"_EMAIL", userEmailKey)
+	if err != nil {
+		return object.Signature{}, err
+	}
+
+	// as before there was a config: $USER, or anonymous, at localhost
+	if len(name) == 0 {
+		name = defaultUser()
+	}
+	if len(email) == 0 {
+		email = defaultUser() + "@localhost"
+	}
+
+	if strings.ContainsAny(name, "<>\n") || strings.ContainsAny(email, "<>\n") {
+		return object.Signature{}, fmt.Errorf("invalid identity %q <%s>: name and email can not contain '<', '>' or new lines", name, email)
+	}
+
+	when, err := identityDate(envPrefix)
+	if err != nil {
+		return object.Signature{}, err
+	}
+
+	return object.Signature{
+		Name:  name,
+		Email: email,
+		When:  when,
+	}, nil
+}
+
+// AuthorDate returns the date of new commits: ARBOR_AUTHOR_DATE, or now.
+func AuthorDate() (time.Time, error) {
+	return identityDate("ARBOR_AUTHOR")
+}
+
+func identityDate(envPrefix string) (time.Time, error) {
+	now := time.Now()
+	v, ok := os.LookupEnv(envPrefix + "_DATE")
+	if !ok || len(strings.TrimSpace(v)) == 0 {
+		return now, nil
+	}
+
+	when, err := date.Parse(v, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s_DATE: %w", envPrefix, err)
+	}
+	return when, nil
+}
+
+// ParseIdentity reads "Name <email>".
+func ParseIdentity(s string) (string, string, error) {
+	name, rest, ok := strings.Cut(s, "<")
+	if !ok || !strings.HasSuffix(strings.TrimSpace(rest), ">") {
+		return "", "", fmt.Errorf("invalid identity %q, expected \"Name <email>\"", s)
+	}
+
+	name = strings.TrimSpace(name)
+	email := strings.TrimSuffix(strings.TrimSpace(rest), ">")
+	if len(name) == 0 || len(email) == 0 || strings.ContainsAny(email, "<>") {
+		return "", "", fmt.Errorf("invalid identity %q, expected \"Name <email>\"", s)
+	}
+
+	return name, email, nil
+}
+
+func identityValue(repoPath, env, key string) (string, error) {
+	if v, ok := os.LookupEnv(env); ok {
+		return strings.TrimSpace(v), nil
+	}
+
+	v, _, err := Get(repoPath, key)
+	return strings.TrimSpace(v), err
+}
+
+func defaultUser() string {
+	if user := strings.TrimSpace(os.Getenv("USER")); len(user) > 0 {
+		return user
+	}
+	return "anonymous"
+}
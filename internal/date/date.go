@@ -0,0 +1,132 @@
+package date
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var layouts = []string{
+	time.RFC3339,
+	time.RFC1123,
+	time.RFC1123Z,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+var units = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+// Parse reads an absolute date (RFC3339, RFC1123, "2006-01-02 15:04:05", "2006-01-02", a unix
+// timestamp as "@1700000000") or a relative one ("now", "today", "yesterday", "2 weeks ago").
+// Dates without a zone are taken in the local zone. now is the reference for relative dates.
+func Parse(s string, now time.Time) (time.Time, error) {
+	v := strings.TrimSpace(strings.ToLower(s))
+	if len(v) == 0 {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+
+	switch v {
+	case "now":
+		return now, nil
+	case "today":
+		y, m, d := now.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), nil
+	case "yesterday":
+		y, m, d := now.AddDate(0, 0, -1).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), nil
+	}
+
+	if strings.HasPrefix(v, "@") {
+		epoch, err := strconv.ParseInt(v[1:], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", s)
+		}
+		return time.Unix(epoch, 0), nil
+	}
+
+	if strings.HasSuffix(v, " ago") {
+		return parseRelative(s, strings.TrimSuffix(v, " ago"), now)
+	}
+
+	for _, l := range layouts {
+		if t, err := time.ParseInLocation(l, strings.TrimSpace(s), now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// parseRelative reads "<n> <unit>[s]" pairs, e.g. "2 weeks", "1 day 3 hours", "3 months".
+func parseRelative(orig, v string, now time.Time) (time.Time, error) {
+	fields := strings.Fields(strings.ReplaceAll(v, ",", " "))
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return time.Time{}, fmt.Errorf("invalid date %q", orig)
+	}
+
+	t := now
+	for i := 0; i < len(fields); i += 2 {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", orig)
+		}
+
+		unit := strings.TrimSuffix(fields[i+1], "s")
+		switch unit {
+		case "month":
+			t = t.AddDate(0, -n, 0)
+		case "year":
+			t = t.AddDate(-n, 0, 0)
+		default:
+			d, ok := units[unit]
+			if !ok {
+				return time.Time{}, fmt.Errorf("invalid date %q: unknown unit %q", orig, fields[i+1])
+			}
+			t = t.Add(-time.Duration(n) * d)
+		}
+	}
+
+	return t, nil
+}
+
+// Relative describes t compared with now, e.g. "5 minutes ago", "3 weeks ago".
+func Relative(t, now time.Time) string {
+	d := now.Sub(t)
+	if d < 0 {
+		return "in the future"
+	}
+
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s ago", n, unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+
+	switch {
+	case d < 90*time.Second:
+		return plural(int64(d/time.Second), "second")
+	case d < 90*time.Minute:
+		return plural(int64(d/time.Minute), "minute")
+	case d < 36*time.Hour:
+		return plural(int64(d/time.Hour), "hour")
+	case d < 14*units["day"]:
+		return plural(int64(d/units["day"]), "day")
+	case d < 10*units["week"]:
+		return plural(int64(d/units["week"]), "week")
+	case d < 365*units["day"]:
+		return plural(int64(d/(30*units["day"])), "month")
+	default:
+		return plural(int64(d/(365*units["day"])), "year")
+	}
+}
@@ -0,0 +1,147 @@
+package diff
+
+import (
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/pathspec"
+)
+
+// CombinedLine is a line of a combined diff, with one marker per parent:
+// AddedLine if the line is not in that parent, RemovedLine if it is only in that parent.
+type CombinedLine struct {
+	Markers []LineResult
+	Line    string
+}
+
+type CombinedResult struct {
+	File    string
+	Parents []object.ObjectHash
+	Hash    object.ObjectHash
+	// Lines is nil when any of the versions is binary
+	Lines []CombinedLine
+}
+
+// CombinedDiff compares a merge tree with all of its parent trees at once. Only the
+// files that differ from every parent are shown, the ones taken as is from a parent
+// are not interesting in a merge.
+func CombinedDiff(repoPath string, parents []object.ObjectHash, merged object.ObjectHash, opts Options) ([]CombinedResult, error) {
+	mergedMap, _, err := treePathMap(repoPath, merged)
+	if err != nil {
+		return nil, err
+	}
+
+	parentMaps := make([]map[string]object.ObjectHash, 0, len(parents))
+	seen := map[string]struct{}{}
+	for p := range mergedMap {
+		seen[p] = struct{}{}
+	}
+	for _, parent := range parents {
+		m, _, err := treePathMap(repoPath, parent)
+		if err != nil {
+			return nil, err
+		}
+		for p := range m {
+			seen[p] = struct{}{}
+		}
+		parentMaps = append(parentMaps, m)
+	}
+
+	results := []CombinedResult{}
+	for _, p := range sortedKeys(seen) {
+		if !pathspec.Match(opts.Paths, p) {
+			continue
+		}
+
+		hash := mergedMap[p]
+		parentHashes := make([]object.ObjectHash, len(parentMaps))
+		interesting := true
+		for i, m := range parentMaps {
+			parentHashes[i] = m[p]
+			if sameBlob(m[p], hash) {
+				interesting = false
+			}
+		}
+		if !interesting {
+			continue
+		}
+
+		lines, _, binary, err := blobLines(repoPath, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		parentLines := make([][]string, len(parentHashes))
+		for i, h := range parentHashes {
+			pl, _, b, err := blobLines(repoPath, h)
+			if err != nil {
+				return nil, err
+			}
+			binary = binary || b
+			parentLines[i] = pl
+		}
+
+		result := CombinedResult{File: p, Parents: parentHashes, Hash: hash}
+		if !binary {
+			result.Lines = combineLines(parentLines, lines, opts)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// combineLines merges the diffs of each parent against lines: the lines removed from
+// a parent go before the line of the result they were removed in front of.
+func combineLines(parentLines [][]string, lines []string, opts Options) []CombinedLine {
+	n := len(parentLines)
+	added := make([][]bool, n)
+	removed := make([][][]string, n)
+	for i, pl := range parentLines {
+		added[i] = make([]bool, len(lines))
+		removed[i] = make([][]string, len(lines)+1)
+
+		j := 0
+		for _, ld := range unifiedDiff(pl, lines, opts) {
+			switch ld.Result {
+			case RemovedLine:
+				removed[i][j] = append(removed[i][j], ld.ALine)
+			case AddedLine:
+				added[i][j] = true
+				j++
+			default:
+				j++
+			}
+		}
+	}
+
+	out := []CombinedLine{}
+	for j := 0; j <= len(lines); j++ {
+		for i := range parentLines {
+			for _, r := range removed[i][j] {
+				markers := make([]LineResult, n)
+				markers[i] = RemovedLine
+				out = append(out, CombinedLine{Markers: markers, Line: r})
+			}
+		}
+
+		if j == len(lines) {
+			break
+		}
+
+		markers := make([]LineResult, n)
+		for i := range parentLines {
+			if added[i][j] {
+				markers[i] = AddedLine
+			}
+		}
+		out = append(out, CombinedLine{Markers: markers, Line: lines[j]})
+	}
+
+	return out
+}
+
+func sameBlob(a, b object.ObjectHash) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equals(b)
+}
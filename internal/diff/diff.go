@@ -4,9 +4,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
 
 	"github.com/matiasmartin00/arbor/internal/index"
 	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/pathspec"
+	"github.com/matiasmartin00/arbor/internal/revision"
 	"github.com/matiasmartin00/arbor/internal/tree"
 	"github.com/matiasmartin00/arbor/internal/utils"
 )
@@ -24,6 +29,8 @@ type LineData struct {
 	BLine      string
 	ResultLine string
 	Result     LineResult
+	// NoEOL marks the last line of a version that does not end with a newline
+	NoEOL bool
 }
 
 func (ld LineResult) String() string {
@@ -38,56 +45,142 @@ type DiffResult struct {
 	File  string
 	AHash object.ObjectHash
 	BHash object.ObjectHash
+	// AMode and BMode are the file modes of each version, zero for a missing file
+	AMode object.FileMode
+	BMode object.FileMode
 	Lines []LineData
+	// OldFile is the source of a renamed or copied File, empty otherwise
+	OldFile    string
+	Similarity int
+	Copied     bool
 }
 
-func readFileContent(repoPath, path string) ([]string, error) {
-	data, err := utils.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return []string{}, nil
+// IsSymlink reports if either version of the file is a symlink.
+func (r DiffResult) IsSymlink() bool {
+	return r.AMode == object.ModeSymlink || r.BMode == object.ModeSymlink
+}
+
+// Options tunes what the diffs compare and report.
+type Options struct {
+	// Paths limits the diff to files in these repository relative paths
+	Paths []string
+	// Renames pairs deleted and added files with similar content, see DetectRenames
+	Renames bool
+	// Copies also finds added files copied from files that still exist
+	Copies bool
+	// RenameThreshold is the minimum similarity in percent, DefaultRenameThreshold when zero
+	RenameThreshold int
+
+	// IgnoreAllSpace compares lines without their whitespace
+	IgnoreAllSpace bool
+	// IgnoreSpaceChange compares lines with runs of whitespace as a single space,
+	// and without trailing whitespace
+	IgnoreSpaceChange bool
+	// IgnoreBlankLines ignores blank lines that are added or removed
+	IgnoreBlankLines bool
+	// IgnoreCRAtEOL ignores a carriage return at the end of lines
+	IgnoreCRAtEOL bool
+}
+
+// lineKey is the part of a line that is compared, as set by the whitespace options.
+func (o Options) lineKey(l string) string {
+	switch {
+	case o.IgnoreAllSpace:
+		return strings.Join(strings.Fields(l), "")
+	case o.IgnoreSpaceChange:
+		// leading whitespace still counts, only its amount does not
+		key := strings.Join(strings.Fields(l), " ")
+		if len(key) > 0 && unicode.IsSpace(rune(l[0])) {
+			key = " " + key
 		}
-		return nil, err
+		return key
+	case o.IgnoreCRAtEOL:
+		return strings.TrimSuffix(l, "\r")
 	}
+	return l
+}
 
-	return object.SplitLines(data)
+// ignoresWhitespace reports if lines with different bytes may compare as equal.
+func (o Options) ignoresWhitespace() bool {
+	return o.IgnoreAllSpace || o.IgnoreSpaceChange || o.IgnoreBlankLines || o.IgnoreCRAtEOL
 }
 
+// splitLines splits data in lines, keeping carriage returns so they show up as changes.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// noEOL reports if the last line of data does not end with a newline.
+func noEOL(data []byte) bool {
+	return len(data) > 0 && data[len(data)-1] != '\n'
+}
+
+// NoEOLLine follows a line marked NoEOL in a diff
+const NoEOLLine = "\\ No newline at end of file"
+
+// eolMark can not be part of a line, it tells a last line without a newline
+// apart from the same line with one
+const eolMark = "\n"
+
 // DiffWorktreeVsIndex diffs the working copy vs the index and return diff result
-func DiffWorktreeVsIndex(repoPath string, paths []string) ([]DiffResult, error) {
+func DiffWorktreeVsIndex(repoPath string, opts Options) ([]DiffResult, error) {
 	idx, err := index.Load(repoPath)
 	if err != nil {
 		return nil, err
 	}
 
-	targets := pathsToTargetMap(paths)
+	files := map[string]struct{}{}
+	for p := range idx {
+		files[p] = struct{}{}
+	}
 
 	diffResult := []DiffResult{}
-	for p, ie := range idx {
+	for _, p := range sortedKeys(files) {
+		ie := idx[p]
 		// if paths filter given, skip others
-		if len(targets) > 0 {
-			if _, ok := targets[p]; !ok {
-				continue
-			}
+		if !pathspec.Match(opts.Paths, p) {
+			continue
 		}
 
-		// if file is a binary don't compare lines, just checks hashes
-		if ie.IsBinary {
-			workPath := filepath.FromSlash(p)
-			data, err := utils.ReadFile(workPath)
+		// a missing file is a deletion, with no hash on the worktree side
+		workPath := filepath.FromSlash(p)
+		var workHash object.ObjectHash
+		data, workMode, err := object.ReadWorktreeFile(workPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			workHash, err = object.NewHashBlob(data)
 			if err != nil {
 				return nil, err
 			}
-			workHash, err := object.NewHashBlob(data)
+		}
 
-			if workHash.Equals(ie.Hash) {
-				continue
+		if workHash != nil && workHash.Equals(ie.Hash) {
+			if workMode != ie.Mode {
+				diffResult = append(diffResult, DiffResult{
+					File:  p,
+					AHash: ie.Hash,
+					BHash: workHash,
+					AMode: ie.Mode,
+					BMode: workMode,
+					Lines: []LineData{},
+				})
 			}
+			continue
+		}
 
+		// if file is a binary don't compare lines, just checks hashes
+		if ie.IsBinary || (workHash != nil && utils.IsBinary(data)) {
 			diffResult = append(diffResult, DiffResult{
 				File:  p,
 				AHash: ie.Hash,
 				BHash: workHash,
+				AMode: ie.Mode,
+				BMode: workMode,
 				Lines: nil,
 			})
 
@@ -95,31 +188,24 @@ func DiffWorktreeVsIndex(repoPath string, paths []string) ([]DiffResult, error)
 		}
 
 		// read index content via blob
-		indexBlob, err := object.ReadBlob(repoPath, ie.Hash)
+		indexLines, indexNoEOL, _, err := blobLines(repoPath, ie.Hash)
 		if err != nil {
 			return nil, err
 		}
 
-		indexLines, err := indexBlob.SplitLines()
-		if err != nil {
-			return nil, err
-		}
-
-		// read worktree content from file
-		workPath := filepath.FromSlash(p)
-		workLines, err := readFileContent(repoPath, workPath)
-		if err != nil {
-			return nil, err
-		}
-		if equalLines(indexLines, workLines) {
+		// worktree content as read above, no lines when deleted
+		lines := diffText(indexLines, indexNoEOL, splitLines(data), noEOL(data), opts)
+		if workHash != nil && !hasChanges(lines) && workMode == ie.Mode {
 			continue
 		}
 
 		diffResult = append(diffResult, DiffResult{
 			File:  p,
 			AHash: ie.Hash,
-			BHash: nil,
-			Lines: unifiedDiff(indexLines, workLines),
+			BHash: workHash,
+			AMode: ie.Mode,
+			BMode: workMode,
+			Lines: lines,
 		})
 	}
 
@@ -127,7 +213,7 @@ func DiffWorktreeVsIndex(repoPath string, paths []string) ([]DiffResult, error)
 }
 
 // DiffIndexVsHead diffs the index vs HEAD tree (staged changes).
-func DiffIndexVsHead(repoPath string, paths []string) ([]DiffResult, error) {
+func DiffIndexVsHead(repoPath string, opts Options) ([]DiffResult, error) {
 	idx, err := index.Load(repoPath)
 	if err != nil {
 		return nil, err
@@ -138,242 +224,304 @@ func DiffIndexVsHead(repoPath string, paths []string) ([]DiffResult, error) {
 		return nil, err
 	}
 
-	targets := pathsToTargetMap(paths)
-
-	// union of keys from idx and headMap
-	seen := map[string]struct{}{}
-	for p := range idx {
-		seen[p] = struct{}{}
+	headModes, err := tree.GetHeadTreeModes(repoPath)
+	if err != nil {
+		return nil, err
 	}
 
-	for p := range headMap {
-		seen[p] = struct{}{}
+	idxMap := make(map[string]object.ObjectHash, len(idx))
+	idxModes := make(map[string]object.FileMode, len(idx))
+	for p, ie := range idx {
+		idxMap[p] = ie.Hash
+		idxModes[p] = ie.Mode
 	}
 
-	diffResult := []DiffResult{}
-	for p := range seen {
-		if len(targets) > 0 {
-			if _, ok := targets[p]; !ok {
-				continue
-			}
-		}
-
-		ih := idx[p]
-		hh := headMap[p]
-
-		var idxLines, headLines []string
-
-		// if file is a binary don't compare lines, just checks hashes
-		if ih.IsBinary {
-			if ih.Hash.Equals(hh) {
-				continue
-			}
-
-			diffResult = append(diffResult, DiffResult{
-				File:  p,
-				AHash: hh,
-				BHash: ih.Hash,
-				Lines: nil,
-			})
-			continue
-		}
-
-		if ih.Hash != nil {
-			idxBlob, err := object.ReadBlob(repoPath, ih.Hash)
-			if err != nil {
-				return nil, err
-			}
-			idxLines, err = idxBlob.SplitLines()
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			idxLines = []string{}
-		}
-
-		if hh != nil {
-			headBlob, err := object.ReadBlob(repoPath, hh)
-			if err != nil {
-				return nil, err
-			}
-			headLines, err = headBlob.SplitLines()
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			headLines = []string{}
-		}
+	return diffMaps(repoPath, headMap, idxMap, headModes, idxModes, opts)
+}
 
-		if equalLines(idxLines, headLines) {
-			continue
-		}
+// DiffCommits diffs two revisions by comparing their trees
+func DiffCommits(repoPath, commitA, commitB string, opts Options) ([]DiffResult, error) {
+	treeA, err := commitTree(repoPath, commitA)
+	if err != nil {
+		return nil, err
+	}
 
-		diffResult = append(diffResult, DiffResult{
-			File:  p,
-			AHash: hh,
-			BHash: ih.Hash,
-			Lines: unifiedDiff(headLines, idxLines),
-		})
+	treeB, err := commitTree(repoPath, commitB)
+	if err != nil {
+		return nil, err
 	}
 
-	return diffResult, nil
+	return DiffTrees(repoPath, treeA, treeB, opts)
 }
 
-// diffCommits diff two commits by comparings their trees
-func DiffCommits(repoPath, commitA, commitB string, paths []string) ([]DiffResult, error) {
-	mapA, err := makeTreePathMap(repoPath, commitA)
+// DiffTrees diffs two trees, a nil tree is an empty tree. Results are sorted by path.
+func DiffTrees(repoPath string, treeA, treeB object.ObjectHash, opts Options) ([]DiffResult, error) {
+	mapA, modesA, err := treePathMap(repoPath, treeA)
 	if err != nil {
 		return nil, err
 	}
-	mapB, err := makeTreePathMap(repoPath, commitB)
+	mapB, modesB, err := treePathMap(repoPath, treeB)
 	if err != nil {
 		return nil, err
 	}
 
-	// union of keys
-	targets := pathsToTargetMap(paths)
+	return diffMaps(repoPath, mapA, mapB, modesA, modesB, opts)
+}
 
+// diffMaps diffs two path -> blob maps, with the modes of their files, sorted by path.
+func diffMaps(repoPath string, mapA, mapB map[string]object.ObjectHash, modesA, modesB map[string]object.FileMode, opts Options) ([]DiffResult, error) {
+	// union of keys
 	seen := map[string]struct{}{}
 	for p := range mapA {
 		seen[p] = struct{}{}
 	}
 
-	for p := range mapA {
+	for p := range mapB {
 		seen[p] = struct{}{}
 	}
 
 	diffResult := []DiffResult{}
-	for p := range seen {
-		if len(targets) > 0 {
-			if _, ok := targets[p]; !ok {
-				continue
-			}
+	for _, p := range sortedKeys(seen) {
+		if !pathspec.Match(opts.Paths, p) {
+			continue
 		}
 
-		aHash := mapA[p]
-		bHash := mapB[p]
-		var aLines, bLines []string
-		if aHash != nil {
-			blob, err := object.ReadBlob(repoPath, aHash)
-			if err != nil {
-				return nil, err
-			}
+		r, err := diffBlobs(repoPath, p, mapA[p], mapB[p], modesA[p], modesB[p], opts)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			diffResult = append(diffResult, *r)
+		}
+	}
 
-			if utils.IsBinary(blob.Data()) {
-				if aHash.Equals(bHash) {
-					continue
-				}
+	if !opts.Renames && !opts.Copies {
+		return diffResult, nil
+	}
 
-				diffResult = append(diffResult, DiffResult{
-					File:  p,
-					AHash: aHash,
-					BHash: bHash,
-					Lines: nil,
-				})
-				continue
-			}
+	return withRenames(repoPath, diffResult, mapA, modesA, modesB, opts)
+}
 
-			aLines, err = blob.SplitLines()
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			aLines = []string{}
+// diffBlobs compares two versions of a file, nil hashes are missing files.
+// It returns nil when they have the same content, as compared with opts, and
+// the same mode. A mode-only change has no lines.
+func diffBlobs(repoPath, file string, aHash, bHash object.ObjectHash, aMode, bMode object.FileMode, opts Options) (*DiffResult, error) {
+	if aHash != nil && aHash.Equals(bHash) {
+		if aMode == bMode {
+			return nil, nil
 		}
+		return &DiffResult{File: file, AHash: aHash, BHash: bHash, AMode: aMode, BMode: bMode, Lines: []LineData{}}, nil
+	}
 
-		if bHash != nil {
-			blob, err := object.ReadBlob(repoPath, bHash)
-			if err != nil {
-				return nil, err
-			}
+	aLines, aNoEOL, aBinary, err := blobLines(repoPath, aHash)
+	if err != nil {
+		return nil, err
+	}
+
+	bLines, bNoEOL, bBinary, err := blobLines(repoPath, bHash)
+	if err != nil {
+		return nil, err
+	}
 
-			if utils.IsBinary(blob.Data()) {
-				if bHash.Equals(aHash) {
-					continue
-				}
+	if aBinary || bBinary {
+		return &DiffResult{
+			File:  file,
+			AHash: aHash,
+			BHash: bHash,
+			AMode: aMode,
+			BMode: bMode,
+			Lines: nil,
+		}, nil
+	}
 
-				diffResult = append(diffResult, DiffResult{
-					File:  p,
-					AHash: aHash,
-					BHash: bHash,
-					Lines: nil,
-				})
-				continue
-			}
+	// files that are added or deleted are changes even when empty
+	lines := diffText(aLines, aNoEOL, bLines, bNoEOL, opts)
+	if aHash != nil && bHash != nil && !hasChanges(lines) && aMode == bMode {
+		return nil, nil
+	}
 
-			bLines, err = blob.SplitLines()
-			if err != nil {
-				return nil, err
+	return &DiffResult{
+		File:  file,
+		AHash: aHash,
+		BHash: bHash,
+		AMode: aMode,
+		BMode: bMode,
+		Lines: lines,
+	}, nil
+}
+
+// withRenames replaces the deletions and additions that are renames (or the additions
+// that are copies of a file of mapA) by a single result from the old to the new file.
+func withRenames(repoPath string, results []DiffResult, mapA map[string]object.ObjectHash, modesA, modesB map[string]object.FileMode, opts Options) ([]DiffResult, error) {
+	deleted := map[string]object.ObjectHash{}
+	added := map[string]object.ObjectHash{}
+	for _, r := range results {
+		switch {
+		case r.BHash == nil:
+			deleted[r.File] = r.AHash
+		case r.AHash == nil:
+			added[r.File] = r.BHash
+		}
+	}
+
+	var copySources map[string]object.ObjectHash
+	if opts.Copies {
+		copySources = map[string]object.ObjectHash{}
+		for p, h := range mapA {
+			if _, gone := deleted[p]; !gone && pathspec.Match(opts.Paths, p) {
+				copySources[p] = h
 			}
+		}
+	}
+
+	renames, err := DetectRenames(repoPath, deleted, added, copySources, opts.RenameThreshold)
+	if err != nil {
+		return nil, err
+	}
+	if len(renames) == 0 {
+		return results, nil
+	}
+
+	replaced := map[string]struct{}{}
+	out := []DiffResult{}
+	for _, rn := range renames {
+		fromHash := deleted[rn.From]
+		if rn.Copy {
+			fromHash = copySources[rn.From]
 		} else {
-			bLines = []string{}
+			replaced[rn.From] = struct{}{}
 		}
+		replaced[rn.To] = struct{}{}
 
-		if equalLines(aLines, bLines) {
-			continue
+		r, err := diffBlobs(repoPath, rn.To, fromHash, added[rn.To], modesA[rn.From], modesB[rn.To], opts)
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			// same content, nothing but the name changed
+			r = &DiffResult{File: rn.To, AHash: fromHash, BHash: added[rn.To], AMode: modesA[rn.From], BMode: modesB[rn.To], Lines: []LineData{}}
 		}
 
-		diffResult = append(diffResult, DiffResult{
-			File:  p,
-			AHash: aHash,
-			BHash: bHash,
-			Lines: unifiedDiff(aLines, bLines),
-		})
+		r.OldFile = rn.From
+		r.Similarity = rn.Similarity
+		r.Copied = rn.Copy
+		out = append(out, *r)
 	}
 
-	return diffResult, nil
+	for _, r := range results {
+		if _, ok := replaced[r.File]; !ok {
+			out = append(out, r)
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].File < out[j].File
+	})
+	return out, nil
 }
 
-func makeTreePathMap(repoPath, commitHash string) (map[string]object.ObjectHash, error) {
-	m := map[string]object.ObjectHash{}
-	if len(commitHash) == 0 {
-		return nil, fmt.Errorf("invalid commit '%s'", commitHash)
+// blobLines returns the lines of a blob, or no lines for a nil hash, if the last
+// one does not end with a newline and if it is binary.
+func blobLines(repoPath string, hash object.ObjectHash) ([]string, bool, bool, error) {
+	if hash == nil {
+		return []string{}, false, false, nil
 	}
 
-	hash, err := object.NewObjectHash(commitHash)
+	blob, err := object.ReadBlob(repoPath, hash)
 	if err != nil {
-		return nil, err
+		return nil, false, false, err
 	}
 
-	commit, err := object.ReadCommit(repoPath, hash)
+	if utils.IsBinary(blob.Data()) {
+		return nil, false, true, nil
+	}
+
+	return splitLines(blob.Data()), noEOL(blob.Data()), false, nil
+}
+
+func commitTree(repoPath, rev string) (object.ObjectHash, error) {
+	if len(rev) == 0 {
+		return nil, fmt.Errorf("invalid commit '%s'", rev)
+	}
+
+	hash, err := revision.Resolve(repoPath, rev)
 	if err != nil {
 		return nil, err
 	}
 
-	tree, err := object.ReadTree(repoPath, commit.TreeHash())
+	commit, err := object.ReadCommit(repoPath, hash)
 	if err != nil {
 		return nil, err
 	}
-	tree.FillPathMap(m)
-	return m, nil
+	return commit.TreeHash(), nil
 }
 
-func equalLines(a, b []string) bool {
-	if len(a) != len(b) {
-		return false
+// treePathMap maps every file path of a tree to its blob and to its mode, a nil
+// tree is empty.
+func treePathMap(repoPath string, treeHash object.ObjectHash) (map[string]object.ObjectHash, map[string]object.FileMode, error) {
+	m := map[string]object.ObjectHash{}
+	modes := map[string]object.FileMode{}
+	if treeHash == nil {
+		return m, modes, nil
 	}
 
-	for i := range a {
-		if a[i] != b[i] {
-			return false
-		}
+	tree, err := object.ReadTree(repoPath, treeHash)
+	if err != nil {
+		return nil, nil, err
 	}
+	tree.FillPathMap(m)
+	tree.FillModeMap(modes)
+	return m, modes, nil
+}
 
-	return true
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-func pathsToTargetMap(paths []string) map[string]struct{} {
-	targets := map[string]struct{}{}
-	for _, p := range paths {
-		targets[filepath.ToSlash(p)] = struct{}{}
+// hasChanges reports if any line was added or removed.
+func hasChanges(lines []LineData) bool {
+	for _, ld := range lines {
+		if ld.Result != EqLine {
+			return true
+		}
 	}
-	return targets
+	return false
 }
 
 // unifiedDiff produces a simple unified diff between a and b.
 // it uses LCS to compute inserts/deletes. Context lines are not collapsed.
-func unifiedDiff(aLines, bLines []string) []LineData {
+// Lines are compared as set in the whitespace options, equal lines show as in b.
+func unifiedDiff(aLines, bLines []string, opts Options) []LineData {
+	return diffText(aLines, false, bLines, false, opts)
+}
+
+// diffText is unifiedDiff for the lines of two files, aNoEOL and bNoEOL tell if
+// their last line does not end with a newline. Such a line differs from the
+// same line with a newline and is marked NoEOL.
+func diffText(aLines []string, aNoEOL bool, bLines []string, bNoEOL bool, opts Options) []LineData {
 	n, m := len(aLines), len(bLines)
+	aKeys, bKeys := aLines, bLines
+	if opts.ignoresWhitespace() || aNoEOL || bNoEOL {
+		aKeys, bKeys = make([]string, n), make([]string, m)
+		for i, l := range aLines {
+			aKeys[i] = opts.lineKey(l)
+		}
+		for i, l := range bLines {
+			bKeys[i] = opts.lineKey(l)
+		}
+		if aNoEOL && n > 0 {
+			aKeys[n-1] += eolMark
+		}
+		if bNoEOL && m > 0 {
+			bKeys[m-1] += eolMark
+		}
+	}
+
 	dp := make([][]int, n+1)
 
 	for i := 0; i <= n; i++ {
@@ -382,7 +530,7 @@ func unifiedDiff(aLines, bLines []string) []LineData {
 
 	for i := n - 1; i >= 0; i-- {
 		for j := m - 1; j >= 0; j-- {
-			if aLines[i] == bLines[j] {
+			if aKeys[i] == bKeys[j] {
 				dp[i][j] = dp[i+1][j+1] + 1
 				continue
 			}
@@ -400,12 +548,13 @@ func unifiedDiff(aLines, bLines []string) []LineData {
 	i, j := 0, 0
 	for i < n || j < m {
 
-		if i < n && j < m && aLines[i] == bLines[j] {
+		if i < n && j < m && aKeys[i] == bKeys[j] {
 			out = append(out, LineData{
 				ALine:      aLines[i],
 				BLine:      bLines[j],
-				ResultLine: aLines[i],
+				ResultLine: bLines[j],
 				Result:     EqLine,
+				NoEOL:      bNoEOL && j == m-1,
 			})
 			i++
 			j++
@@ -422,6 +571,7 @@ func unifiedDiff(aLines, bLines []string) []LineData {
 				BLine:      bLine,
 				ResultLine: aLines[i],
 				Result:     RemovedLine,
+				NoEOL:      aNoEOL && i == n-1,
 			})
 			i++
 			continue
@@ -437,11 +587,37 @@ func unifiedDiff(aLines, bLines []string) []LineData {
 				BLine:      bLines[j],
 				ResultLine: bLines[j],
 				Result:     AddedLine,
+				NoEOL:      bNoEOL && j == m-1,
 			})
 			j++
 			continue
 		}
 	}
 
+	if opts.IgnoreBlankLines {
+		return withoutBlankChanges(out)
+	}
 	return out
 }
+
+// withoutBlankChanges drops removed blank lines and keeps added ones as context.
+func withoutBlankChanges(lines []LineData) []LineData {
+	out := make([]LineData, 0, len(lines))
+	for _, ld := range lines {
+		if ld.Result == EqLine || len(strings.TrimSpace(ld.ResultLine)) > 0 {
+			out = append(out, ld)
+			continue
+		}
+
+		if ld.Result == AddedLine {
+			ld.Result = EqLine
+			out = append(out, ld)
+		}
+	}
+	return out
+}
+
+// DiffLines compares two lists of lines with the same algorithm used for files.
+func DiffLines(aLines, bLines []string) []LineData {
+	return unifiedDiff(aLines, bLines, Options{})
+}
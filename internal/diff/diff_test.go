@@ -0,0 +1,40 @@
+package diff
+
+import "testing"
+
+func TestDiffTextFinalNewline(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []LineData
+	}{
+		{"same", "a\nb", "a\nb", []LineData{
+			{ALine: "a", BLine: "a", ResultLine: "a", Result: EqLine},
+			{ALine: "b", BLine: "b", ResultLine: "b", Result: EqLine, NoEOL: true},
+		}},
+		{"added", "a\nb", "a\nb\n", []LineData{
+			{ALine: "a", BLine: "a", ResultLine: "a", Result: EqLine},
+			{ALine: "b", BLine: "b", ResultLine: "b", Result: RemovedLine, NoEOL: true},
+			{ALine: "", BLine: "b", ResultLine: "b", Result: AddedLine},
+		}},
+		{"removed", "a\n", "a", []LineData{
+			{ALine: "a", BLine: "a", ResultLine: "a", Result: RemovedLine},
+			{ALine: "", BLine: "a", ResultLine: "a", Result: AddedLine, NoEOL: true},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := []byte(tt.a), []byte(tt.b)
+			got := diffText(splitLines(a), noEOL(a), splitLines(b), noEOL(b), Options{})
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("line %d: got %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
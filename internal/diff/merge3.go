@@ -0,0 +1,104 @@
+package diff
+
+import "strings"
+
+// Merge3 merges the changes made from base in ours and in theirs, line by line.
+// Lines changed differently on both sides are written between conflict markers
+// labeled oursName and theirsName, and the result reports a conflict.
+func Merge3(base, ours, theirs []byte, oursName, theirsName string) ([]byte, bool) {
+	baseLines, ourLines, theirLines := mergeLines(base), mergeLines(ours), mergeLines(theirs)
+	inOurs := matchLines(baseLines, ourLines)
+	inTheirs := matchLines(baseLines, theirLines)
+
+	out := []string{}
+	conflict := false
+	i, j, k := 0, 0, 0
+	for i < len(baseLines) || j < len(ourLines) || k < len(theirLines) {
+		// next base line both sides kept
+		b := i
+		for b < len(baseLines) && (inOurs[b] < 0 || inTheirs[b] < 0) {
+			b++
+		}
+
+		if b == i && b < len(baseLines) && inOurs[b] == j && inTheirs[b] == k {
+			out = append(out, baseLines[i])
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+
+		// the lines up to it changed on one side at least
+		nextJ, nextK := len(ourLines), len(theirLines)
+		if b < len(baseLines) {
+			nextJ, nextK = inOurs[b], inTheirs[b]
+		}
+		baseChunk, ourChunk, theirChunk := baseLines[i:b], ourLines[j:nextJ], theirLines[k:nextK]
+
+		switch {
+		case sameLines(baseChunk, ourChunk):
+			out = append(out, theirChunk...)
+		case sameLines(baseChunk, theirChunk), sameLines(ourChunk, theirChunk):
+			out = append(out, ourChunk...)
+		default:
+			out = append(out, "<<<<<<< "+oursName)
+			out = append(out, ourChunk...)
+			out = append(out, "=======")
+			out = append(out, theirChunk...)
+			out = append(out, ">>>>>>> "+theirsName)
+			conflict = true
+		}
+		i, j, k = b, nextJ, nextK
+	}
+
+	// the result ends without a newline only if its last line had none
+	var sb strings.Builder
+	for n, l := range out {
+		l, marked := strings.CutSuffix(l, eolMark)
+		sb.WriteString(l)
+		if !marked || n < len(out)-1 {
+			sb.WriteString("\n")
+		}
+	}
+	return []byte(sb.String()), conflict
+}
+
+// mergeLines splits data in lines, a last line without a newline ends with
+// eolMark so that it only matches the same line without a newline.
+func mergeLines(data []byte) []string {
+	lines := splitLines(data)
+	if noEOL(data) {
+		lines[len(lines)-1] += eolMark
+	}
+	return lines
+}
+
+// matchLines maps each line of a to the line of b it is kept as, -1 when it was removed.
+func matchLines(a, b []string) []int {
+	match := make([]int, len(a))
+	ai, bi := 0, 0
+	for _, ld := range DiffLines(a, b) {
+		switch ld.Result {
+		case EqLine:
+			match[ai] = bi
+			ai++
+			bi++
+		case RemovedLine:
+			match[ai] = -1
+			ai++
+		case AddedLine:
+			bi++
+		}
+	}
+	return match
+}
+
+func sameLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
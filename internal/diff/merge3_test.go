@@ -0,0 +1,66 @@
+package diff
+
+import "testing"
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		conflict           bool
+	}{
+		{
+			name: "ours only", base: "a\nb\nc\n", ours: "a\nB\nc\n", theirs: "a\nb\nc\n",
+			want: "a\nB\nc\n",
+		},
+		{
+			name: "theirs only", base: "a\nb\nc\n", ours: "a\nb\nc\n", theirs: "a\nb\nC\n",
+			want: "a\nb\nC\n",
+		},
+		{
+			name: "both apart", base: "a\nb\nc\nd\n", ours: "A\nb\nc\nd\n", theirs: "a\nb\nc\nD\n",
+			want: "A\nb\nc\nD\n",
+		},
+		{
+			name: "same change", base: "a\nb\n", ours: "a\nB\n", theirs: "a\nB\n",
+			want: "a\nB\n",
+		},
+		{
+			name: "conflict", base: "a\nb\nc\n", ours: "a\nours\nc\n", theirs: "a\ntheirs\nc\n",
+			want:     "a\n<<<<<<< local\nours\n=======\ntheirs\n>>>>>>> main\nc\n",
+			conflict: true,
+		},
+		{
+			name: "conflict with a removal", base: "a\nb\n", ours: "a\n", theirs: "a\nB\n",
+			want:     "a\n<<<<<<< local\n=======\nB\n>>>>>>> main\n",
+			conflict: true,
+		},
+		{
+			name: "added on both sides", base: "", ours: "x\n", theirs: "y\n",
+			want:     "<<<<<<< local\nx\n=======\ny\n>>>>>>> main\n",
+			conflict: true,
+		},
+		{
+			name: "no final newline kept", base: "a\nb", ours: "A\nb", theirs: "a\nb",
+			want: "A\nb",
+		},
+		{
+			name: "final newline added", base: "a\nb", ours: "a\nb", theirs: "a\nb\n",
+			want: "a\nb\n",
+		},
+		{
+			name: "no final newline in a conflict", base: "a\nb", ours: "a\nours", theirs: "a\ntheirs",
+			want:     "a\n<<<<<<< local\nours\n=======\ntheirs\n>>>>>>> main\n",
+			conflict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflict := Merge3([]byte(tt.base), []byte(tt.ours), []byte(tt.theirs), "local", "main")
+			if string(got) != tt.want || conflict != tt.conflict {
+				t.Errorf("got %q (conflict %v), want %q (conflict %v)", got, conflict, tt.want, tt.conflict)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/config"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// DefaultRenameThreshold is the minimum similarity, in percent, for two files to be a rename
+const DefaultRenameThreshold = 50
+
+// renameLimit caps the pairs compared by content, past it only exact renames are found
+const renameLimit = 1000 * 1000
+
+// Rename pairs a file that disappeared (or still exists, for a copy) with a new one.
+type Rename struct {
+	From string
+	To   string
+	// Similarity is the percentage of content in common
+	Similarity int
+	Copy       bool
+}
+
+// DetectRenames pairs deleted and added files (path -> blob) by content: exact hash
+// matches first, then the most similar pairs with at least threshold percent of
+// their lines in common. Each deleted file is the source of one rename at most.
+// copySources are files that still exist and may have been copied into an added
+// file; nil disables copy detection.
+func DetectRenames(repoPath string, deleted, added, copySources map[string]object.ObjectHash, threshold int) ([]Rename, error) {
+	if threshold <= 0 {
+		threshold = DefaultRenameThreshold
+	}
+
+	renames := []Rename{}
+	usedDeleted := map[string]bool{}
+	pending := []string{}
+
+	byHash := map[string][]string{}
+	for _, p := range sortedPaths(deleted) {
+		byHash[deleted[p].String()] = append(byHash[deleted[p].String()], p)
+	}
+	copyByHash := map[string]string{}
+	for _, p := range sortedPaths(copySources) {
+		if _, ok := copyByHash[copySources[p].String()]; !ok {
+			copyByHash[copySources[p].String()] = p
+		}
+	}
+
+	// exact matches
+	for _, to := range sortedPaths(added) {
+		h := added[to].String()
+		if from := firstUnused(byHash[h], usedDeleted); len(from) > 0 {
+			usedDeleted[from] = true
+			renames = append(renames, Rename{From: from, To: to, Similarity: 100})
+			continue
+		}
+		if from, ok := copyByHash[h]; ok {
+			renames = append(renames, Rename{From: from, To: to, Similarity: 100, Copy: true})
+			continue
+		}
+		pending = append(pending, to)
+	}
+
+	sources := []string{}
+	for _, p := range sortedPaths(deleted) {
+		if !usedDeleted[p] {
+			sources = append(sources, p)
+		}
+	}
+	copyFrom := sortedPaths(copySources)
+
+	if len(pending)*(len(sources)+len(copyFrom)) > renameLimit {
+		return renames, nil
+	}
+
+	content := newLineCache(repoPath)
+	type candidate struct {
+		from, to string
+		score    int
+		copy     bool
+	}
+	candidates := []candidate{}
+	for _, to := range pending {
+		toLines, ok, err := content.lines(added[to])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		for _, group := range []struct {
+			paths  []string
+			hashes map[string]object.ObjectHash
+			copy   bool
+		}{{sources, deleted, false}, {copyFrom, copySources, true}} {
+			for _, from := range group.paths {
+				fromLines, ok, err := content.lines(group.hashes[from])
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					continue
+				}
+
+				if score := similarity(fromLines, toLines); score >= threshold {
+					candidates = append(candidates, candidate{from: from, to: to, score: score, copy: group.copy})
+				}
+			}
+		}
+	}
+
+	// best pairs first; renames win over copies of the same score
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return !candidates[i].copy && candidates[j].copy
+	})
+
+	usedAdded := map[string]bool{}
+	for _, c := range candidates {
+		if usedAdded[c.to] || (!c.copy && usedDeleted[c.from]) {
+			continue
+		}
+
+		usedAdded[c.to] = true
+		if !c.copy {
+			usedDeleted[c.from] = true
+		}
+		renames = append(renames, Rename{From: c.from, To: c.to, Similarity: c.score, Copy: c.copy})
+	}
+
+	sort.Slice(renames, func(i, j int) bool {
+		return renames[i].To < renames[j].To
+	})
+	return renames, nil
+}
+
+// similarity is the percentage of lines two files have in common, in any order.
+func similarity(a, b []string) int {
+	if len(a)+len(b) == 0 {
+		return 0
+	}
+
+	counts := map[string]int{}
+	for _, l := range a {
+		counts[l]++
+	}
+
+	common := 0
+	for _, l := range b {
+		if counts[l] > 0 {
+			counts[l]--
+			common++
+		}
+	}
+
+	return common * 2 * 100 / (len(a) + len(b))
+}
+
+// lineCache reads each blob once. Binary and empty blobs are only renamed on exact matches.
+type lineCache struct {
+	repoPath string
+	cache    map[string][]string
+}
+
+func newLineCache(repoPath string) *lineCache {
+	return &lineCache{repoPath: repoPath, cache: map[string][]string{}}
+}
+
+func (c *lineCache) lines(hash object.ObjectHash) ([]string, bool, error) {
+	if l, ok := c.cache[hash.String()]; ok {
+		return l, l != nil, nil
+	}
+
+	blob, err := object.ReadBlob(c.repoPath, hash)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var lines []string
+	if len(blob.Data()) > 0 && !utils.IsBinary(blob.Data()) {
+		lines, err = blob.SplitLines()
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	c.cache[hash.String()] = lines
+	return lines, lines != nil, nil
+}
+
+func firstUnused(paths []string, used map[string]bool) string {
+	for _, p := range paths {
+		if !used[p] {
+			return p
+		}
+	}
+	return ""
+}
+
+func sortedPaths(m map[string]object.ObjectHash) []string {
+	paths := make([]string, 0, len(m))
+	for p := range m {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// ParseThreshold reads a similarity threshold like "50%" or "50".
+func ParseThreshold(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "%"))
+	if err != nil || n < 0 || n > 100 {
+		return 0, fmt.Errorf("invalid similarity threshold %q, expected a percentage like 50%%", s)
+	}
+	return n, nil
+}
+
+// RenamesFromConfig reads diff.renames: true (the default) detects renames,
+// "copies" renames and copies, and false neither.
+func RenamesFromConfig(repoPath string) (renames bool, copies bool, err error) {
+	v, err := config.GetDefault(repoPath, "diff.renames", "true")
+	if err != nil {
+		return false, false, err
+	}
+
+	switch strings.ToLower(v) {
+	case "copies", "copy":
+		return true, true, nil
+	case "false", "no", "off", "0":
+		return false, false, nil
+	default:
+		return true, false, nil
+	}
+}
@@ -0,0 +1,89 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/repo"
+)
+
+func writeBlob(t *testing.T, data string) object.ObjectHash {
+	t.Helper()
+	hash, err := object.WriteBlobData(".", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestDetectRenamesThreshold(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+
+	old := writeBlob(t, "a\nb\nc\nd\n")
+	// 3 of 4 lines in common
+	edited := writeBlob(t, "a\nb\nc\nX\n")
+	other := writeBlob(t, "w\nx\ny\nz\n")
+
+	tests := []struct {
+		name      string
+		added     object.ObjectHash
+		copies    bool
+		threshold int
+		want      []Rename
+	}{
+		{"exact", old, false, 100, []Rename{{From: "old", To: "new", Similarity: 100}}},
+		{"default threshold", edited, false, 0, []Rename{{From: "old", To: "new", Similarity: 75}}},
+		{"at threshold", edited, false, 75, []Rename{{From: "old", To: "new", Similarity: 75}}},
+		{"above similarity", edited, false, 76, []Rename{}},
+		{"unrelated", other, false, 1, []Rename{}},
+		{"copy", edited, true, 50, []Rename{{From: "kept", To: "new", Similarity: 100, Copy: true}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted := map[string]object.ObjectHash{"old": old}
+			var sources map[string]object.ObjectHash
+			if tt.copies {
+				sources = map[string]object.ObjectHash{"kept": edited}
+			}
+
+			got, err := DetectRenames(".", deleted, map[string]object.ObjectHash{"new": tt.added}, sources, tt.threshold)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got %v, want %v", got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"50%", 50, true},
+		{"90", 90, true},
+		{"100%", 100, true},
+		{"101%", 0, false},
+		{"-1", 0, false},
+		{"half", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseThreshold(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseThreshold(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
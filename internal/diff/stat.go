@@ -0,0 +1,56 @@
+package diff
+
+// File statuses, as in git's --name-status
+const (
+	StatusAdded    = "A"
+	StatusModified = "M"
+	StatusDeleted  = "D"
+	StatusRenamed  = "R"
+	StatusCopied   = "C"
+)
+
+type FileStat struct {
+	File string
+	// OldFile and Similarity are set for renames and copies
+	OldFile    string
+	Similarity int
+	Status     string
+	Added      int
+	Removed    int
+	// Binary files have no line counts
+	Binary bool
+}
+
+// Stat counts the lines added and removed in a file.
+func Stat(r DiffResult) FileStat {
+	fs := FileStat{File: r.File, OldFile: r.OldFile, Similarity: r.Similarity, Status: StatusModified, Binary: r.Lines == nil}
+	switch {
+	case r.Copied:
+		fs.Status = StatusCopied
+	case len(r.OldFile) > 0:
+		fs.Status = StatusRenamed
+	case r.AHash == nil:
+		fs.Status = StatusAdded
+	case r.BHash == nil:
+		fs.Status = StatusDeleted
+	}
+
+	for _, l := range r.Lines {
+		switch l.Result {
+		case AddedLine:
+			fs.Added++
+		case RemovedLine:
+			fs.Removed++
+		}
+	}
+	return fs
+}
+
+// Stats returns the stat of every result, in the same order.
+func Stats(results []DiffResult) []FileStat {
+	stats := make([]FileStat, 0, len(results))
+	for _, r := range results {
+		stats = append(stats, Stat(r))
+	}
+	return stats
+}
@@ -0,0 +1,76 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/matiasmartin00/arbor/internal/config"
+)
+
+// DefaultWordRegex takes runs of non-whitespace as words
+const DefaultWordRegex = `\S+`
+
+// WordSegment is a piece of text of a word diff, it may hold line breaks.
+type WordSegment struct {
+	Text   string
+	Result LineResult
+}
+
+// WordDiff compares the removed and added lines of a change word by word. Words
+// are the matches of re, the text between them is compared too so the segments
+// rebuild both sides. Adjacent segments with the same result are joined.
+func WordDiff(removed, added []string, re *regexp.Regexp) []WordSegment {
+	out := []WordSegment{}
+	for _, ld := range unifiedDiff(words(removed, re), words(added, re), Options{}) {
+		if n := len(out); n > 0 && out[n-1].Result == ld.Result {
+			out[n-1].Text += ld.ResultLine
+			continue
+		}
+		out = append(out, WordSegment{Text: ld.ResultLine, Result: ld.Result})
+	}
+	return out
+}
+
+// words splits lines in the matches of re and the text between them, with a
+// "\n" token between lines.
+func words(lines []string, re *regexp.Regexp) []string {
+	out := []string{}
+	for i, l := range lines {
+		if i > 0 {
+			out = append(out, "\n")
+		}
+
+		last := 0
+		for _, m := range re.FindAllStringIndex(l, -1) {
+			if m[0] == m[1] {
+				continue
+			}
+			if m[0] > last {
+				out = append(out, l[last:m[0]])
+			}
+			out = append(out, l[m[0]:m[1]])
+			last = m[1]
+		}
+		if last < len(l) {
+			out = append(out, l[last:])
+		}
+	}
+	return out
+}
+
+// WordRegex compiles expr, or diff.wordRegex when empty, or DefaultWordRegex.
+func WordRegex(repoPath, expr string) (*regexp.Regexp, error) {
+	if len(expr) == 0 {
+		v, err := config.GetDefault(repoPath, "diff.wordRegex", DefaultWordRegex)
+		if err != nil {
+			return nil, err
+		}
+		expr = v
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid word regex: %w", err)
+	}
+	return re, nil
+}
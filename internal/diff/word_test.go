@@ -0,0 +1,90 @@
+package diff
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestWordDiff(t *testing.T) {
+	tests := []struct {
+		name           string
+		removed, added []string
+		regex          string
+		want           []WordSegment
+	}{
+		{
+			name:    "changed word",
+			removed: []string{"the quick fox"},
+			added:   []string{"the slow fox"},
+			regex:   DefaultWordRegex,
+			want: []WordSegment{
+				{"the ", EqLine}, {"quick", RemovedLine}, {"slow", AddedLine}, {" fox", EqLine},
+			},
+		},
+		{
+			name:    "added words",
+			removed: []string{"a c"},
+			added:   []string{"a b c"},
+			regex:   DefaultWordRegex,
+			want: []WordSegment{
+				{"a ", EqLine}, {"b ", AddedLine}, {"c", EqLine},
+			},
+		},
+		{
+			name:    "across lines",
+			removed: []string{"one", "two"},
+			added:   []string{"one", "2"},
+			regex:   DefaultWordRegex,
+			want: []WordSegment{
+				{"one\n", EqLine}, {"two", RemovedLine}, {"2", AddedLine},
+			},
+		},
+		{
+			name:    "regex",
+			removed: []string{"f(a,b)"},
+			added:   []string{"f(a,c)"},
+			regex:   `\w+`,
+			want: []WordSegment{
+				{"f(a,", EqLine}, {"b", RemovedLine}, {"c", AddedLine}, {")", EqLine},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WordDiff(tt.removed, tt.added, regexp.MustCompile(tt.regex))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("segment %d: got %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWhitespaceOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    []string
+		opts    Options
+		changes bool
+	}{
+		{"all space", []string{"a b"}, []string{" a  b\t"}, Options{IgnoreAllSpace: true}, false},
+		{"space change", []string{"a b"}, []string{"a   b "}, Options{IgnoreSpaceChange: true}, false},
+		{"space change keeps indent", []string{"a b"}, []string{"  a b"}, Options{IgnoreSpaceChange: true}, true},
+		{"blank lines", []string{"a", "b"}, []string{"a", "", "b"}, Options{IgnoreBlankLines: true}, false},
+		{"cr at eol", []string{"a"}, []string{"a\r"}, Options{IgnoreCRAtEOL: true}, false},
+		{"none", []string{"a b"}, []string{"a  b"}, Options{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasChanges(unifiedDiff(tt.a, tt.b, tt.opts)); got != tt.changes {
+				t.Errorf("changes = %v, want %v", got, tt.changes)
+			}
+		})
+	}
+}
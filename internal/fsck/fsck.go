@@ -0,0 +1,114 @@
+package fsck
+
+import (
+	"sort"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/reach"
+)
+
+type Corrupt struct {
+	Hash object.ObjectHash
+	Err  error
+}
+
+type Dangling struct {
+	Hash object.ObjectHash
+	Type object.ObjectType
+}
+
+type Report struct {
+	// Checked is the number of objects in the store
+	Checked int
+	// Invalid are files in the objects directory that can not be objects
+	Invalid []string
+	// Corrupt are objects whose content does not match their name or does not parse
+	Corrupt []Corrupt
+	// Missing are objects referenced from a root or another object that are not in the store
+	Missing []reach.Link
+	// Dangling are unreachable objects no other object points to
+	Dangling []Dangling
+}
+
+// OK reports if the repository has no corruption. Dangling objects are not corruption.
+func (r Report) OK() bool {
+	return len(r.Invalid) == 0 && len(r.Corrupt) == 0 && len(r.Missing) == 0
+}
+
+// Fsck verifies every object in the store and the connectivity from the refs,
+// HEAD, MERGE_HEAD and the index.
+func Fsck(repoPath string) (Report, error) {
+	hashes, invalid, err := object.ListObjects(repoPath)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{Checked: len(hashes), Invalid: invalid}
+
+	types := map[string]object.ObjectType{}
+	corrupt := map[string]struct{}{}
+	for _, h := range hashes {
+		t, err := object.VerifyObject(repoPath, h)
+		if err != nil {
+			report.Corrupt = append(report.Corrupt, Corrupt{Hash: h, Err: err})
+			corrupt[h.String()] = struct{}{}
+			continue
+		}
+		types[h.String()] = t
+	}
+
+	roots, err := reach.Roots(repoPath)
+	if err != nil {
+		return Report{}, err
+	}
+
+	marked := reach.Mark(repoPath, roots)
+	report.Missing = marked.Missing
+
+	// objects that are read as another type than the one they are referenced as
+	for h, err := range marked.Broken {
+		if _, ok := corrupt[h]; ok {
+			continue
+		}
+		hash, _ := object.NewObjectHash(h)
+		report.Corrupt = append(report.Corrupt, Corrupt{Hash: hash, Err: err})
+		corrupt[h] = struct{}{}
+	}
+
+	// objects pointed to by unreachable objects are not dangling, only the tips are
+	referenced := map[string]struct{}{}
+	for h, t := range types {
+		if _, ok := marked.Reachable[h]; ok {
+			continue
+		}
+
+		hash, _ := object.NewObjectHash(h)
+		links, err := reach.Links(repoPath, hash, t)
+		if err != nil {
+			continue
+		}
+		for _, l := range links {
+			referenced[l.Hash.String()] = struct{}{}
+		}
+	}
+
+	for h, t := range types {
+		_, isReachable := marked.Reachable[h]
+		_, isReferenced := referenced[h]
+		if isReachable || isReferenced {
+			continue
+		}
+
+		hash, _ := object.NewObjectHash(h)
+		report.Dangling = append(report.Dangling, Dangling{Hash: hash, Type: t})
+	}
+
+	sort.Slice(report.Corrupt, func(i, j int) bool {
+		return report.Corrupt[i].Hash.String() < report.Corrupt[j].Hash.String()
+	})
+	sort.Slice(report.Dangling, func(i, j int) bool {
+		return report.Dangling[i].Hash.String() < report.Dangling[j].Hash.String()
+	})
+
+	return report, nil
+}
@@ -0,0 +1,148 @@
+package fsck
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/add"
+	"github.com/matiasmartin00/arbor/internal/commit"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/repo"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// newRepo creates a repository with one commit of f in a temporary directory
+// and moves into it. It returns the commit.
+func newRepo(t *testing.T) object.ObjectHash {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("ARBOR_CONFIG_USER__NAME", "T")
+	t.Setenv("ARBOR_CONFIG_USER__EMAIL", "t@x")
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile("f", []byte("committed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := add.Add(".", false, []string{"f"}); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := commit.Commit(".", commit.CommitOptions{Message: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func fsck(t *testing.T) Report {
+	t.Helper()
+	report, err := Fsck(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestFsckSound(t *testing.T) {
+	newRepo(t)
+
+	report := fsck(t)
+	if !report.OK() || len(report.Dangling) != 0 {
+		t.Errorf("got %+v for a sound repository", report)
+	}
+	// the blob, the tree and the commit
+	if report.Checked != 3 {
+		t.Errorf("checked %d objects, want 3", report.Checked)
+	}
+}
+
+func TestFsckDangling(t *testing.T) {
+	head := newRepo(t)
+	c, err := object.ReadCommit(".", head)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a commit no ref points to, holding a new blob, and a loose blob
+	blob, err := object.WriteBlobData(".", []byte("lost\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := object.WriteTree(".", map[string]object.ObjectHash{"lost": blob}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := object.Signature{Name: "T", Email: "t@x", When: time.Now()}
+	lost, err := object.WriteCommit(".", tree, []object.ObjectHash{c.Hash()}, sig, sig, "lost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loose, err := object.WriteBlobData(".", []byte("loose\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := fsck(t)
+	if !report.OK() {
+		t.Errorf("dangling objects are reported as corruption: %+v", report)
+	}
+
+	// only the tips, the tree and the blob of the lost commit are referenced
+	want := map[string]object.ObjectType{lost.String(): object.CommitType, loose.String(): object.BlobType}
+	if len(report.Dangling) != len(want) {
+		t.Fatalf("got dangling %v, want %v", report.Dangling, want)
+	}
+	for _, d := range report.Dangling {
+		if typ, ok := want[d.Hash.String()]; !ok || typ != d.Type {
+			t.Errorf("%s %v is dangling, want %v", d.Hash, d.Type, want)
+		}
+	}
+}
+
+func TestFsckCorruption(t *testing.T) {
+	newRepo(t)
+
+	objectsDir := utils.GetObjectsDir(".")
+	blob, err := object.WriteBlobData(".", []byte("committed\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := object.WriteBlobData(".", []byte("other\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the committed blob is gone, another one holds the wrong content
+	if err := os.Remove(object.ObjectPath(".", blob)); err != nil {
+		t.Fatal(err)
+	}
+	otherPath := object.ObjectPath(".", other)
+	os.Chmod(otherPath, 0o644)
+	if err := os.WriteFile(otherPath, []byte("not zlib"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(objectsDir, "stray"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// a temporary file of a write in progress is not an object
+	if err := os.WriteFile(filepath.Join(objectsDir, utils.TempPrefix+"x"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report := fsck(t)
+	if report.OK() {
+		t.Fatal("the corruption is not found")
+	}
+	if len(report.Invalid) != 1 || report.Invalid[0] != "stray" {
+		t.Errorf("got invalid %q, want the stray file", report.Invalid)
+	}
+	if len(report.Corrupt) != 1 || !report.Corrupt[0].Hash.Equals(other) {
+		t.Errorf("got corrupt %v, want %s", report.Corrupt, other)
+	}
+	if len(report.Missing) != 1 || !report.Missing[0].Hash.Equals(blob) {
+		t.Errorf("got missing %v, want %s", report.Missing, blob)
+	}
+}
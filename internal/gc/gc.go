@@ -0,0 +1,146 @@
+// Package gc deletes the objects a repository does not need anymore: blobs added
+// but never committed, and the history of deleted branches.
+package gc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/journal"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/reach"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// staleTempAge is the age of the temporary files that are deleted, younger ones
+// may belong to a write still running
+const staleTempAge = time.Hour
+
+type PruneOptions struct {
+	// Expire keeps the unreachable objects written after it, so objects of a
+	// command running at the same time are not deleted. Zero prunes every unreachable object.
+	Expire time.Time
+	// DryRun reports what would be pruned without deleting anything
+	DryRun bool
+}
+
+type PrunedObject struct {
+	Hash object.ObjectHash
+	Size int64
+}
+
+type PruneResult struct {
+	Pruned []PrunedObject
+	// Bytes is the total size of the pruned objects
+	Bytes int64
+	// Recent is the number of unreachable objects kept because they are newer than Expire
+	Recent int
+	// Temp are the temporary files left by interrupted writes that were deleted
+	Temp []string
+}
+
+// Prune deletes the objects that are not reachable from the branches, HEAD,
+// MERGE_HEAD, a bisect session or the index and were written before opts.Expire.
+// It refuses to run while a checkout or merge waits for `arbor recover`.
+func Prune(repoPath string, opts PruneOptions) (PruneResult, error) {
+	// an interrupted checkout or merge keeps the files it replaced as unreachable blobs
+	if err := journal.Check(repoPath); err != nil {
+		return PruneResult{}, err
+	}
+
+	roots, err := reach.Roots(repoPath)
+	if err != nil {
+		return PruneResult{}, err
+	}
+
+	marked := reach.Mark(repoPath, roots)
+
+	// whatever a broken object points to can not be known, so nothing is safe to delete
+	for h, err := range marked.Broken {
+		return PruneResult{}, fmt.Errorf("can not prune, object %s is broken (%v), run arbor fsck", h, err)
+	}
+
+	hashes, _, err := object.ListObjects(repoPath)
+	if err != nil {
+		return PruneResult{}, err
+	}
+
+	result := PruneResult{}
+	dirs := map[string]struct{}{}
+	for _, h := range hashes {
+		if _, ok := marked.Reachable[h.String()]; ok {
+			continue
+		}
+
+		path := object.ObjectPath(repoPath, h)
+		info, err := os.Stat(path)
+		if err != nil {
+			return PruneResult{}, err
+		}
+
+		if !opts.Expire.IsZero() && info.ModTime().After(opts.Expire) {
+			result.Recent++
+			continue
+		}
+
+		if !opts.DryRun {
+			if err := os.Remove(path); err != nil {
+				return PruneResult{}, err
+			}
+			dirs[filepath.Dir(path)] = struct{}{}
+		}
+
+		result.Pruned = append(result.Pruned, PrunedObject{Hash: h, Size: info.Size()})
+		result.Bytes += info.Size()
+	}
+
+	// drop the fan-out directories left empty, a non-empty one is not removed
+	for d := range dirs {
+		_ = os.Remove(d)
+	}
+
+	sort.Slice(result.Pruned, func(i, j int) bool {
+		return result.Pruned[i].Hash.String() < result.Pruned[j].Hash.String()
+	})
+
+	result.Temp, err = pruneTemp(repoPath, opts.DryRun)
+	if err != nil {
+		return PruneResult{}, err
+	}
+
+	return result, nil
+}
+
+// pruneTemp deletes the temporary files older than staleTempAge in the
+// repository directory and returns them.
+func pruneTemp(repoPath string, dryRun bool) ([]string, error) {
+	files, err := utils.TempFiles(utils.GetRepoDir(repoPath))
+	if err != nil {
+		return nil, err
+	}
+
+	stale := []string{}
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if time.Since(info.ModTime()) < staleTempAge {
+			continue
+		}
+
+		if !dryRun {
+			if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		stale = append(stale, f)
+	}
+	return stale, nil
+}
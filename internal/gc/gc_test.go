@@ -0,0 +1,153 @@
+package gc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/add"
+	"github.com/matiasmartin00/arbor/internal/commit"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/repo"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// newRepo creates a repository with one commit of f in a temporary directory
+// and moves into it.
+func newRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("ARBOR_CONFIG_USER__NAME", "T")
+	t.Setenv("ARBOR_CONFIG_USER__EMAIL", "t@x")
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile("f", []byte("committed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := add.Add(".", false, []string{"f"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := commit.Commit(".", commit.CommitOptions{Message: "test"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeBlob stores an unreachable blob last modified at mtime.
+func writeBlob(t *testing.T, data string, mtime time.Time) object.ObjectHash {
+	t.Helper()
+	hash, err := object.WriteBlobData(".", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(object.ObjectPath(".", hash), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Now()
+
+	t.Run("grace period", func(t *testing.T) {
+		newRepo(t)
+		old := writeBlob(t, "old\n", now.Add(-48*time.Hour))
+		recent := writeBlob(t, "recent\n", now)
+
+		result, err := Prune(".", PruneOptions{Expire: now.Add(-24 * time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(result.Pruned) != 1 || !result.Pruned[0].Hash.Equals(old) {
+			t.Fatalf("pruned %v, want only %s", result.Pruned, old)
+		}
+		if result.Recent != 1 || result.Bytes != result.Pruned[0].Size {
+			t.Errorf("got %d recent and %d bytes, want 1 and %d", result.Recent, result.Bytes, result.Pruned[0].Size)
+		}
+		if object.Exists(".", old) || !object.Exists(".", recent) {
+			t.Error("the old blob is kept or the recent one was deleted")
+		}
+
+		committed, _ := object.WriteBlobData(".", []byte("committed\n"))
+		if !object.Exists(".", committed) {
+			t.Error("the committed blob was deleted")
+		}
+	})
+
+	t.Run("no expiry", func(t *testing.T) {
+		newRepo(t)
+		recent := writeBlob(t, "recent\n", now)
+
+		result, err := Prune(".", PruneOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Pruned) != 1 || object.Exists(".", recent) {
+			t.Errorf("pruned %v, want %s", result.Pruned, recent)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		newRepo(t)
+		old := writeBlob(t, "old\n", now.Add(-48*time.Hour))
+
+		result, err := Prune(".", PruneOptions{DryRun: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Pruned) != 1 || !object.Exists(".", old) {
+			t.Errorf("pruned %v, want %s reported and kept", result.Pruned, old)
+		}
+	})
+
+	t.Run("interrupted merge", func(t *testing.T) {
+		newRepo(t)
+		old := writeBlob(t, "backup\n", now.Add(-48*time.Hour))
+		journalPath := filepath.Join(utils.GetRepoDir("."), "JOURNAL")
+		if err := os.WriteFile(journalPath, []byte(`{"action":"merge"}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Prune(".", PruneOptions{}); err == nil || !strings.Contains(err.Error(), "arbor recover") {
+			t.Fatalf("got %v, want the interrupted merge reported", err)
+		}
+		if !object.Exists(".", old) {
+			t.Error("a blob was pruned while the journal may need it")
+		}
+	})
+}
+
+func TestPruneTemp(t *testing.T) {
+	newRepo(t)
+	repoDir := utils.GetRepoDir(".")
+	stale := filepath.Join(repoDir, "objects", utils.TempPrefix+"blob-1")
+	fresh := filepath.Join(repoDir, utils.TempPrefix+"index-2")
+	for _, p := range []string{stale, fresh} {
+		if err := os.WriteFile(p, []byte("partial"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Prune(".", PruneOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Temp) != 1 || result.Temp[0] != stale {
+		t.Errorf("deleted %q, want only %s", result.Temp, stale)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("the stale temporary file is kept")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("the temporary file of a running write was deleted: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Package hooks runs the executables found in .arbor/hooks (or in the directory
+// set by core.hooksPath) at well-defined points of arbor commands:
+//
+//	pre-commit                    before the commit is written, no arguments
+//	commit-msg <file>             with the file holding the message, which the hook may edit
+//	post-commit                   after the commit is written, no arguments
+//	pre-merge <branch>            before merging branch into the current branch
+//	post-checkout <prev> <new> <flag>
+//	                              after the worktree is updated, with the previous and new
+//	                              commit hashes and flag 1 for a branch checkout, 0 otherwise
+//
+// Hooks run in the repository root with ARBOR_DIR set to the absolute path of the
+// .arbor directory. A pre hook (pre-commit, commit-msg, pre-merge) exiting with a
+// non-zero status aborts the operation, the exit status of post hooks is only reported.
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/matiasmartin00/arbor/internal/config"
+	"github.com/matiasmartin00/arbor/internal/repo"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+const (
+	PreCommit    = "pre-commit"
+	CommitMsg    = "commit-msg"
+	PostCommit   = "post-commit"
+	PreMerge     = "pre-merge"
+	PostCheckout = "post-checkout"
+
+	hooksDir = "hooks"
+)
+
+// Dir returns the hooks directory: core.hooksPath, relative to the repository
+// root when it is not absolute, or .arbor/hooks.
+func Dir(repoPath string) (string, error) {
+	p, ok, err := config.Get(repoPath, "core.hooksPath")
+	if err != nil {
+		return "", err
+	}
+
+	if !ok || len(p) == 0 {
+		return filepath.Join(utils.GetRepoDir(repoPath), hooksDir), nil
+	}
+
+	if filepath.IsAbs(p) {
+		return p, nil
+	}
+	return filepath.Join(repoPath, p), nil
+}
+
+// Run runs hook name with args. A missing hook is not an error, a hook that is
+// not executable is skipped with a hint on stderr.
+func Run(repoPath, name string, args ...string) error {
+	dir, err := Dir(repoPath)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if info.IsDir() {
+		return nil
+	}
+
+	if info.Mode()&0111 == 0 {
+		fmt.Fprintf(os.Stderr, "hint: the '%s' hook was ignored because it's not set as executable\n", name)
+		return nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	arborDir, err := filepath.Abs(utils.GetRepoDir(repoPath))
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(absPath, args...)
+	cmd.Dir = repoPath
+	cmd.Env = append(os.Environ(), repo.DirEnv+"="+arborDir)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("%s hook exited with status %d", name, exitErr.ExitCode())
+		}
+		return fmt.Errorf("can not run %s hook: %w", name, err)
+	}
+
+	return nil
+}
+
+// RunPost runs a post hook, which can not abort the operation that already
+// happened, so a failure is only reported on stderr.
+func RunPost(repoPath, name string, args ...string) {
+	if err := Run(repoPath, name, args...); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
@@ -0,0 +1,108 @@
+package hooks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/repo"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// newRepo creates a repository in a temporary directory and moves into it.
+func newRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("ARBOR_CONFIG_CORE__HOOKSPATH", "")
+	os.Unsetenv("ARBOR_CONFIG_CORE__HOOKSPATH")
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeHook writes the shell script of hook name in dir.
+func writeHook(t *testing.T, dir, name, script string, perm os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), perm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	newRepo(t)
+	dir := filepath.Join(utils.GetRepoDir("."), hooksDir)
+
+	if err := Run(".", PreCommit); err != nil {
+		t.Errorf("a missing hook failed: %v", err)
+	}
+
+	writeHook(t, dir, PreCommit, "exit 1", 0o644)
+	if err := Run(".", PreCommit); err != nil {
+		t.Errorf("a hook that is not executable ran: %v", err)
+	}
+
+	writeHook(t, dir, PreCommit, "exit 3", 0o755)
+	if err := Run(".", PreCommit); err == nil || !strings.Contains(err.Error(), "pre-commit hook exited with status 3") {
+		t.Errorf("got %v, want the exit status reported", err)
+	}
+
+	// arguments, the working directory and ARBOR_DIR
+	writeHook(t, dir, PostCheckout, `echo "$# $1 $2 $3 $(pwd) $ARBOR_DIR" > out`, 0o755)
+	RunPost(".", PostCheckout, "a", "b", "1")
+	data, err := os.ReadFile("out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := os.Getwd()
+	want := "3 a b 1 " + root + " " + filepath.Join(root, ".arbor") + "\n"
+	if string(data) != want {
+		t.Errorf("the hook saw %q, want %q", data, want)
+	}
+
+	// a failing post hook is only reported
+	writeHook(t, dir, PostCommit, "exit 1", 0o755)
+	RunPost(".", PostCommit)
+}
+
+func TestDir(t *testing.T) {
+	newRepo(t)
+	root, _ := os.Getwd()
+
+	tests := []struct {
+		hooksPath string
+		want      string
+	}{
+		{"", filepath.Join(".arbor", hooksDir)},
+		{"scripts/hooks", "scripts/hooks"},
+		{filepath.Join(root, "abs"), filepath.Join(root, "abs")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hooksPath, func(t *testing.T) {
+			if len(tt.hooksPath) > 0 {
+				t.Setenv("ARBOR_CONFIG_CORE__HOOKSPATH", tt.hooksPath)
+			}
+			got, err := Dir(".")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Setenv("ARBOR_CONFIG_CORE__HOOKSPATH", "scripts/hooks")
+	writeHook(t, "scripts/hooks", PreMerge, "exit 1", 0o755)
+	if err := Run(".", PreMerge, "dev"); err == nil {
+		t.Error("the hook of core.hooksPath did not run")
+	}
+}
@@ -0,0 +1,241 @@
+// Package hunk splits file diffs in hunks, asks which ones to take and
+// builds the content that results from them. It powers add -p, reset -p and
+// restore -p.
+package hunk
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/diff"
+)
+
+// DefaultContext is the number of unchanged lines shown around changes
+const DefaultContext = 3
+
+// Hunk is a run of changes with its context, a range of the lines of a file diff.
+type Hunk struct {
+	// start and end index the hunk in the lines of the file diff
+	start, end int
+	// Lines are the lines of the hunk, an edited hunk has its own
+	Lines []diff.LineData
+	// edited hunks were changed by hand and can not be split
+	edited bool
+	// OldStart and NewStart are the first line of the hunk in each version, from 1
+	OldStart int
+	NewStart int
+}
+
+// Hunks groups the changes of a file diff with context lines around them,
+// changes closer than twice the context share a hunk.
+func Hunks(lines []diff.LineData, context int) []Hunk {
+	hunks := []Hunk{}
+	for i := 0; i < len(lines); i++ {
+		if lines[i].Result == diff.EqLine {
+			continue
+		}
+
+		start := max(0, i-context)
+		if n := len(hunks); n > 0 && start < hunks[n-1].end {
+			start = hunks[n-1].end
+		}
+
+		// extend while the next change is within reach of the context
+		end := i + 1
+		for j := end; j < len(lines) && j < end+2*context; j++ {
+			if lines[j].Result != diff.EqLine {
+				end = j + 1
+			}
+		}
+		end = min(len(lines), end+context)
+
+		hunks = append(hunks, newHunk(lines, start, end))
+		i = end - 1
+	}
+	return hunks
+}
+
+func newHunk(lines []diff.LineData, start, end int) Hunk {
+	h := Hunk{start: start, end: end, Lines: lines[start:end], OldStart: 1, NewStart: 1}
+	for _, ld := range lines[:start] {
+		if ld.Result != diff.AddedLine {
+			h.OldStart++
+		}
+		if ld.Result != diff.RemovedLine {
+			h.NewStart++
+		}
+	}
+	return h
+}
+
+// Header is the "@@ -old,count +new,count @@" line of the hunk.
+func (h Hunk) Header() string {
+	oldLines, newLines := 0, 0
+	for _, ld := range h.Lines {
+		if ld.Result != diff.AddedLine {
+			oldLines++
+		}
+		if ld.Result != diff.RemovedLine {
+			newLines++
+		}
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, oldLines, h.NewStart, newLines)
+}
+
+// String is the header and the lines of the hunk, as in a diff.
+func (h Hunk) String() string {
+	var sb strings.Builder
+	sb.WriteString(h.Header())
+	for _, ld := range h.Lines {
+		sb.WriteString("\n" + ld.Result.String() + ld.ResultLine)
+		if ld.NoEOL {
+			sb.WriteString("\n" + diff.NoEOLLine)
+		}
+	}
+	return sb.String()
+}
+
+// Split cuts the hunk before each run of changes but the first one. A hunk with
+// a single run of changes, or an edited one, is returned as it is.
+func Split(lines []diff.LineData, h Hunk) []Hunk {
+	if h.edited {
+		return []Hunk{h}
+	}
+
+	out := []Hunk{}
+	start := h.start
+	for i := h.start + 1; i < h.end; i++ {
+		// a change that follows context starts a new hunk, with that context
+		if lines[i].Result != diff.EqLine && lines[i-1].Result == diff.EqLine && hasChanges(lines[start:i]) {
+			first := i
+			for first > start && lines[first-1].Result == diff.EqLine {
+				first--
+			}
+			// the unchanged lines in between go to the second hunk
+			out = append(out, newHunk(lines, start, first))
+			start = first
+		}
+	}
+	return append(out, newHunk(lines, start, h.end))
+}
+
+// CanSplit reports if Split cuts the hunk in more than one.
+func CanSplit(lines []diff.LineData, h Hunk) bool {
+	return len(Split(lines, h)) > 1
+}
+
+func hasChanges(lines []diff.LineData) bool {
+	for _, ld := range lines {
+		if ld.Result != diff.EqLine {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply rebuilds the file from the old version and the accepted hunks, the
+// other hunks keep the old lines. hunks must cover every change of lines.
+func Apply(lines []diff.LineData, hunks []Hunk, accepted []bool) []byte {
+	out := []diff.LineData{}
+	pos := 0
+	for i, h := range hunks {
+		out = append(out, lines[pos:h.start]...)
+
+		if accepted[i] {
+			for _, ld := range h.Lines {
+				if ld.Result != diff.RemovedLine {
+					out = append(out, ld)
+				}
+			}
+		} else {
+			for _, ld := range lines[h.start:h.end] {
+				if ld.Result != diff.AddedLine {
+					out = append(out, ld)
+				}
+			}
+		}
+		pos = h.end
+	}
+
+	return content(append(out, lines[pos:]...))
+}
+
+// Taken reports if any hunk was accepted.
+func Taken(accepted []bool) bool {
+	for _, a := range accepted {
+		if a {
+			return true
+		}
+	}
+	return false
+}
+
+// Revert rebuilds the file from the new version with the accepted hunks undone.
+func Revert(lines []diff.LineData, hunks []Hunk, accepted []bool) []byte {
+	keep := make([]bool, len(accepted))
+	for i, a := range accepted {
+		keep[i] = !a
+	}
+	return Apply(lines, hunks, keep)
+}
+
+// content joins lines into file content, each line ends with a newline but a
+// last one marked NoEOL.
+func content(lines []diff.LineData) []byte {
+	var sb strings.Builder
+	for i, ld := range lines {
+		sb.WriteString(ld.ResultLine)
+		if !ld.NoEOL || i < len(lines)-1 {
+			sb.WriteString("\n")
+		}
+	}
+	return []byte(sb.String())
+}
+
+// parseEdited reads a hunk edited by hand: " " context, "-" removed and "+" added
+// lines, "@@" and "#" lines are skipped, a "\" line marks the line before it
+// as the last one, without a newline. The old side must not change.
+func parseEdited(text string, h Hunk) (Hunk, error) {
+	lines := []diff.LineData{}
+	for _, l := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(l, "@@"), strings.HasPrefix(l, "#"):
+			continue
+		case len(l) == 0:
+			// editors may drop the space of an empty context line
+			lines = append(lines, diff.LineData{Result: diff.EqLine})
+		case l[0] == '\\':
+			if n := len(lines); n > 0 {
+				lines[n-1].NoEOL = true
+			}
+		case l[0] == ' ':
+			lines = append(lines, diff.LineData{ResultLine: l[1:], Result: diff.EqLine})
+		case l[0] == '-':
+			lines = append(lines, diff.LineData{ResultLine: l[1:], Result: diff.RemovedLine})
+		case l[0] == '+':
+			lines = append(lines, diff.LineData{ResultLine: l[1:], Result: diff.AddedLine})
+		default:
+			return Hunk{}, fmt.Errorf("edited hunk has an invalid line %q", l)
+		}
+	}
+
+	if !slices.Equal(oldSide(lines), oldSide(h.Lines)) {
+		return Hunk{}, fmt.Errorf("edited hunk does not apply, only \"+\" lines can be dropped and \"-\" lines turned into context")
+	}
+
+	edited := h
+	edited.Lines = lines
+	edited.edited = true
+	return edited, nil
+}
+
+func oldSide(lines []diff.LineData) []diff.LineData {
+	out := []diff.LineData{}
+	for _, ld := range lines {
+		if ld.Result != diff.AddedLine {
+			out = append(out, diff.LineData{ResultLine: ld.ResultLine, NoEOL: ld.NoEOL})
+		}
+	}
+	return out
+}
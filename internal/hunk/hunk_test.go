@@ -0,0 +1,162 @@
+package hunk
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/diff"
+)
+
+// fileDiff builds the lines of a file diff from "-", "+" and " " prefixed lines,
+// a "\" line marks the line before it as NoEOL.
+func fileDiff(lines ...string) []diff.LineData {
+	out := []diff.LineData{}
+	for _, l := range lines {
+		switch l[0] {
+		case '-':
+			out = append(out, diff.LineData{ResultLine: l[1:], Result: diff.RemovedLine})
+		case '+':
+			out = append(out, diff.LineData{ResultLine: l[1:], Result: diff.AddedLine})
+		case '\\':
+			out[len(out)-1].NoEOL = true
+		default:
+			out = append(out, diff.LineData{ResultLine: l[1:], Result: diff.EqLine})
+		}
+	}
+	return out
+}
+
+func TestHunks(t *testing.T) {
+	lines := fileDiff(" 1", "-2", "+two", " 3", " 4", " 5", " 6", " 7", " 8", " 9", "+10")
+
+	hunks := Hunks(lines, 1)
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(hunks))
+	}
+	if got, want := hunks[0].Header(), "@@ -1,3 +1,3 @@"; got != want {
+		t.Errorf("first header %q, want %q", got, want)
+	}
+	if got, want := hunks[1].Header(), "@@ -9,1 +9,2 @@"; got != want {
+		t.Errorf("second header %q, want %q", got, want)
+	}
+
+	// changes closer than twice the context share a hunk
+	if n := len(Hunks(lines, 4)); n != 1 {
+		t.Errorf("got %d hunks with 4 lines of context, want 1", n)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	lines := fileDiff(" 1", "-2", " 3", "+4", " 5")
+	h := Hunks(lines, 3)[0]
+
+	split := Split(lines, h)
+	if len(split) != 2 || !CanSplit(lines, h) {
+		t.Fatalf("got %d hunks, want 2", len(split))
+	}
+	if got, want := split[0].String(), "@@ -1,2 +1,1 @@\n 1\n-2"; got != want {
+		t.Errorf("first hunk %q, want %q", got, want)
+	}
+	if got, want := split[1].String(), "@@ -3,2 +2,3 @@\n 3\n+4\n 5"; got != want {
+		t.Errorf("second hunk %q, want %q", got, want)
+	}
+
+	if single := Split(lines, split[1]); len(single) != 1 || CanSplit(lines, split[1]) {
+		t.Errorf("a hunk with one change was split in %d", len(single))
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []diff.LineData
+		accepted []bool
+		apply    string
+		revert   string
+	}{
+		{
+			name:     "all",
+			lines:    fileDiff(" a", "-b", "+B", " c", " d", " e", " f", " g", " h", " i", "+j"),
+			accepted: []bool{true, true},
+			apply:    "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\n",
+			revert:   "a\nb\nc\nd\ne\nf\ng\nh\ni\n",
+		},
+		{
+			name:     "some",
+			lines:    fileDiff(" a", "-b", "+B", " c", " d", " e", " f", " g", " h", " i", "+j"),
+			accepted: []bool{false, true},
+			apply:    "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n",
+			revert:   "a\nB\nc\nd\ne\nf\ng\nh\ni\n",
+		},
+		{
+			name:     "none",
+			lines:    fileDiff("-a"),
+			accepted: []bool{false},
+			apply:    "a\n",
+			revert:   "",
+		},
+		{
+			name:     "no final newline kept",
+			lines:    fileDiff("-a", "+a2", " b", `\`),
+			accepted: []bool{true},
+			apply:    "a2\nb",
+			revert:   "a\nb",
+		},
+		{
+			name:     "final newline added",
+			lines:    fileDiff(" a", "-b", `\`, "+b"),
+			accepted: []bool{true},
+			apply:    "a\nb\n",
+			revert:   "a\nb",
+		},
+		{
+			name:     "final newline removed",
+			lines:    fileDiff(" a", "-b", "+b", "+c", `\`),
+			accepted: []bool{false},
+			apply:    "a\nb\n",
+			revert:   "a\nb\nc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks := Hunks(tt.lines, 1)
+			if len(hunks) != len(tt.accepted) {
+				t.Fatalf("got %d hunks, want %d", len(hunks), len(tt.accepted))
+			}
+			if got := string(Apply(tt.lines, hunks, tt.accepted)); got != tt.apply {
+				t.Errorf("Apply = %q, want %q", got, tt.apply)
+			}
+			if got := string(Revert(tt.lines, hunks, tt.accepted)); got != tt.revert {
+				t.Errorf("Revert = %q, want %q", got, tt.revert)
+			}
+		})
+	}
+}
+
+func TestParseEdited(t *testing.T) {
+	lines := fileDiff(" a", "-b", `\`, "+B", "+c")
+	h := Hunks(lines, 3)[0]
+
+	edited, err := parseEdited(h.String()+"\n", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(Apply(lines, []Hunk{edited}, []bool{true})); got != "a\nB\nc\n" {
+		t.Errorf("unchanged hunk applies as %q", got)
+	}
+
+	// keep b, without its newline, and drop c
+	text := strings.Join([]string{h.Header(), " a", " b", `\ No newline at end of file`, "+B"}, "\n")
+	edited, err = parseEdited(text, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(Apply(lines, []Hunk{edited}, []bool{true})); got != "a\nb\nB\n" {
+		t.Errorf("edited hunk applies as %q", got)
+	}
+
+	if _, err := parseEdited(strings.Join([]string{" a", "-x"}, "\n"), h); err == nil {
+		t.Error("a hunk with a changed old side was accepted")
+	}
+}
@@ -0,0 +1,110 @@
+package hunk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/diff"
+)
+
+const promptHelp = `y - take this hunk
+n - do not take this hunk
+q - quit, do not take this hunk or any of the remaining ones
+s - split the hunk in smaller hunks
+e - edit the hunk by hand
+? - print help`
+
+// Prompter asks which hunks to take, reading the answers from In.
+type Prompter struct {
+	In  *bufio.Reader
+	Out io.Writer
+	// Edit opens text in an editor and returns it as saved, nil disables "e"
+	Edit func(text string) (string, error)
+}
+
+func NewPrompter(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{In: bufio.NewReader(in), Out: out}
+}
+
+// Select shows the hunks of the file diff one by one with question, and returns
+// the hunks (split or edited as asked) with the ones taken. It returns false when
+// the user quits, the hunks left are not taken.
+func (p *Prompter) Select(dr diff.DiffResult, question string) ([]Hunk, []bool, bool, error) {
+	hunks := Hunks(dr.Lines, DefaultContext)
+	accepted := make([]bool, 0, len(hunks))
+	if len(hunks) == 0 {
+		return hunks, accepted, true, nil
+	}
+
+	fmt.Fprintf(p.Out, "diff -- a/%s b/%s\n", dr.File, dr.File)
+	for i := 0; i < len(hunks); {
+		h := hunks[i]
+		options := []string{"y", "n", "q"}
+		if CanSplit(dr.Lines, h) {
+			options = append(options, "s")
+		}
+		if p.Edit != nil {
+			options = append(options, "e")
+		}
+		options = append(options, "?")
+
+		fmt.Fprintln(p.Out, h.String())
+		fmt.Fprintf(p.Out, "(%d/%d) %s [%s]? ", i+1, len(hunks), question, strings.Join(options, ","))
+
+		answer, err := p.In.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, false, err
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if err == io.EOF && len(answer) == 0 {
+			fmt.Fprintln(p.Out)
+			answer = "q"
+		}
+
+		switch {
+		case answer == "y":
+			accepted = append(accepted, true)
+			i++
+		case answer == "n":
+			accepted = append(accepted, false)
+			i++
+		case answer == "q":
+			for len(accepted) < len(hunks) {
+				accepted = append(accepted, false)
+			}
+			return hunks, accepted, false, nil
+		case answer == "s" && CanSplit(dr.Lines, h):
+			parts := Split(dr.Lines, h)
+			fmt.Fprintf(p.Out, "Split into %d hunks.\n", len(parts))
+			hunks = append(hunks[:i], append(parts, hunks[i+1:]...)...)
+		case answer == "e" && p.Edit != nil:
+			edited, err := p.edit(h)
+			if err != nil {
+				fmt.Fprintln(p.Out, err)
+				continue
+			}
+			hunks[i] = edited
+			accepted = append(accepted, true)
+			i++
+		default:
+			fmt.Fprintln(p.Out, promptHelp)
+		}
+	}
+
+	return hunks, accepted, true, nil
+}
+
+func (p *Prompter) edit(h Hunk) (Hunk, error) {
+	text := h.String() + `
+# To keep a "-" line, make it a context line (" ") instead.
+# To leave out a "+" line, delete it.
+# Lines starting with # are ignored.
+`
+	edited, err := p.Edit(text)
+	if err != nil {
+		return Hunk{}, err
+	}
+	return parseEdited(edited, h)
+}
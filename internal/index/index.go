@@ -2,7 +2,10 @@ package index
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/matiasmartin00/arbor/internal/object"
 	"github.com/matiasmartin00/arbor/internal/utils"
@@ -11,6 +14,7 @@ import (
 type indexEntry struct {
 	Hash     object.ObjectHash
 	IsBinary bool
+	Mode     object.FileMode
 }
 
 type Index map[string]indexEntry
@@ -26,29 +30,161 @@ func Load(repoPath string) (Index, error) {
 		return nil, err
 	}
 
+	return Parse(data)
+}
+
+// Parse reads an index as saved in the index file.
+func Parse(data []byte) (Index, error) {
 	var idx Index
 	if err := json.Unmarshal(data, &idx); err != nil {
 		return nil, err
 	}
 
-	return idx, err
+	// paths are written to and removed from the working directory
+	for p := range idx {
+		if err := object.ValidatePath(p); err != nil {
+			return nil, fmt.Errorf("invalid index: %w", err)
+		}
+	}
+
+	return idx, nil
+}
+
+// Marshal returns the index as saved in the index file.
+func (idx Index) Marshal() ([]byte, error) {
+	return json.MarshalIndent(idx, "", "  ")
 }
 
-func (idx Index) Save(repoPath string) error {
+// Lock is the index.lock file a writer holds from loading the index to saving
+// it, other writers fail to take it meanwhile. The new index is written to the
+// lock file, which is then renamed over the index. Until then the file holds
+// the owner of the lock.
+type Lock struct {
+	path      string
+	indexPath string
+	file      *os.File
+	// Owner tells this lock apart from the locks of other processes
+	Owner string
+}
+
+// LoadForUpdate takes the index lock and loads the index. The changes are saved
+// with Commit, and the lock must be released with Release in any case.
+func LoadForUpdate(repoPath string) (Index, *Lock, error) {
 	indexPath := utils.GetIndexPath(repoPath)
-	data, err := json.MarshalIndent(idx, "", "  ")
+	lockPath := lockPath(repoPath)
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, nil, fmt.Errorf("unable to lock the index, %s exists: another arbor process is updating the repository, or one crashed and the stale lock file must be removed", lockPath)
+		}
+		return nil, nil, err
+	}
+	lock := &Lock{path: lockPath, indexPath: indexPath, file: f, Owner: fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())}
+	if err := writeOwner(f, lock.Owner); err != nil {
+		lock.Release()
+		return nil, nil, err
+	}
+
+	idx, err := Load(repoPath)
+	if err != nil {
+		lock.Release()
+		return nil, nil, err
+	}
+	return idx, lock, nil
+}
+
+// writeOwner stores owner in the lock file, synced so that it survives a crash.
+func writeOwner(f *os.File, owner string) error {
+	if _, err := f.WriteString(owner); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// RemoveStaleLock removes the lock left behind by a process that crashed while
+// holding it as owner. A lock of anyone else is left alone.
+func RemoveStaleLock(repoPath, owner string) error {
+	data, err := utils.ReadFile(lockPath(repoPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if len(owner) == 0 || string(data) != owner {
+		return nil
+	}
+	return utils.RemoveFile(lockPath(repoPath))
+}
+
+func lockPath(repoPath string) string {
+	return utils.GetIndexPath(repoPath) + ".lock"
+}
+
+// Release gives up the lock without saving, it does nothing after Commit.
+func (l *Lock) Release() {
+	if l.file == nil {
+		return
+	}
+	l.file.Close()
+	os.Remove(l.path)
+	l.file = nil
+}
+
+// Commit writes the index to the lock file, syncs it and renames it over the
+// index, which releases the lock.
+func (idx Index) Commit(l *Lock) error {
+	if l.file == nil {
+		return fmt.Errorf("index lock %s is not held", l.path)
+	}
+
+	data, err := idx.Marshal()
 	if err != nil {
 		return err
 	}
 
-	return utils.WriteFile(indexPath, data)
+	// the index replaces the owner
+	f := l.file
+	l.file = nil
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		os.Remove(l.path)
+		return err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		f.Close()
+		os.Remove(l.path)
+		return err
+	}
+	if err := utils.WriteSynced(f, data); err != nil {
+		os.Remove(l.path)
+		return err
+	}
+
+	if err := os.Rename(l.path, l.indexPath); err != nil {
+		os.Remove(l.path)
+		return err
+	}
+	return utils.SyncDir(filepath.Dir(l.indexPath))
 }
 
+// AddEntry stages hash for path, keeping the mode of the entry it replaces or
+// as a regular file.
 func (idx Index) AddEntry(path string, hash object.ObjectHash) {
+	mode := object.ModeRegular
+	if ie, ok := idx[path]; ok {
+		mode = ie.Mode
+	}
+	idx.AddEntryMode(path, hash, mode)
+}
+
+func (idx Index) AddEntryMode(path string, hash object.ObjectHash, mode object.FileMode) {
 	blob, _ := object.ReadBlob(".", hash) // TODO: pending to change....
 	idx[path] = indexEntry{
 		Hash:     hash,
 		IsBinary: utils.IsBinary(blob.Data()),
+		Mode:     mode,
 	}
 }
 
@@ -63,6 +199,7 @@ func (idx Index) Remove(path string) {
 type indexEntryJSON struct {
 	Hash     string `json:"hash"`
 	IsBinary bool   `json:"is_binary"`
+	Mode     string `json:"mode,omitempty"`
 }
 
 func (e indexEntry) MarshalJSON() ([]byte, error) {
@@ -74,6 +211,7 @@ func (e indexEntry) MarshalJSON() ([]byte, error) {
 	j := indexEntryJSON{
 		Hash:     hs,
 		IsBinary: e.IsBinary,
+		Mode:     e.Mode.String(),
 	}
 
 	return json.MarshalIndent(j, "", "  ")
@@ -96,5 +234,15 @@ func (e *indexEntry) UnmarshalJSON(b []byte) error {
 	}
 
 	e.IsBinary = j.IsBinary
+
+	// indexes written before modes were recorded hold regular files
+	e.Mode = object.ModeRegular
+	if len(j.Mode) > 0 {
+		mode, err := object.ParseFileMode(j.Mode)
+		if err != nil {
+			return err
+		}
+		e.Mode = mode
+	}
 	return nil
 }
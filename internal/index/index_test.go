@@ -0,0 +1,81 @@
+package index
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// newRepo creates the repository directory in a temporary directory and moves
+// into it, repo.Init can not be used as it imports index.
+func newRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(utils.GetObjectsDir("."), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockConflict(t *testing.T) {
+	newRepo(t)
+
+	_, lock, err := LoadForUpdate(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+
+	if _, _, err := LoadForUpdate("."); err == nil || !strings.Contains(err.Error(), ".arbor/index.lock") {
+		t.Fatalf("got %v, want a lock conflict naming .arbor/index.lock", err)
+	}
+
+	lock.Release()
+	_, second, err := LoadForUpdate(".")
+	if err != nil {
+		t.Fatalf("the released lock is still held: %v", err)
+	}
+	second.Release()
+}
+
+func TestReleaseAfterCommit(t *testing.T) {
+	newRepo(t)
+
+	idx, lock, err := LoadForUpdate(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := object.WriteBlobData(".", []byte("f\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.AddEntry("f", hash)
+	if err := idx.Commit(lock); err != nil {
+		t.Fatal(err)
+	}
+
+	// the next writer takes the lock, a late Release must leave it alone
+	_, next, err := LoadForUpdate(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer next.Release()
+	lock.Release()
+
+	if _, err := os.Stat(lockPath(".")); err != nil {
+		t.Errorf("the lock of the next writer is gone: %v", err)
+	}
+	if err := idx.Commit(lock); err == nil {
+		t.Error("committed twice with the same lock")
+	}
+
+	saved, err := Load(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 {
+		t.Errorf("got %d entries, want the committed one", len(saved))
+	}
+}
@@ -0,0 +1,537 @@
+// Package journal applies the changes of a checkout or a merge to the working
+// directory, the refs and the index as a single transaction. Before touching
+// anything it validates the changes, keeps the previous content of every file
+// it changes as a blob and writes the plan to .arbor/JOURNAL. A failure rolls
+// everything back, and after a crash Recover rolls back or finishes the work.
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/matiasmartin00/arbor/internal/index"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+const journalFile = "JOURNAL"
+
+var errNoJournal = fmt.Errorf("no interrupted checkout or merge to recover")
+
+// RefUpdate sets a ref file, relative to the repository directory, to Content.
+type RefUpdate struct {
+	File    string
+	Content string
+}
+
+// SetHead points HEAD to branch.
+func SetHead(branch string) RefUpdate {
+	return RefUpdate{File: refs.HeadFile, Content: refs.RefFile(branch) + "\n"}
+}
+
+// SetDetachedHead points HEAD to the commit hash instead of a branch.
+func SetDetachedHead(hash object.ObjectHash) RefUpdate {
+	return RefUpdate{File: refs.HeadFile, Content: hash.String() + "\n"}
+}
+
+// SetBranch moves branch to hash.
+func SetBranch(branch string, hash object.ObjectHash) RefUpdate {
+	return RefUpdate{File: refs.RefFile(branch), Content: hash.String() + "\n"}
+}
+
+// SetMergeHead records hash as the commit being merged.
+func SetMergeHead(hash object.ObjectHash) RefUpdate {
+	return RefUpdate{File: refs.MergeHeadFile, Content: hash.String() + "\n"}
+}
+
+// Transaction collects the changes of a checkout or a merge. It holds the index
+// lock from Begin until Commit or Abort.
+type Transaction struct {
+	repoPath string
+	action   string
+	lock     *index.Lock
+	index    index.Index
+	// writes are the files that get a blob and a mode, by slash separated path
+	writes  map[string]fileState
+	removes map[string]struct{}
+	refs    []RefUpdate
+}
+
+type fileState struct {
+	hash object.ObjectHash
+	mode object.FileMode
+}
+
+// Begin starts a transaction named after action ("checkout", "merge"). It locks
+// and returns the index, the changes made to it are saved by Commit.
+func Begin(repoPath, action string) (*Transaction, index.Index, error) {
+	if err := Check(repoPath); err != nil {
+		return nil, nil, err
+	}
+
+	idx, lock, err := index.LoadForUpdate(repoPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Transaction{
+		repoPath: repoPath,
+		action:   action,
+		lock:     lock,
+		index:    idx,
+		writes:   map[string]fileState{},
+		removes:  map[string]struct{}{},
+	}, idx, nil
+}
+
+// Write sets the file at path to the blob hash with mode.
+func (tx *Transaction) Write(p string, hash object.ObjectHash, mode object.FileMode) {
+	p = filepath.ToSlash(p)
+	delete(tx.removes, p)
+	tx.writes[p] = fileState{hash: hash, mode: mode}
+}
+
+// Remove deletes the file at path.
+func (tx *Transaction) Remove(p string) {
+	p = filepath.ToSlash(p)
+	delete(tx.writes, p)
+	tx.removes[p] = struct{}{}
+}
+
+// UpdateRefs sets the ref files once the files are written.
+func (tx *Transaction) UpdateRefs(updates ...RefUpdate) {
+	tx.refs = append(tx.refs, updates...)
+}
+
+// Abort gives up the transaction, it does nothing after Commit.
+func (tx *Transaction) Abort() {
+	tx.lock.Release()
+}
+
+// Commit validates the changes, records them in the journal and applies them:
+// files are removed, then written, then the refs and the index are updated. On
+// a failure the files and refs are put back as they were.
+func (tx *Transaction) Commit() error {
+	if err := tx.validate(); err != nil {
+		return err
+	}
+
+	j, err := tx.prepare()
+	if err != nil {
+		return err
+	}
+
+	if err := j.save(tx.repoPath); err != nil {
+		return err
+	}
+
+	if err := j.apply(tx.repoPath, tx.lock); err != nil {
+		if rerr := j.rollback(tx.repoPath); rerr != nil {
+			return fmt.Errorf("%s failed: %w; rolling it back failed too: %v, run `arbor recover` to retry", j.Action, err, rerr)
+		}
+		if rerr := removeJournal(tx.repoPath); rerr != nil {
+			return rerr
+		}
+		return fmt.Errorf("%s failed, the changes were rolled back: %w", j.Action, err)
+	}
+
+	return removeJournal(tx.repoPath)
+}
+
+// validate checks the paths, the blobs and that nothing unexpected stands in the
+// way of a write: a file or symlink where a directory goes, or a directory with
+// files that are not removed where a file goes.
+func (tx *Transaction) validate() error {
+	for p := range tx.removes {
+		if err := object.ValidatePath(p); err != nil {
+			return err
+		}
+	}
+
+	for p, st := range tx.writes {
+		if err := object.ValidatePath(p); err != nil {
+			return err
+		}
+
+		if !object.Exists(tx.repoPath, st.hash) {
+			return fmt.Errorf("can not %s, object %s of %s is missing", tx.action, st.hash, p)
+		}
+
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			info, err := os.Lstat(filepath.FromSlash(dir))
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return err
+			}
+			if _, removed := tx.removes[dir]; !info.IsDir() && !removed {
+				return fmt.Errorf("can not %s, %s stands where the directory of %s goes", tx.action, dir, p)
+			}
+		}
+
+		if info, err := os.Lstat(filepath.FromSlash(p)); err == nil && info.IsDir() {
+			err := filepath.WalkDir(filepath.FromSlash(p), func(f string, d os.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				if _, removed := tx.removes[filepath.ToSlash(f)]; !removed {
+					return fmt.Errorf("can not %s, the directory %s holds %s where a file goes", tx.action, p, f)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// prepare builds the journal: the changes, a backup of every file they touch,
+// the refs before and after, and the index before and after, stored as blobs.
+func (tx *Transaction) prepare() (journal, error) {
+	j := journal{Action: tx.action, Lock: tx.lock.Owner}
+
+	for p := range tx.removes {
+		j.Removes = append(j.Removes, p)
+	}
+	sort.Strings(j.Removes)
+
+	for p, st := range tx.writes {
+		j.Writes = append(j.Writes, fileEntry{Path: p, Hash: st.hash.String(), Mode: st.mode.String()})
+	}
+	sort.Slice(j.Writes, func(a, b int) bool {
+		return j.Writes[a].Path < j.Writes[b].Path
+	})
+
+	touched := append([]string{}, j.Removes...)
+	for _, w := range j.Writes {
+		touched = append(touched, w.Path)
+	}
+	for _, p := range touched {
+		backup, err := backupFile(tx.repoPath, p)
+		if err != nil {
+			return journal{}, err
+		}
+		j.Backups = append(j.Backups, backup)
+	}
+
+	for _, u := range tx.refs {
+		entry := refEntry{File: filepath.ToSlash(u.File), After: u.Content}
+		data, err := utils.ReadFile(filepath.Join(utils.GetRepoDir(tx.repoPath), u.File))
+		if err != nil && !os.IsNotExist(err) {
+			return journal{}, err
+		}
+		if err == nil {
+			before := string(data)
+			entry.Before = &before
+		}
+		j.Refs = append(j.Refs, entry)
+	}
+
+	before, err := utils.ReadFile(utils.GetIndexPath(tx.repoPath))
+	if err != nil && !os.IsNotExist(err) {
+		return journal{}, err
+	}
+	if err != nil {
+		before = []byte("{}")
+	}
+	if j.IndexBefore, err = writeBlob(tx.repoPath, before); err != nil {
+		return journal{}, err
+	}
+
+	after, err := tx.index.Marshal()
+	if err != nil {
+		return journal{}, err
+	}
+	if j.IndexAfter, err = writeBlob(tx.repoPath, after); err != nil {
+		return journal{}, err
+	}
+
+	return j, nil
+}
+
+// backupFile stores the file at p as a blob, a missing file or a directory has
+// no hash.
+func backupFile(repoPath, p string) (fileEntry, error) {
+	info, err := os.Lstat(filepath.FromSlash(p))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fileEntry{Path: p}, nil
+		}
+		return fileEntry{}, err
+	}
+	if info.IsDir() {
+		return fileEntry{Path: p}, nil
+	}
+
+	data, mode, err := object.ReadWorktreeFile(filepath.FromSlash(p))
+	if err != nil {
+		return fileEntry{}, err
+	}
+
+	hash, err := writeBlob(repoPath, data)
+	if err != nil {
+		return fileEntry{}, err
+	}
+	return fileEntry{Path: p, Hash: hash, Mode: mode.String()}, nil
+}
+
+func writeBlob(repoPath string, data []byte) (string, error) {
+	hash, err := object.WriteBlobData(repoPath, data)
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+// journal is the content of .arbor/JOURNAL.
+type journal struct {
+	Action  string      `json:"action"`
+	Removes []string    `json:"removes"`
+	Writes  []fileEntry `json:"writes"`
+	// Backups are the touched files as they were, with no hash when missing
+	Backups     []fileEntry `json:"backups"`
+	Refs        []refEntry  `json:"refs"`
+	IndexBefore string      `json:"index_before"`
+	IndexAfter  string      `json:"index_after"`
+	// Lock is the owner of the index lock held by the transaction
+	Lock string `json:"lock"`
+}
+
+type fileEntry struct {
+	Path string `json:"path"`
+	Hash string `json:"hash,omitempty"`
+	Mode string `json:"mode,omitempty"`
+}
+
+// refEntry is a ref file before (nil when missing) and after the transaction.
+type refEntry struct {
+	File   string  `json:"file"`
+	Before *string `json:"before"`
+	After  string  `json:"after"`
+}
+
+func journalPath(repoPath string) string {
+	return filepath.Join(utils.GetRepoDir(repoPath), journalFile)
+}
+
+func (j journal) save(repoPath string) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFile(journalPath(repoPath), data)
+}
+
+func load(repoPath string) (journal, error) {
+	data, err := utils.ReadFile(journalPath(repoPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return journal{}, errNoJournal
+		}
+		return journal{}, err
+	}
+
+	var j journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return journal{}, fmt.Errorf("invalid journal %s: %w", journalPath(repoPath), err)
+	}
+	return j, nil
+}
+
+func removeJournal(repoPath string) error {
+	if err := utils.RemoveFile(journalPath(repoPath)); err != nil {
+		return err
+	}
+	return utils.SyncDir(utils.GetRepoDir(repoPath))
+}
+
+// apply makes the changes of the journal, it can run again after a crash.
+func (j journal) apply(repoPath string, lock *index.Lock) error {
+	for _, p := range j.Removes {
+		if err := removeFile(p); err != nil {
+			return err
+		}
+	}
+
+	for _, w := range j.Writes {
+		if err := writeFile(repoPath, w); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range j.Refs {
+		if err := writeRef(repoPath, r.File, &r.After); err != nil {
+			return err
+		}
+	}
+
+	return commitIndex(repoPath, j.IndexAfter, lock)
+}
+
+// rollback puts the files and refs back as they were. The files written where
+// there was none go first, so the directories they made leave room for files.
+func (j journal) rollback(repoPath string) error {
+	for _, b := range j.Backups {
+		if len(b.Hash) == 0 {
+			if err := removeFile(b.Path); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, b := range j.Backups {
+		if len(b.Hash) > 0 {
+			if err := writeFile(repoPath, b); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, r := range j.Refs {
+		if err := writeRef(repoPath, r.File, r.Before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeFile removes a file and the directories it leaves empty.
+func removeFile(p string) error {
+	if err := object.RemoveWorktreeFile(filepath.FromSlash(p)); err != nil {
+		return err
+	}
+
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		if err := os.Remove(filepath.FromSlash(dir)); err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// writeFile writes the blob of e, replacing an empty directory in the way.
+func writeFile(repoPath string, e fileEntry) error {
+	hash, err := object.NewObjectHash(e.Hash)
+	if err != nil {
+		return err
+	}
+	mode, err := object.ParseFileMode(e.Mode)
+	if err != nil {
+		return err
+	}
+
+	blob, err := object.ReadBlob(repoPath, hash)
+	if err != nil {
+		return err
+	}
+
+	p := filepath.FromSlash(e.Path)
+	if info, err := os.Lstat(p); err == nil && info.IsDir() {
+		if err := removeEmptyDirs(p); err != nil {
+			return err
+		}
+	}
+	return object.WriteWorktreeFile(p, blob.Data(), mode)
+}
+
+// removeEmptyDirs removes dir when it holds nothing but empty directories.
+func removeEmptyDirs(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			return fmt.Errorf("can not write the file %s, it is a directory that is not empty", dir)
+		}
+		if err := removeEmptyDirs(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return os.Remove(dir)
+}
+
+// writeRef sets a ref file to content, or removes it for nil.
+func writeRef(repoPath, file string, content *string) error {
+	p := filepath.Join(utils.GetRepoDir(repoPath), filepath.FromSlash(file))
+	if content == nil {
+		return utils.RemoveFile(p)
+	}
+	return utils.WriteFile(p, []byte(*content))
+}
+
+// commitIndex saves the index stored in the blob hash, which releases lock.
+func commitIndex(repoPath, hash string, lock *index.Lock) error {
+	h, err := object.NewObjectHash(hash)
+	if err != nil {
+		return err
+	}
+
+	blob, err := object.ReadBlob(repoPath, h)
+	if err != nil {
+		return err
+	}
+
+	idx, err := index.Parse(blob.Data())
+	if err != nil {
+		return err
+	}
+	return idx.Commit(lock)
+}
+
+// Check fails when a transaction was interrupted, until it is recovered.
+func Check(repoPath string) error {
+	j, err := load(repoPath)
+	if err == errNoJournal {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("a %s was interrupted, run `arbor recover` to roll it back or `arbor recover --continue` to finish it", j.Action)
+}
+
+// Recover ends an interrupted transaction: it finishes it with resume, otherwise
+// it puts the files, the refs and the index back as they were. It returns the
+// action of the transaction.
+func Recover(repoPath string, resume bool) (string, error) {
+	j, err := load(repoPath)
+	if err != nil {
+		return "", err
+	}
+
+	// the lock left by the interrupted transaction, another one is still in use
+	if err := index.RemoveStaleLock(repoPath, j.Lock); err != nil {
+		return "", err
+	}
+
+	_, lock, err := index.LoadForUpdate(repoPath)
+	if err != nil {
+		return "", err
+	}
+	defer lock.Release()
+
+	if resume {
+		err = j.apply(repoPath, lock)
+	} else {
+		err = j.rollback(repoPath)
+		if err == nil {
+			err = commitIndex(repoPath, j.IndexBefore, lock)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return j.Action, removeJournal(repoPath)
+}
@@ -0,0 +1,263 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/index"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
+	"github.com/matiasmartin00/arbor/internal/repo"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// newRepo creates a repository in a temporary directory and moves into it.
+func newRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func storeBlob(t *testing.T, data string) object.ObjectHash {
+	t.Helper()
+	hash, err := object.WriteBlobData(".", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func writeWorktree(t *testing.T, p, data string) {
+	t.Helper()
+	if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertFiles checks the content of the files, "" for a missing one.
+func assertFiles(t *testing.T, want map[string]string) {
+	t.Helper()
+	for p, content := range want {
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			data, err = nil, nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s holds %q, want %q", p, data, content)
+		}
+	}
+}
+
+func assertIndex(t *testing.T, want map[string]object.ObjectHash) {
+	t.Helper()
+	idx, err := index.Load(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx) != len(want) {
+		t.Errorf("index has %d entries, want %d", len(idx), len(want))
+	}
+	for p, hash := range want {
+		if ie, ok := idx[p]; !ok || !ie.Hash.Equals(hash) {
+			t.Errorf("index entry of %s is %v, want %s", p, ie.Hash, hash)
+		}
+	}
+}
+
+// setup stages "a" and "b" with old content in the index and the working directory.
+func setup(t *testing.T) (oldA, oldB object.ObjectHash) {
+	t.Helper()
+	newRepo(t)
+	oldA, oldB = storeBlob(t, "old a\n"), storeBlob(t, "old b\n")
+	writeWorktree(t, "a", "old a\n")
+	writeWorktree(t, "b", "old b\n")
+
+	idx, lock, err := index.LoadForUpdate(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.AddEntryMode("a", oldA, object.ModeRegular)
+	idx.AddEntryMode("b", oldB, object.ModeRegular)
+	if err := idx.Commit(lock); err != nil {
+		t.Fatal(err)
+	}
+	return oldA, oldB
+}
+
+func TestCommit(t *testing.T) {
+	oldA, _ := setup(t)
+	newB := storeBlob(t, "new b\n")
+	c := storeBlob(t, "c\n")
+
+	tx, idx, err := Begin(".", "checkout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Abort()
+
+	tx.Write("b", newB, object.ModeRegular)
+	tx.Write("dir/c", c, object.ModeRegular)
+	tx.Remove("a")
+	idx.Remove("a")
+	idx.AddEntryMode("b", newB, object.ModeRegular)
+	idx.AddEntryMode("dir/c", c, object.ModeRegular)
+	tx.UpdateRefs(SetMergeHead(oldA))
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFiles(t, map[string]string{"a": "", "b": "new b\n", "dir/c": "c\n"})
+	assertIndex(t, map[string]object.ObjectHash{"b": newB, "dir/c": c})
+	if h, err := refs.GetMergeHead("."); err != nil || !h.Equals(oldA) {
+		t.Errorf("MERGE_HEAD is %v (%v), want %s", h, err, oldA)
+	}
+	if utils.Exists(journalPath(".")) {
+		t.Error("the journal was left behind")
+	}
+
+	// the lock was released
+	if _, lock, err := index.LoadForUpdate("."); err != nil {
+		t.Error(err)
+	} else {
+		lock.Release()
+	}
+}
+
+func TestCommitFailureRollsBack(t *testing.T) {
+	oldA, oldB := setup(t)
+	newA := storeBlob(t, "new a\n")
+	broken := storeBlob(t, "new b\n")
+	// the blob of b exists but can not be read, so b fails after a was written
+	writeWorktree(t, object.ObjectPath(".", broken), "garbage")
+
+	tx, idx, err := Begin(".", "checkout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Abort()
+
+	tx.Write("a", newA, object.ModeRegular)
+	tx.Write("b", broken, object.ModeRegular)
+	tx.Write("c", newA, object.ModeRegular)
+	idx.AddEntryMode("a", newA, object.ModeRegular)
+	tx.UpdateRefs(SetMergeHead(newA))
+
+	err = tx.Commit()
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("got %v, want a rolled back failure", err)
+	}
+
+	assertFiles(t, map[string]string{"a": "old a\n", "b": "old b\n", "c": ""})
+	assertIndex(t, map[string]object.ObjectHash{"a": oldA, "b": oldB})
+	if utils.Exists(filepath.Join(utils.GetRepoDir("."), refs.MergeHeadFile)) {
+		t.Error("MERGE_HEAD was left behind")
+	}
+	if utils.Exists(journalPath(".")) {
+		t.Error("the journal was left behind")
+	}
+}
+
+// interrupt records a transaction that writes "a", removes "b" and sets
+// MERGE_HEAD, and stops after writing "a" with the index still locked.
+func interrupt(t *testing.T) (newA object.ObjectHash) {
+	t.Helper()
+	newA = storeBlob(t, "new a\n")
+
+	tx, idx, err := Begin(".", "merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Write("a", newA, object.ModeRegular)
+	tx.Remove("b")
+	idx.AddEntryMode("a", newA, object.ModeRegular)
+	idx.Remove("b")
+	tx.UpdateRefs(SetMergeHead(newA))
+
+	j, err := tx.prepare()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.save("."); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(".", j.Writes[0]); err != nil {
+		t.Fatal(err)
+	}
+	return newA
+}
+
+func TestRecover(t *testing.T) {
+	t.Run("rollback", func(t *testing.T) {
+		oldA, oldB := setup(t)
+		interrupt(t)
+
+		if err := Check("."); err == nil {
+			t.Fatal("the interrupted merge was not reported")
+		}
+
+		action, err := Recover(".", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if action != "merge" {
+			t.Errorf("recovered a %s, want a merge", action)
+		}
+
+		assertFiles(t, map[string]string{"a": "old a\n", "b": "old b\n"})
+		assertIndex(t, map[string]object.ObjectHash{"a": oldA, "b": oldB})
+		if utils.Exists(filepath.Join(utils.GetRepoDir("."), refs.MergeHeadFile)) {
+			t.Error("MERGE_HEAD was left behind")
+		}
+		if err := Check("."); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("continue", func(t *testing.T) {
+		setup(t)
+		newA := interrupt(t)
+
+		if _, err := Recover(".", true); err != nil {
+			t.Fatal(err)
+		}
+
+		assertFiles(t, map[string]string{"a": "new a\n", "b": ""})
+		assertIndex(t, map[string]object.ObjectHash{"a": newA})
+		if h, err := refs.GetMergeHead("."); err != nil || !h.Equals(newA) {
+			t.Errorf("MERGE_HEAD is %v (%v), want %s", h, err, newA)
+		}
+	})
+
+	t.Run("lock of another process", func(t *testing.T) {
+		setup(t)
+		interrupt(t)
+
+		// the interrupted process left no lock, and another one took it since
+		lockPath := utils.GetIndexPath(".") + ".lock"
+		if err := os.Remove(lockPath); err != nil {
+			t.Fatal(err)
+		}
+		_, lock, err := index.LoadForUpdate(".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer lock.Release()
+
+		if _, err := Recover(".", false); err == nil {
+			t.Fatal("recovered while another process holds the lock")
+		}
+		if !utils.Exists(lockPath) {
+			t.Error("the lock of another process was removed")
+		}
+		if err := Check("."); err == nil {
+			t.Error("the journal was removed")
+		}
+	})
+}
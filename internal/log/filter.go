@@ -0,0 +1,283 @@
+package log
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+)
+
+// filter decides which of the walked commits are shown.
+type filter struct {
+	w        *walker
+	author   *regexp.Regexp
+	grep     *regexp.Regexp
+	since    time.Time
+	until    time.Time
+	paths    []string
+	included map[string]bool
+}
+
+func newFilter(w *walker, opts LogOptions) (*filter, error) {
+	f := &filter{
+		w:        w,
+		since:    opts.Since,
+		until:    opts.Until,
+		included: map[string]bool{},
+	}
+
+	if len(opts.Author) > 0 {
+		re, err := regexp.Compile(opts.Author)
+		if err != nil {
+			return nil, fmt.Errorf("invalid author pattern: %w", err)
+		}
+		f.author = re
+	}
+
+	if len(opts.Grep) > 0 {
+		re, err := regexp.Compile(opts.Grep)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grep pattern: %w", err)
+		}
+		f.grep = re
+	}
+
+	for _, p := range opts.Paths {
+		p = strings.TrimSuffix(path.Clean(strings.ReplaceAll(p, "\\", "/")), "/")
+		if p == "." || len(p) == 0 {
+			// the whole tree, same as no path filter
+			f.paths = nil
+			break
+		}
+		f.paths = append(f.paths, p)
+	}
+
+	return f, nil
+}
+
+func (f *filter) active() bool {
+	return f.author != nil || f.grep != nil || !f.since.IsZero() || !f.until.IsZero() || len(f.paths) > 0
+}
+
+func (f *filter) match(c object.Commit) (bool, error) {
+	if v, ok := f.included[c.Hash().String()]; ok {
+		return v, nil
+	}
+
+	v, err := f.evaluate(c)
+	if err != nil {
+		return false, err
+	}
+
+	f.included[c.Hash().String()] = v
+	return v, nil
+}
+
+func (f *filter) evaluate(c object.Commit) (bool, error) {
+	if f.author != nil && !f.author.MatchString(fmt.Sprintf("%s <%s>", c.Author(), c.Email())) {
+		return false, nil
+	}
+
+	if f.grep != nil && !f.grep.MatchString(c.Message()) {
+		return false, nil
+	}
+
+	if !f.since.IsZero() && c.CommitTime().Before(f.since) {
+		return false, nil
+	}
+
+	if !f.until.IsZero() && c.CommitTime().After(f.until) {
+		return false, nil
+	}
+
+	if len(f.paths) == 0 {
+		return true, nil
+	}
+
+	// root commits are compared with an empty tree
+	if len(c.ParentHashes()) == 0 {
+		return f.treeChanged(nil, c.TreeHash(), "")
+	}
+
+	// a commit (or merge) is shown only if its tree differs from every parent on the paths
+	for _, p := range c.ParentHashes() {
+		parent, err := f.w.read(p)
+		if err != nil {
+			return false, err
+		}
+
+		changed, err := f.treeChanged(parent.TreeHash(), c.TreeHash(), "")
+		if err != nil {
+			return false, err
+		}
+
+		if !changed {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// treeChanged compares two trees on the filtered paths. Subtrees with the same hash
+// are not read, and only subtrees that may hold a filtered path are descended into.
+// A nil tree is an empty tree.
+func (f *filter) treeChanged(a, b object.ObjectHash, prefix string) (bool, error) {
+	if a != nil && b != nil && a.Equals(b) {
+		return false, nil
+	}
+
+	entriesA, err := f.treeEntries(a)
+	if err != nil {
+		return false, err
+	}
+
+	entriesB, err := f.treeEntries(b)
+	if err != nil {
+		return false, err
+	}
+
+	names := map[string]struct{}{}
+	for n := range entriesA {
+		names[n] = struct{}{}
+	}
+	for n := range entriesB {
+		names[n] = struct{}{}
+	}
+
+	for n := range names {
+		full := prefix + n
+		ea, okA := entriesA[n]
+		eb, okB := entriesB[n]
+
+		if okA && okB && ea.Type == eb.Type && ea.Mode == eb.Mode && ea.Hash.Equals(eb.Hash) {
+			continue
+		}
+
+		if f.covers(full) {
+			return true, nil
+		}
+
+		if !f.leadsTo(full) {
+			continue
+		}
+
+		// only trees can hold a filtered path deeper down
+		var subA, subB object.ObjectHash
+		if okA && ea.Type == object.TreeType {
+			subA = ea.Hash
+		}
+		if okB && eb.Type == object.TreeType {
+			subB = eb.Hash
+		}
+
+		if subA == nil && subB == nil {
+			continue
+		}
+
+		changed, err := f.treeChanged(subA, subB, full+"/")
+		if err != nil {
+			return false, err
+		}
+
+		if changed {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (f *filter) treeEntries(hash object.ObjectHash) (map[string]object.TreeEntry, error) {
+	m := map[string]object.TreeEntry{}
+	if hash == nil {
+		return m, nil
+	}
+
+	entries, err := object.ReadTreeEntries(f.w.repoPath, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		m[e.Name] = e
+	}
+	return m, nil
+}
+
+// covers reports if p is one of the filtered paths or is inside one of them.
+func (f *filter) covers(p string) bool {
+	for _, fp := range f.paths {
+		if p == fp || strings.HasPrefix(p, fp+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// leadsTo reports if the directory p holds one of the filtered paths.
+func (f *filter) leadsTo(p string) bool {
+	for _, fp := range f.paths {
+		if strings.HasPrefix(fp, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteParents replaces the parents that are filtered out by their closest shown
+// ancestors, so the graph keeps its lanes connected.
+func (f *filter) rewriteParents(c object.Commit, memo map[string][]object.ObjectHash) ([]object.ObjectHash, error) {
+	out := []object.ObjectHash{}
+	seen := map[string]struct{}{}
+
+	for _, p := range c.ParentHashes() {
+		resolved, err := f.closestShown(p, memo)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range resolved {
+			if _, ok := seen[r.String()]; ok {
+				continue
+			}
+			seen[r.String()] = struct{}{}
+			out = append(out, r)
+		}
+	}
+
+	return out, nil
+}
+
+func (f *filter) closestShown(hash object.ObjectHash, memo map[string][]object.ObjectHash) ([]object.ObjectHash, error) {
+	if v, ok := memo[hash.String()]; ok {
+		return v, nil
+	}
+
+	c, err := f.w.read(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := f.match(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		memo[hash.String()] = []object.ObjectHash{hash}
+		return memo[hash.String()], nil
+	}
+
+	// mark it first so a long chain of hidden commits is only resolved once
+	memo[hash.String()] = []object.ObjectHash{}
+	resolved, err := f.rewriteParents(c, memo)
+	if err != nil {
+		return nil, err
+	}
+
+	memo[hash.String()] = resolved
+	return resolved, nil
+}
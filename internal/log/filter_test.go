@@ -0,0 +1,109 @@
+package log
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
+	"github.com/matiasmartin00/arbor/internal/repo"
+)
+
+// newLinearHistory creates a repository in a temporary directory, moves into it
+// and commits these in order on main:
+//
+//	1 alice "add docs"   docs/a and src/main
+//	2 bob   "fix: main"  src/main
+//	3 alice "fix: docs"  docs/a
+//	4 carol "release"    VERSION
+//
+// Commit n is made at n*100 seconds.
+func newLinearHistory(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	var parents []object.ObjectHash
+	commit := func(n int64, author, message string, changes map[string]string) {
+		t.Helper()
+		for p, content := range changes {
+			files[p] = content
+		}
+		entries := map[string]object.ObjectHash{}
+		for p, content := range files {
+			hash, err := object.WriteBlobData(".", []byte(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries[p] = hash
+		}
+		tree, err := object.WriteTree(".", entries, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig := object.Signature{Name: author, Email: author + "@example.com", When: time.Unix(n*100, 0).UTC()}
+		hash, err := object.WriteCommit(".", tree, parents, sig, sig, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parents = []object.ObjectHash{hash}
+	}
+
+	commit(1, "alice", "add docs", map[string]string{"docs/a": "1\n", "src/main": "1\n"})
+	commit(2, "bob", "fix: main", map[string]string{"src/main": "2\n"})
+	commit(3, "alice", "fix: docs", map[string]string{"docs/a": "2\n"})
+	commit(4, "carol", "release", map[string]string{"VERSION": "1\n"})
+
+	if err := refs.CreateRef(".", "main", parents[0]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogFilters(t *testing.T) {
+	at := func(n int64) time.Time { return time.Unix(n*100, 0) }
+
+	tests := []struct {
+		name string
+		opts LogOptions
+		want []string
+	}{
+		{"author name", LogOptions{Author: "alice"}, []string{"fix: docs", "add docs"}},
+		{"author email", LogOptions{Author: "^bob <bob@"}, []string{"fix: main"}},
+		{"grep", LogOptions{Grep: "^fix"}, []string{"fix: docs", "fix: main"}},
+		{"since", LogOptions{Since: at(2)}, []string{"release", "fix: docs", "fix: main"}},
+		{"until", LogOptions{Until: at(3)}, []string{"fix: docs", "fix: main", "add docs"}},
+		{"date range", LogOptions{Since: at(2), Until: at(3)}, []string{"fix: docs", "fix: main"}},
+		{"directory", LogOptions{Paths: []string{"docs"}}, []string{"fix: docs", "add docs"}},
+		{"directory with slash", LogOptions{Paths: []string{"src/"}}, []string{"fix: main", "add docs"}},
+		{"file", LogOptions{Paths: []string{"docs/a", "VERSION"}}, []string{"release", "fix: docs", "add docs"}},
+		{"whole tree", LogOptions{Paths: []string{"."}}, []string{"release", "fix: docs", "fix: main", "add docs"}},
+		{"missing path", LogOptions{Paths: []string{"doc"}}, []string{}},
+		{"combined", LogOptions{Author: "alice", Paths: []string{"src"}}, []string{"add docs"}},
+		{"limit after filter", LogOptions{Grep: "fix", Limit: 1}, []string{"fix: docs"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newLinearHistory(t)
+			result, err := Log(".", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := render(result.Logs); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	for _, opts := range []LogOptions{{Author: "("}, {Grep: "[a-"}} {
+		newLinearHistory(t)
+		if _, err := Log(".", opts); err == nil {
+			t.Errorf("%+v: an invalid pattern was accepted", opts)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package log
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/date"
+)
+
+// Subject returns the first line of the message.
+func (lc LogCommit) Subject() string {
+	subject, _, _ := strings.Cut(lc.Message, "\n")
+	return subject
+}
+
+// Decoration returns the refs pointing to the commit between parentheses, with a
+// leading space, or nothing.
+func (lc LogCommit) Decoration() string {
+	if len(lc.Refs) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(lc.Refs, ", ") + ")"
+}
+
+// Body returns the message without its subject and the blank lines after it.
+func (lc LogCommit) Body() string {
+	_, body, _ := strings.Cut(lc.Message, "\n")
+	return strings.TrimLeft(body, "\n")
+}
+
+// Format expands a template with the commit data. Placeholders:
+//
+//	%H  hash             %h  short hash
+//	%P  parent hashes    %p  short parent hashes
+//	%an author name      %ae author email
+//	%ad author date      %ai author date, ISO 8601
+//	%at author date, unix timestamp
+//	%ar author date, relative
+//	%cn committer name   %ce committer email
+//	%cd committer date   %ci committer date, ISO 8601
+//	%ct committer date, unix timestamp
+//	%cr committer date, relative
+//	%d  ref names, " (HEAD -> main, dev)"
+//	%s  subject          %b  body
+//	%B  raw message      %n  newline
+//	%%  a literal %
+//
+// Unknown placeholders are left as they are.
+func Format(lc LogCommit, template string, now time.Time) string {
+	var sb strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' || i == len(template)-1 {
+			sb.WriteByte(template[i])
+			continue
+		}
+
+		rest := template[i+1:]
+		value, n := placeholder(lc, rest, now)
+		if n == 0 {
+			sb.WriteByte('%')
+			continue
+		}
+
+		sb.WriteString(value)
+		i += n
+	}
+	return sb.String()
+}
+
+// placeholder expands the placeholder at the start of s, returning its value and length.
+func placeholder(lc LogCommit, s string, now time.Time) (string, int) {
+	if len(s) >= 2 {
+		switch s[:2] {
+		case "an":
+			return lc.Author, 2
+		case "ae":
+			return lc.Email, 2
+		case "ad":
+			return lc.Date.Format(time.RFC1123Z), 2
+		case "ai":
+			return lc.Date.Format("2006-01-02 15:04:05 -0700"), 2
+		case "at":
+			return strconv.FormatInt(lc.Date.Unix(), 10), 2
+		case "ar":
+			return date.Relative(lc.Date, now), 2
+		case "cn":
+			return lc.Committer, 2
+		case "ce":
+			return lc.CommitterEmail, 2
+		case "cd":
+			return lc.CommitDate.Format(time.RFC1123Z), 2
+		case "ci":
+			return lc.CommitDate.Format("2006-01-02 15:04:05 -0700"), 2
+		case "ct":
+			return strconv.FormatInt(lc.CommitDate.Unix(), 10), 2
+		case "cr":
+			return date.Relative(lc.CommitDate, now), 2
+		}
+	}
+
+	switch s[0] {
+	case 'H':
+		return lc.Hash.String(), 1
+	case 'h':
+		return lc.Hash.Short(7), 1
+	case 'P':
+		parents := make([]string, 0, len(lc.Parents))
+		for _, p := range lc.Parents {
+			parents = append(parents, p.String())
+		}
+		return strings.Join(parents, " "), 1
+	case 'p':
+		parents := make([]string, 0, len(lc.Parents))
+		for _, p := range lc.Parents {
+			parents = append(parents, p.Short(7))
+		}
+		return strings.Join(parents, " "), 1
+	case 'd':
+		return lc.Decoration(), 1
+	case 's':
+		return lc.Subject(), 1
+	case 'b':
+		return lc.Body(), 1
+	case 'B':
+		return lc.Message, 1
+	case 'n':
+		return "\n", 1
+	case '%':
+		return "%", 1
+	}
+
+	return "", 0
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+)
+
+func TestFormat(t *testing.T) {
+	hash, _ := object.NewObjectHash("1c94cac361efe44a77f308090e92805f5bdf0d0f")
+	parent, _ := object.NewObjectHash("333029753261891ec0e95e61b95aeea8ca11873c")
+	zone := time.FixedZone("", -3*3600)
+	lc := LogCommit{
+		Hash:           hash,
+		Parents:        []object.ObjectHash{parent},
+		Author:         "Alice",
+		Email:          "alice@example.com",
+		Date:           time.Date(2024, 1, 31, 10, 0, 0, 0, zone),
+		Committer:      "Bob",
+		CommitterEmail: "bob@example.com",
+		CommitDate:     time.Date(2024, 2, 1, 10, 0, 0, 0, zone),
+		Message:        "subject\n\nbody line",
+		Refs:           []string{"HEAD -> main", "dev"},
+	}
+	now := lc.CommitDate.Add(2 * time.Hour)
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"%H", "1c94cac361efe44a77f308090e92805f5bdf0d0f"},
+		{"%h %p", "1c94cac 3330297"},
+		{"%P", "333029753261891ec0e95e61b95aeea8ca11873c"},
+		{"%an <%ae>", "Alice <alice@example.com>"},
+		{"%cn <%ce>", "Bob <bob@example.com>"},
+		{"%ai|%ci", "2024-01-31 10:00:00 -0300|2024-02-01 10:00:00 -0300"},
+		{"%at %ct", "1706706000 1706792400"},
+		{"%ad", "Wed, 31 Jan 2024 10:00:00 -0300"},
+		{"%ar, %cr", "26 hours ago, 2 hours ago"},
+		{"%h%d %s", "1c94cac (HEAD -> main, dev) subject"},
+		{"%s%n%b", "subject\nbody line"},
+		{"%B", "subject\n\nbody line"},
+		{"100%% %x %", "100% %x %"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			if got := Format(lc, tt.template, now); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogCommitJSON(t *testing.T) {
+	commits := newHistory(t)
+	result, err := Log(".", LogOptions{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(result.Logs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"hash":            commits["M"].String(),
+		"parents":         []any{commits["D"].String(), commits["E"].String()},
+		"author":          "T",
+		"email":           "t@x",
+		"date":            "1970-01-01T00:00:06Z",
+		"committer":       "T",
+		"committer_email": "t@x",
+		"commit_date":     "1970-01-01T00:00:06Z",
+		"message":         "M",
+		"refs":            []any{"HEAD -> main"},
+	}
+	if gotJSON, wantJSON := mustMarshal(t, got), mustMarshal(t, want); gotJSON != wantJSON {
+		t.Errorf("got %s\nwant %s", gotJSON, wantJSON)
+	}
+}
+
+func mustMarshal(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,188 @@
+package log
+
+import (
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+)
+
+// GraphRow holds the ASCII lanes drawn next to one commit.
+//   - Before: standalone lines printed before the commit (lanes joining into it)
+//   - Commit: prefix for the first line of the commit
+//   - After:  prefixes for the following lines, printed standalone if the commit has fewer lines
+//   - Padding: prefix for any further line of the commit
+type GraphRow struct {
+	Before  []string
+	Commit  string
+	After   []string
+	Padding string
+}
+
+// graph keeps the lanes between commits. Each lane holds the hash of the commit
+// expected to show up next in it.
+type graph struct {
+	lanes []string
+}
+
+func newGraph() *graph {
+	return &graph{}
+}
+
+func (g *graph) next(hash object.ObjectHash, parents []object.ObjectHash) GraphRow {
+	row := GraphRow{}
+	h := hash.String()
+
+	// lanes waiting for this commit join into the first of them
+	positions := []int{}
+	for i, l := range g.lanes {
+		if l == h {
+			positions = append(positions, i)
+		}
+	}
+
+	if len(positions) > 1 {
+		removed := map[int]struct{}{}
+		for _, p := range positions[1:] {
+			removed[p] = struct{}{}
+		}
+
+		line := newGraphLine(len(g.lanes))
+		lanes := []string{}
+		for i, l := range g.lanes {
+			newPos := len(lanes)
+			if _, ok := removed[i]; ok {
+				newPos = positions[0]
+			} else {
+				lanes = append(lanes, l)
+			}
+			line.edge(i, newPos)
+		}
+		row.Before = append(row.Before, line.String())
+		g.lanes = lanes
+	}
+
+	idx := -1
+	for i, l := range g.lanes {
+		if l == h {
+			idx = i
+			break
+		}
+	}
+
+	// a commit nobody is waiting for starts a new lane
+	if idx == -1 {
+		g.lanes = append(g.lanes, h)
+		idx = len(g.lanes) - 1
+	}
+
+	commitLine := newGraphLine(len(g.lanes))
+	for i := range g.lanes {
+		commitLine.set(2*i, '|')
+	}
+	commitLine.set(2*idx, '*')
+
+	// replace the commit lane with its parents
+	lanes := make([]string, 0, len(g.lanes)+len(parents))
+	lanes = append(lanes, g.lanes[:idx]...)
+	for _, p := range parents {
+		lanes = append(lanes, p.String())
+	}
+	lanes = append(lanes, g.lanes[idx+1:]...)
+
+	if len(parents) != 1 {
+		width := len(g.lanes)
+		if len(lanes) > width {
+			width = len(lanes)
+		}
+		line := newGraphLine(width)
+		for i := range g.lanes {
+			switch {
+			case i < idx:
+				line.edge(i, i)
+			case i == idx:
+				for n := range parents {
+					line.edge(idx, idx+n)
+				}
+			default:
+				line.edge(i, i+len(parents)-1)
+			}
+		}
+
+		// a root commit with no lanes on its right needs no connector
+		if strings.TrimSpace(line.String()) != strings.TrimSpace(lanesLine(len(lanes))) || len(parents) > 1 {
+			row.After = append(row.After, line.String())
+		}
+	}
+
+	g.lanes = lanes
+
+	width := len(g.lanes)
+	for _, l := range append(append([]string{}, row.Before...), row.After...) {
+		if (len(l)+1)/2 > width {
+			width = (len(l) + 1) / 2
+		}
+	}
+	if (len(commitLine.String())+1)/2 > width {
+		width = (len(commitLine.String()) + 1) / 2
+	}
+
+	for i := range row.Before {
+		row.Before[i] = pad(row.Before[i], width)
+	}
+	for i := range row.After {
+		row.After[i] = pad(row.After[i], width)
+	}
+	row.Commit = pad(commitLine.String(), width)
+	row.Padding = pad(lanesLine(len(g.lanes)), width)
+
+	return row
+}
+
+type graphLine []byte
+
+func newGraphLine(lanes int) graphLine {
+	if lanes == 0 {
+		return graphLine{}
+	}
+	return graphLine([]byte(strings.Repeat(" ", 2*lanes-1)))
+}
+
+func (l *graphLine) set(pos int, c byte) {
+	for len(*l) <= pos {
+		*l = append(*l, ' ')
+	}
+	(*l)[pos] = c
+}
+
+// edge draws the link of a lane moving from column from to column to.
+func (l *graphLine) edge(from, to int) {
+	switch {
+	case from == to:
+		l.set(2*from, '|')
+	case to < from:
+		l.set(2*from-1, '/')
+	default:
+		l.set(2*from+1+2*(to-from-1), '\\')
+	}
+}
+
+func (l graphLine) String() string {
+	return strings.TrimRight(string(l), " ")
+}
+
+func lanesLine(lanes int) string {
+	l := newGraphLine(lanes)
+	for i := 0; i < lanes; i++ {
+		l.set(2*i, '|')
+	}
+	return l.String()
+}
+
+// pad leaves room for width lanes, so text lines up in a row.
+func pad(s string, width int) string {
+	w := 2 * width
+	if len(s) >= w {
+		return s + " "
+	}
+	return s + strings.Repeat(" ", w-len(s))
+}
@@ -1,19 +1,62 @@
 package log
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/matiasmartin00/arbor/internal/branch"
 	"github.com/matiasmartin00/arbor/internal/object"
 	"github.com/matiasmartin00/arbor/internal/refs"
+	"github.com/matiasmartin00/arbor/internal/revision"
 )
 
+type Order int
+
+const (
+	// DefaultOrder shows commits newest first, as found walking the history.
+	DefaultOrder Order = iota
+	// DateOrder never shows a parent before its children, otherwise newest first.
+	DateOrder
+	// TopoOrder never shows a parent before its children and avoids interleaving lines of history.
+	TopoOrder
+)
+
+type LogOptions struct {
+	// Revisions to start from, HEAD when empty (and All is not set)
+	Revisions []string
+	// All starts from every branch and HEAD
+	All bool
+	// Limit is the max number of commits to return, no limit when <= 0
+	Limit int
+	Order Order
+	// Graph fills LogCommit.Graph with the ASCII lanes of the history
+	Graph bool
+	// Author keeps commits whose "name <email>" matches this regular expression
+	Author string
+	// Grep keeps commits whose message matches this regular expression
+	Grep string
+	// Since and Until keep commits in the date range, ignored when zero
+	Since time.Time
+	Until time.Time
+	// Paths keeps commits that changed any of these repo-relative files or directories
+	Paths []string
+}
+
 type LogCommit struct {
-	Hash    object.ObjectHash
-	Author  string
-	Email   string
-	Date    time.Time
-	Message string
+	Hash           object.ObjectHash   `json:"hash"`
+	Parents        []object.ObjectHash `json:"parents"`
+	Author         string              `json:"author"`
+	Email          string              `json:"email"`
+	Date           time.Time           `json:"date"`
+	Committer      string              `json:"committer"`
+	CommitterEmail string              `json:"committer_email"`
+	CommitDate     time.Time           `json:"commit_date"`
+	Message        string              `json:"message"`
+	// Refs point to the commit: "HEAD -> main" for the current branch, "HEAD"
+	// when detached, then the other branches
+	Refs  []string  `json:"refs"`
+	Graph *GraphRow `json:"-"`
 }
 
 type LogResult struct {
@@ -21,45 +64,102 @@ type LogResult struct {
 	NextCommit object.ObjectHash
 }
 
-func Log(repoPath string, commitHashFrom string, limit int) (LogResult, error) {
-	hash, err := calculateHash(repoPath, commitHashFrom)
+func Log(repoPath string, opts LogOptions) (LogResult, error) {
+	starts, err := startingHashes(repoPath, opts)
 	if err != nil {
 		return LogResult{}, err
 	}
 
-	if hash == nil {
+	if len(starts) == 0 {
 		println("No commits yet.")
 		return LogResult{}, nil
 	}
 
-	if limit <= 0 && limit > 100 {
-		return LogResult{}, fmt.Errorf("invalid limit should be gte 1 and lte 100")
+	// the graph is only readable if children come before their parents
+	order := opts.Order
+	if opts.Graph && order == DefaultOrder {
+		order = TopoOrder
+	}
+
+	w := newWalker(repoPath)
+	f, err := newFilter(w, opts)
+	if err != nil {
+		return LogResult{}, err
 	}
 
-	logs := make([]LogCommit, 0, limit)
+	commits := []object.Commit{}
 	var nextCommit object.ObjectHash
-	count := 0
-	for hash != nil {
-		commit, err := object.ReadCommit(repoPath, hash)
+	var filterErr error
+
+	full := func(c object.Commit) bool {
+		ok, err := f.match(c)
 		if err != nil {
-			return LogResult{}, err
+			filterErr = err
+			return false
 		}
 
-		if count == limit {
-			nextCommit = hash
-			break
+		if !ok {
+			return true
 		}
 
-		logs = append(logs, LogCommit{
-			Hash:    hash,
-			Author:  commit.Author(),
-			Email:   commit.Email(),
-			Date:    commit.Timestamp(),
-			Message: commit.Message(),
-		})
+		if opts.Limit > 0 && len(commits) == opts.Limit {
+			nextCommit = c.Hash()
+			return false
+		}
+		commits = append(commits, c)
+		return true
+	}
 
-		hash = commit.ParentHash()
-		count++
+	if order == DefaultOrder {
+		if err := w.byDate(starts, full); err != nil {
+			return LogResult{}, err
+		}
+	} else {
+		sorted, err := w.topological(starts, order == DateOrder)
+		if err != nil {
+			return LogResult{}, err
+		}
+		for _, c := range sorted {
+			if !full(c) {
+				break
+			}
+		}
+	}
+
+	if filterErr != nil {
+		return LogResult{}, filterErr
+	}
+
+	var g *graph
+	if opts.Graph {
+		g = newGraph()
+	}
+	rewritten := map[string][]object.ObjectHash{}
+
+	refNames, err := decorations(repoPath)
+	if err != nil {
+		return LogResult{}, err
+	}
+
+	logs := make([]LogCommit, 0, len(commits))
+	for _, c := range commits {
+		lc := NewLogCommit(c)
+		lc.Refs = refNames[c.Hash().String()]
+
+		if g != nil {
+			parents := c.ParentHashes()
+			if f.active() {
+				parents, err = f.rewriteParents(c, rewritten)
+				if err != nil {
+					return LogResult{}, err
+				}
+			}
+
+			row := g.next(c.Hash(), parents)
+			lc.Graph = &row
+		}
+
+		logs = append(logs, lc)
 	}
 
 	return LogResult{
@@ -68,15 +168,114 @@ func Log(repoPath string, commitHashFrom string, limit int) (LogResult, error) {
 	}, nil
 }
 
+// decorations maps the commits HEAD and the branches point to to their names.
+func decorations(repoPath string) (map[string][]string, error) {
+	names := map[string][]string{}
+
+	_, err := branch.GetCurrentBranch(repoPath)
+	if errors.Is(err, branch.ErrDetachedHead) {
+		head, err := refs.GetRefHash(repoPath)
+		if err != nil {
+			return nil, err
+		}
+		names[head.String()] = []string{"HEAD"}
+	} else if err != nil {
+		return nil, err
+	}
+
+	branches, err := branch.ListBranches(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range branches {
+		h, err := refs.GetRefHashByName(repoPath, b.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		key := h.String()
+		if b.IsActive {
+			names[key] = append([]string{"HEAD -> " + b.Name}, names[key]...)
+			continue
+		}
+		names[key] = append(names[key], b.Name)
+	}
+	return names, nil
+}
+
 func parseInt64(s string) (int64, error) {
 	var v int64
 	_, err := fmt.Sscanf(s, "%d", &v)
 	return v, err
 }
 
-func calculateHash(repoPath, commitHash string) (object.ObjectHash, error) {
-	if len(commitHash) > 0 {
-		return object.NewObjectHash(commitHash)
+// startingHashes resolves the revisions to start the walk from.
+func startingHashes(repoPath string, opts LogOptions) ([]object.ObjectHash, error) {
+	starts := []object.ObjectHash{}
+	seen := map[string]struct{}{}
+	addStart := func(h object.ObjectHash) {
+		if h == nil {
+			return
+		}
+		if _, ok := seen[h.String()]; ok {
+			return
+		}
+		seen[h.String()] = struct{}{}
+		starts = append(starts, h)
+	}
+
+	for _, r := range opts.Revisions {
+		h, err := revision.Resolve(repoPath, r)
+		if err != nil {
+			return nil, err
+		}
+		addStart(h)
+	}
+
+	if opts.All {
+		branches, err := branch.ListBranches(repoPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range branches {
+			h, err := refs.GetRefHashByName(repoPath, b.Name)
+			if err != nil {
+				return nil, err
+			}
+			addStart(h)
+		}
+
+		// a detached HEAD may have commits no branch has
+		h, err := refs.GetRefHash(repoPath)
+		if err != nil {
+			return nil, err
+		}
+		addStart(h)
+	}
+
+	if len(opts.Revisions) == 0 && !opts.All {
+		h, err := refs.GetRefHash(repoPath)
+		if err != nil {
+			return nil, err
+		}
+		addStart(h)
+	}
+
+	return starts, nil
+}
+
+// NewLogCommit returns the data of c as shown by log.
+func NewLogCommit(c object.Commit) LogCommit {
+	return LogCommit{
+		Hash:           c.Hash(),
+		Parents:        c.ParentHashes(),
+		Author:         c.Author(),
+		Email:          c.Email(),
+		Date:           c.Timestamp(),
+		Committer:      c.Committer(),
+		CommitterEmail: c.CommitterEmail(),
+		CommitDate:     c.CommitTime(),
+		Message:        c.Message(),
 	}
-	return refs.GetRefHash(repoPath)
 }
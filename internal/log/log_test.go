@@ -0,0 +1,163 @@
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
+	"github.com/matiasmartin00/arbor/internal/repo"
+)
+
+// newHistory creates a repository in a temporary directory, moves into it and
+// writes this history, main pointing to M:
+//
+//	A(1) - B(2) - D(4) - M(6)
+//	   \                 /
+//	    C(3) ------ E(5)
+//
+// The number is the commit time. g changes in B, E and M, f in C and D.
+func newHistory(t *testing.T) map[string]object.ObjectHash {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+
+	commits := map[string]object.ObjectHash{}
+	add := func(name string, seconds int64, files map[string]string, parents ...string) {
+		t.Helper()
+		entries := map[string]object.ObjectHash{}
+		for p, content := range files {
+			hash, err := object.WriteBlobData(".", []byte(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries[p] = hash
+		}
+		tree, err := object.WriteTree(".", entries, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parentHashes := []object.ObjectHash{}
+		for _, p := range parents {
+			parentHashes = append(parentHashes, commits[p])
+		}
+		sig := object.Signature{Name: "T", Email: "t@x", When: time.Unix(seconds, 0).UTC()}
+		commits[name], err = object.WriteCommit(".", tree, parentHashes, sig, sig, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	add("A", 1, map[string]string{"f": "a\n", "g": "0\n"})
+	add("B", 2, map[string]string{"f": "a\n", "g": "1\n"}, "A")
+	add("C", 3, map[string]string{"f": "c\n", "g": "0\n"}, "A")
+	add("D", 4, map[string]string{"f": "d\n", "g": "1\n"}, "B")
+	add("E", 5, map[string]string{"f": "c\n", "g": "2\n"}, "C")
+	add("M", 6, map[string]string{"f": "d\n", "g": "3\n"}, "D", "E")
+
+	if err := refs.CreateRef(".", "main", commits["M"]); err != nil {
+		t.Fatal(err)
+	}
+	return commits
+}
+
+// render returns the subjects of the commits, after the graph lanes when drawn.
+func render(logs []LogCommit) []string {
+	lines := []string{}
+	for _, l := range logs {
+		if l.Graph == nil {
+			lines = append(lines, l.Subject())
+			continue
+		}
+		lines = append(lines, l.Graph.Before...)
+		lines = append(lines, l.Graph.Commit+l.Subject())
+		lines = append(lines, l.Graph.After...)
+	}
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " ")
+	}
+	return lines
+}
+
+func TestLogOrderAndGraph(t *testing.T) {
+	tests := []struct {
+		name string
+		opts LogOptions
+		want []string
+	}{
+		{"default", LogOptions{}, []string{"M", "E", "D", "C", "B", "A"}},
+		{"date order", LogOptions{Order: DateOrder}, []string{"M", "E", "D", "C", "B", "A"}},
+		{"topo order", LogOptions{Order: TopoOrder}, []string{"M", "D", "B", "E", "C", "A"}},
+		{"graph", LogOptions{Graph: true}, []string{
+			"*   M",
+			"|\\",
+			"* | D",
+			"* | B",
+			"| * E",
+			"| * C",
+			"|/",
+			"* A",
+		}},
+		{"graph by date", LogOptions{Graph: true, Order: DateOrder}, []string{
+			"*   M",
+			"|\\",
+			"| * E",
+			"* | D",
+			"| * C",
+			"* | B",
+			"|/",
+			"* A",
+		}},
+		{"limit", LogOptions{Limit: 2}, []string{"M", "E"}},
+		{"revision", LogOptions{Revisions: []string{"D"}}, []string{"D", "B", "A"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commits := newHistory(t)
+			for i, r := range tt.opts.Revisions {
+				tt.opts.Revisions[i] = commits[r].String()
+			}
+
+			result, err := Log(".", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := render(result.Logs)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestLogPathsRewriteParents(t *testing.T) {
+	commits := newHistory(t)
+
+	result, err := Log(".", LogOptions{Graph: true, Paths: []string{"g"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// C and D do not change g, the lanes go from E and B straight to A
+	want := []string{
+		"*   M",
+		"|\\",
+		"* | B",
+		"| * E",
+		"|/",
+		"* A",
+	}
+	if got := render(result.Logs); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// the commits keep their real parents
+	if p := result.Logs[2].Parents; len(p) != 1 || !p[0].Equals(commits["C"]) {
+		t.Errorf("E has parents %v, want C", p)
+	}
+}
@@ -0,0 +1,182 @@
+package log
+
+import (
+	"container/heap"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+)
+
+// commitQueue is a priority queue of commits, the most recent commit first.
+type commitQueue []object.Commit
+
+func (q commitQueue) Len() int {
+	return len(q)
+}
+
+func (q commitQueue) Less(i, j int) bool {
+	ti, tj := q[i].CommitTime(), q[j].CommitTime()
+	if ti.Equal(tj) {
+		// deterministic order for commits created in the same second
+		return q[i].Hash().String() < q[j].Hash().String()
+	}
+	return ti.After(tj)
+}
+
+func (q commitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *commitQueue) Push(x any) {
+	*q = append(*q, x.(object.Commit))
+}
+
+func (q *commitQueue) Pop() any {
+	old := *q
+	n := len(old)
+	c := old[n-1]
+	*q = old[:n-1]
+	return c
+}
+
+// walker reads commits once and remembers them, so the orderings can revisit parents cheaply.
+type walker struct {
+	repoPath string
+	commits  map[string]object.Commit
+}
+
+func newWalker(repoPath string) *walker {
+	return &walker{
+		repoPath: repoPath,
+		commits:  map[string]object.Commit{},
+	}
+}
+
+func (w *walker) read(hash object.ObjectHash) (object.Commit, error) {
+	if c, ok := w.commits[hash.String()]; ok {
+		return c, nil
+	}
+
+	c, err := object.ReadCommit(w.repoPath, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	w.commits[hash.String()] = c
+	return c, nil
+}
+
+// byDate walks history from starts newest first and calls emit for each commit
+// until emit returns false.
+func (w *walker) byDate(starts []object.ObjectHash, emit func(object.Commit) bool) error {
+	q := &commitQueue{}
+	seen := map[string]struct{}{}
+
+	push := func(hash object.ObjectHash) error {
+		if _, ok := seen[hash.String()]; ok {
+			return nil
+		}
+		seen[hash.String()] = struct{}{}
+
+		c, err := w.read(hash)
+		if err != nil {
+			return err
+		}
+		heap.Push(q, c)
+		return nil
+	}
+
+	for _, s := range starts {
+		if err := push(s); err != nil {
+			return err
+		}
+	}
+
+	for q.Len() > 0 {
+		c := heap.Pop(q).(object.Commit)
+		if !emit(c) {
+			return nil
+		}
+
+		for _, p := range c.ParentHashes() {
+			if err := push(p); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// topological returns every commit reachable from starts with no parent before
+// any of its children. With dateOrder the ready commits are taken newest first,
+// otherwise one line of history is followed to its end before another one
+// starts, so lines of history are not interleaved.
+func (w *walker) topological(starts []object.ObjectHash, dateOrder bool) ([]object.Commit, error) {
+	all := []object.Commit{}
+	if err := w.byDate(starts, func(c object.Commit) bool {
+		all = append(all, c)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	children := map[string]int{}
+	for _, c := range all {
+		for _, p := range c.ParentHashes() {
+			children[p.String()]++
+		}
+	}
+
+	out := make([]object.Commit, 0, len(all))
+	release := func(c object.Commit, ready func(object.Commit)) {
+		for _, p := range c.ParentHashes() {
+			children[p.String()]--
+			if children[p.String()] == 0 {
+				ready(w.commits[p.String()])
+			}
+		}
+	}
+
+	if dateOrder {
+		q := &commitQueue{}
+		for _, c := range all {
+			if children[c.Hash().String()] == 0 {
+				heap.Push(q, c)
+			}
+		}
+
+		for q.Len() > 0 {
+			c := heap.Pop(q).(object.Commit)
+			out = append(out, c)
+			release(c, func(p object.Commit) {
+				heap.Push(q, p)
+			})
+		}
+		return out, nil
+	}
+
+	// all is newest first, so the tips are stacked with the newest on top
+	stack := []object.Commit{}
+	for i := len(all) - 1; i >= 0; i-- {
+		if children[all[i].Hash().String()] == 0 {
+			stack = append(stack, all[i])
+		}
+	}
+
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		out = append(out, c)
+
+		// push in reverse so the first parent is the next one to be shown
+		ready := []object.Commit{}
+		release(c, func(p object.Commit) {
+			ready = append(ready, p)
+		})
+		for i := len(ready) - 1; i >= 0; i-- {
+			stack = append(stack, ready[i])
+		}
+	}
+
+	return out, nil
+}
@@ -1,16 +1,21 @@
 package merge
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
-	"github.com/matiasmartin00/arbor/internal/add"
 	"github.com/matiasmartin00/arbor/internal/branch"
 	"github.com/matiasmartin00/arbor/internal/commit"
+	"github.com/matiasmartin00/arbor/internal/diff"
+	"github.com/matiasmartin00/arbor/internal/hooks"
+	"github.com/matiasmartin00/arbor/internal/index"
+	"github.com/matiasmartin00/arbor/internal/journal"
 	"github.com/matiasmartin00/arbor/internal/object"
 	"github.com/matiasmartin00/arbor/internal/refs"
-	"github.com/matiasmartin00/arbor/internal/utils"
 	"github.com/matiasmartin00/arbor/internal/worktree"
 )
 
@@ -42,8 +47,16 @@ func (md MergeDetail) IsFastForward() bool {
 	return md.Type == fastForward
 }
 
-func Merge(repoPath, branchName string) (MergeDetail, error) {
+type MergeOptions struct {
+	// NoVerify skips the pre-merge hook
+	NoVerify bool
+}
+
+func Merge(repoPath, branchName string, opts MergeOptions) (MergeDetail, error) {
 	currentBranch, err := branch.GetCurrentBranch(repoPath)
+	if errors.Is(err, branch.ErrDetachedHead) {
+		return MergeDetail{}, fmt.Errorf("cannot merge into a detached HEAD, switch to a branch first")
+	}
 	if err != nil {
 		return MergeDetail{}, err
 	}
@@ -62,7 +75,13 @@ func Merge(repoPath, branchName string) (MergeDetail, error) {
 		return MergeDetail{}, err
 	}
 
-	ff, err := tryFastForwardMerge(repoPath, headHash, targetHash)
+	if !opts.NoVerify {
+		if err := hooks.Run(repoPath, hooks.PreMerge, branchName); err != nil {
+			return MergeDetail{}, err
+		}
+	}
+
+	ff, err := tryFastForwardMerge(repoPath, currentBranch, headHash, targetHash)
 	if err != nil {
 		return MergeDetail{}, err
 	}
@@ -80,7 +99,7 @@ func Merge(repoPath, branchName string) (MergeDetail, error) {
 
 }
 
-// otherwise, 3-way merge TODO: Implement rollback
+// otherwise, 3-way merge, the files, the index and MERGE_HEAD change in a transaction
 func threeWayMerge(repoPath, branchName, currentBranch string, headHash, targetHash object.ObjectHash) (MergeDetail, error) {
 
 	baseHash, err := findCommonAncestor(repoPath, headHash, targetHash)
@@ -88,23 +107,39 @@ func threeWayMerge(repoPath, branchName, currentBranch string, headHash, targetH
 		return MergeDetail{}, err
 	}
 
-	baseTreePathMap, err := buildTreePathMap(repoPath, baseHash)
+	baseFiles, err := buildTreeFiles(repoPath, baseHash)
 	if err != nil {
 		return MergeDetail{}, err
 	}
 
-	headTreePathMap, err := buildTreePathMap(repoPath, headHash)
+	headFiles, err := buildTreeFiles(repoPath, headHash)
 	if err != nil {
 		return MergeDetail{}, err
 	}
 
-	targetTreePathMap, err := buildTreePathMap(repoPath, targetHash)
+	targetFiles, err := buildTreeFiles(repoPath, targetHash)
 	if err != nil {
 		return MergeDetail{}, err
 	}
 
+	// edits on one side follow files renamed on the other side
+	moved, err := followRenames(repoPath, baseFiles, headFiles, targetFiles)
+	if err != nil {
+		return MergeDetail{}, err
+	}
+
+	baseTreePathMap := baseFiles.hashes
+	headTreePathMap := headFiles.hashes
+	targetTreePathMap := targetFiles.hashes
+
 	conflicts := []string{}
 	merged := map[string]object.ObjectHash{}
+	modes := map[string]object.FileMode{}
+
+	// files of the working directory renamed by target are removed from their old name
+	for _, p := range moved {
+		merged[p] = nil
+	}
 
 	// union all paths
 	allPaths := map[string]struct{}{}
@@ -122,32 +157,83 @@ func threeWayMerge(repoPath, branchName, currentBranch string, headHash, targetH
 		base := baseTreePathMap[path]
 		head := headTreePathMap[path]
 		target := targetTreePathMap[path]
+		modes[path] = mergeMode(baseFiles.modes[path], headFiles.modes[path], targetFiles.modes[path])
 
 		switch {
-		case head.Equals(target):
+		case sameHash(head, target):
 			merged[path] = head
-		case base.Equals(head):
+		case sameHash(base, head):
 			merged[path] = target // changed only in target
-		case base.Equals(target):
+		case sameHash(base, target):
 			merged[path] = head // changed only in head
 		default:
-			// conficlt
-			writeConflictFile(repoPath, path, branchName, head, target)
+			// conflict, the file gets both versions between markers
+			hash, err := conflictBlob(repoPath, branchName, head, target)
+			if err != nil {
+				return MergeDetail{}, err
+			}
+			merged[path] = hash
+			// the markers are text, a conflicting symlink is written as a file
+			if modes[path] == object.ModeSymlink {
+				modes[path] = object.ModeRegular
+			}
 			conflicts = append(conflicts, path)
 		}
 	}
 
-	// write merged files
-	err = writeMergedFiles(repoPath, merged)
+	tx, idx, err := journal.Begin(repoPath, "merge")
 	if err != nil {
-		return MergeDetail{}, nil
+		return MergeDetail{}, err
 	}
+	defer tx.Abort()
 
-	// add to stage area merged and conflict files
-	add.Add(repoPath, true, []string{"."})
+	// the merge commit takes the whole index, changes staged anywhere would end up in it
+	staged, err := stagedChanges(repoPath, idx, headHash)
+	if err != nil {
+		return MergeDetail{}, err
+	}
+	if len(staged) > 0 {
+		return MergeDetail{}, fmt.Errorf("your local changes to %s are staged and would be committed by merge, commit or unstage them first",
+			strings.Join(staged, ", "))
+	}
+
+	// the files that change, merged and conflict files, a nil hash removes the file
+	changes := map[string]object.ObjectHash{}
+	for p, hash := range merged {
+		ie, staged := idx[filepath.ToSlash(p)]
+		if hash == nil && !staged || hash != nil && staged && ie.Hash.Equals(hash) && ie.Mode == modes[p] {
+			continue
+		}
+		changes[p] = hash
+	}
+
+	// nothing is written when a local change would be lost
+	if err := worktree.CheckLocalChanges(repoPath, idx, changes, modes, "merge"); err != nil {
+		return MergeDetail{}, err
+	}
+
+	for p, hash := range changes {
+		key := filepath.ToSlash(p)
+		if hash == nil {
+			tx.Remove(p)
+			idx.Remove(key)
+			continue
+		}
+		tx.Write(p, hash, modes[p])
+		idx.AddEntryMode(key, hash, modes[p])
+	}
+
+	// remember the merged commit, the merge commit (now or after resolving conflicts) gets it as parent
+	tx.UpdateRefs(journal.SetMergeHead(targetHash))
+	if err := tx.Commit(); err != nil {
+		return MergeDetail{}, err
+	}
 
 	mergedFiles := make([]string, 0, len(merged))
-	for k, _ := range merged {
+	for k, h := range merged {
+		if h == nil {
+			continue
+		}
 		mergedFiles = append(mergedFiles, k)
 	}
 
@@ -162,7 +248,8 @@ func threeWayMerge(repoPath, branchName, currentBranch string, headHash, targetH
 
 	// auto commit merge
 	msg := fmt.Sprintf("Merge branch '%s' into '%s'", branchName, currentBranch)
-	mergeHash, err := commit.Commit(repoPath, msg)
+	// pre-merge already verified the merge, the commit hooks are for commits made by hand
+	mergeHash, err := commit.Commit(repoPath, commit.CommitOptions{Message: msg, NoVerify: true})
 	if err != nil {
 		return MergeDetail{}, err
 	}
@@ -176,96 +263,213 @@ func threeWayMerge(repoPath, branchName, currentBranch string, headHash, targetH
 	}, nil
 }
 
-// fast-forward TODO: impl rollback
-func tryFastForwardMerge(repoPath string, headHash, targetHash object.ObjectHash) (bool, error) {
+// followRenames finds the files renamed from base in head and in target, and moves
+// them to their new name in base and in the other side, so they are merged under
+// the new name. Files renamed on both sides are left as they are. It returns the
+// old names of the files head had and target renamed.
+func followRenames(repoPath string, base, head, target treeFiles) ([]string, error) {
+	enabled, _, err := diff.RenamesFromConfig(repoPath)
+	if err != nil || !enabled {
+		return nil, err
+	}
 
-	// detect fast-forward
-	ff, err := isAncestorCommit(repoPath, headHash, targetHash)
+	headRenames, err := renamedFrom(repoPath, base.hashes, head.hashes)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	if !ff {
-		return false, nil
+	targetRenames, err := renamedFrom(repoPath, base.hashes, target.hashes)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := worktree.RestoreCommitWorktree(repoPath, targetHash); err != nil {
-		return false, err
+	for from, to := range headRenames {
+		if _, ok := targetRenames[from]; ok {
+			continue
+		}
+		if _, ok := target.hashes[to]; ok {
+			continue
+		}
+		base.move(from, to)
+		target.move(from, to)
 	}
 
-	if err := refs.UpdateRef(repoPath, targetHash); err != nil {
-		return false, err
+	moved := []string{}
+	for from, to := range targetRenames {
+		if _, ok := headRenames[from]; ok {
+			continue
+		}
+		if _, ok := head.hashes[to]; ok {
+			continue
+		}
+		base.move(from, to)
+		if head.move(from, to) {
+			moved = append(moved, from)
+		}
 	}
 
-	return true, nil
+	return moved, nil
 }
 
-func writeMergedFiles(repoPath string, merged map[string]object.ObjectHash) error {
-	for p, hash := range merged {
-		blob, err := object.ReadBlob(repoPath, hash)
-		if err != nil {
-			return err
+// renamedFrom returns the renames (old path -> new path) from base to side.
+func renamedFrom(repoPath string, base, side map[string]object.ObjectHash) (map[string]string, error) {
+	deleted := map[string]object.ObjectHash{}
+	for p, h := range base {
+		if _, ok := side[p]; !ok {
+			deleted[p] = h
 		}
-		if err := utils.CreateDir(filepath.Dir(p)); err != nil {
-			return err
-		}
-		if err := utils.WriteFile(p, blob.Data()); err != nil {
-			return err
+	}
+
+	added := map[string]object.ObjectHash{}
+	for p, h := range side {
+		if _, ok := base[p]; !ok {
+			added[p] = h
 		}
 	}
 
-	return nil
+	renames := map[string]string{}
+	if len(deleted) == 0 || len(added) == 0 {
+		return renames, nil
+	}
+
+	found, err := diff.DetectRenames(repoPath, deleted, added, nil, diff.DefaultRenameThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range found {
+		renames[r.From] = r.To
+	}
+	return renames, nil
 }
 
-func writeConflictFile(repoPath, path, branchName string, head, target object.ObjectHash) error {
+// treeFiles are the files of a tree: their blobs and their modes, by path.
+type treeFiles struct {
+	hashes map[string]object.ObjectHash
+	modes  map[string]object.FileMode
+}
+
+// move renames from to, and reports if from was there.
+func (tf treeFiles) move(from, to string) bool {
+	h, ok := tf.hashes[from]
+	if !ok {
+		return false
+	}
+	delete(tf.hashes, from)
+	tf.hashes[to] = h
+
+	m := tf.modes[from]
+	delete(tf.modes, from)
+	tf.modes[to] = m
+	return true
+}
 
-	// conficlt
-	headBlob, err := object.ReadBlob(repoPath, head)
+// stagedChanges returns the paths where the index differs from the commit head.
+func stagedChanges(repoPath string, idx index.Index, headHash object.ObjectHash) ([]string, error) {
+	head, err := buildTreeFiles(repoPath, headHash)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	paths := []string{}
+	for p, ie := range idx {
+		h, ok := head.hashes[p]
+		if !ok || !h.Equals(ie.Hash) || head.modes[p] != ie.Mode {
+			paths = append(paths, p)
+		}
 	}
-	headLines, err := headBlob.SplitLines()
+	for p := range head.hashes {
+		if _, ok := idx[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// mergeMode picks the mode of a merged file: the side that changed it, or head
+// when both did (or the file is gone from one side).
+func mergeMode(base, head, target object.FileMode) object.FileMode {
+	if head == 0 || (base == head && target != 0) {
+		return target
+	}
+	return head
+}
+
+// fast-forward, the files, the index and the branch change in a transaction
+func tryFastForwardMerge(repoPath, currentBranch string, headHash, targetHash object.ObjectHash) (bool, error) {
+
+	// detect fast-forward
+	ff, err := isAncestorCommit(repoPath, headHash, targetHash)
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	if !ff {
+		return false, nil
+	}
+
+	if _, err := worktree.RestoreCommitWorktree(repoPath, targetHash, worktree.Options{Action: "merge"}, journal.SetBranch(currentBranch, targetHash)); err != nil {
+		return false, err
 	}
 
-	targetBlob, err := object.ReadBlob(repoPath, target)
+	return true, nil
+}
+
+// sameHash compares two hashes, nil means the file does not exist on that side.
+func sameHash(a, b object.ObjectHash) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equals(b)
+}
+
+// conflictBlob stores the content of a conflicting file: both versions between
+// conflict markers, a deleted side is empty.
+func conflictBlob(repoPath, branchName string, head, target object.ObjectHash) (object.ObjectHash, error) {
+	headLines, err := blobLines(repoPath, head)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	targetLines, err := targetBlob.SplitLines()
+
+	targetLines, err := blobLines(repoPath, target)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	content := "<<<<<<< HEAD\n" + strings.Join(headLines, "\n") + "\n=======\n" + strings.Join(targetLines, "\n") + "\n>>>>>>> " + branchName + "\n"
-	tmpFile := filepath.Join(repoPath, path)
-	if err := utils.CreateDir(filepath.Dir(tmpFile)); err != nil {
-		return err
-	}
-	if err := utils.WriteFile(tmpFile, []byte(content)); err != nil {
-		return err
+	return object.WriteBlobData(repoPath, []byte(content))
+}
+
+func blobLines(repoPath string, hash object.ObjectHash) ([]string, error) {
+	if hash == nil {
+		return nil, nil
 	}
 
-	return nil
+	blob, err := object.ReadBlob(repoPath, hash)
+	if err != nil {
+		return nil, err
+	}
+	return blob.SplitLines()
 }
 
-func buildTreePathMap(repoPath string, commitHash object.ObjectHash) (map[string]object.ObjectHash, error) {
-	treePathMap := map[string]object.ObjectHash{}
+func buildTreeFiles(repoPath string, commitHash object.ObjectHash) (treeFiles, error) {
+	files := treeFiles{hashes: map[string]object.ObjectHash{}, modes: map[string]object.FileMode{}}
 
 	commit, err := object.ReadCommit(repoPath, commitHash)
 	if err != nil {
-		return nil, err
+		return treeFiles{}, err
 	}
 
 	tree, err := object.ReadTree(repoPath, commit.TreeHash())
 	if err != nil {
-		return nil, err
+		return treeFiles{}, err
 	}
 
-	tree.FillPathMap(treePathMap)
+	tree.FillPathMap(files.hashes)
+	tree.FillModeMap(files.modes)
 
-	return treePathMap, nil
+	return files, nil
 }
 
 func isAncestorCommit(repoPath string, maybeAncestor, targetHash object.ObjectHash) (bool, error) {
@@ -274,7 +478,7 @@ func isAncestorCommit(repoPath string, maybeAncestor, targetHash object.ObjectHa
 	}
 
 	toVisit := []object.ObjectHash{targetHash}
-	seen := map[object.ObjectHash]struct{}{}
+	seen := map[string]struct{}{}
 	for len(toVisit) > 0 {
 		c := toVisit[0]
 		toVisit = toVisit[1:]
@@ -282,19 +486,17 @@ func isAncestorCommit(repoPath string, maybeAncestor, targetHash object.ObjectHa
 			return true, nil
 		}
 
-		if _, ok := seen[c]; ok {
+		if _, ok := seen[c.String()]; ok {
 			continue
 		}
 
-		seen[c] = struct{}{}
-		commit, err := object.ReadCommit(repoPath, object.ObjectHash(c))
+		seen[c.String()] = struct{}{}
+		commit, err := object.ReadCommit(repoPath, c)
 		if err != nil {
 			continue
 		}
 
-		if commit.ParentHash() != nil {
-			toVisit = append(toVisit, commit.ParentHash())
-		}
+		toVisit = append(toVisit, commit.ParentHashes()...)
 	}
 	return false, nil
 }
@@ -307,13 +509,50 @@ func findCommonAncestor(repoPath string, a, b object.ObjectHash) (object.ObjectH
 	ancA := allAncestors(repoPath, a)
 	ancB := allAncestors(repoPath, b)
 
+	common := map[string]object.ObjectHash{}
 	for k, v := range ancA {
 		if _, ok := ancB[k]; ok {
-			return v, nil
+			common[k] = v
+		}
+	}
+
+	if len(common) == 0 {
+		return nil, fmt.Errorf("ancestor not found")
+	}
+
+	// with merge commits there may be several common ancestors, keep only the
+	// best ones (those that are not an ancestor of another common ancestor)
+	best := map[string]object.ObjectHash{}
+	for k, v := range common {
+		best[k] = v
+	}
+	for k, v := range common {
+		if _, ok := best[k]; !ok {
+			continue
+		}
+		for ak := range allAncestors(repoPath, v) {
+			if ak != k {
+				delete(best, ak)
+			}
+		}
+	}
+
+	// pick the most recent one to be deterministic
+	var base object.ObjectHash
+	var baseTime time.Time
+	for _, v := range best {
+		c, err := object.ReadCommit(repoPath, v)
+		if err != nil {
+			return nil, err
+		}
+		if base == nil || c.CommitTime().After(baseTime) ||
+			(c.CommitTime().Equal(baseTime) && v.String() < base.String()) {
+			base = v
+			baseTime = c.CommitTime()
 		}
 	}
 
-	return nil, fmt.Errorf("ancestor not found")
+	return base, nil
 }
 
 func allAncestors(repoPath string, start object.ObjectHash) map[string]object.ObjectHash {
@@ -332,9 +571,7 @@ func allAncestors(repoPath string, start object.ObjectHash) map[string]object.Ob
 			continue
 		}
 
-		if data.ParentHash() != nil {
-			queue = append(queue, data.ParentHash())
-		}
+		queue = append(queue, data.ParentHashes()...)
 	}
 
 	return m
@@ -0,0 +1,169 @@
+package merge
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/add"
+	"github.com/matiasmartin00/arbor/internal/branch"
+	"github.com/matiasmartin00/arbor/internal/checkout"
+	"github.com/matiasmartin00/arbor/internal/commit"
+	"github.com/matiasmartin00/arbor/internal/refs"
+	"github.com/matiasmartin00/arbor/internal/repo"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// newRepo creates a repository in a temporary directory and moves into it.
+func newRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("ARBOR_CONFIG_USER__NAME", "T")
+	t.Setenv("ARBOR_CONFIG_USER__EMAIL", "t@x")
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commitFiles writes and stages the files and commits them.
+func commitFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	paths := []string{}
+	for p, content := range files {
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	if _, err := add.Add(".", false, paths); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := commit.Commit(".", commit.CommitOptions{Message: "test"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// diverge makes "topic" change f and "main" change g, and leaves main checked out.
+func diverge(t *testing.T) {
+	t.Helper()
+	newRepo(t)
+	commitFiles(t, map[string]string{"f": "a\n", "g": "x\n"})
+	if err := branch.CreateBranch(".", "topic"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checkout.Checkout(".", "topic", checkout.CheckoutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, map[string]string{"f": "a2\n"})
+	if _, err := checkout.Checkout(".", "main", checkout.CheckoutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, map[string]string{"g": "y\n"})
+}
+
+func TestThreeWayMergeKeepsLocalChanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T)
+		err   string
+	}{
+		{"modified", func(t *testing.T) {
+			os.WriteFile("f", []byte("LOCAL\n"), 0o644)
+		}, "your local changes to f"},
+		{"staged", func(t *testing.T) {
+			os.WriteFile("f", []byte("LOCAL\n"), 0o644)
+			if _, err := add.Add(".", false, []string{"f"}); err != nil {
+				t.Fatal(err)
+			}
+			os.WriteFile("f", []byte("a\n"), 0o644)
+		}, "your local changes to f"},
+		{"staged elsewhere", func(t *testing.T) {
+			os.WriteFile("g", []byte("LOCAL\n"), 0o644)
+			if _, err := add.Add(".", false, []string{"g"}); err != nil {
+				t.Fatal(err)
+			}
+		}, "your local changes to g"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diverge(t)
+			tt.setup(t)
+			before, _ := os.ReadFile("f")
+
+			_, err := Merge(".", "topic", MergeOptions{NoVerify: true})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got %v, want %q", err, tt.err)
+			}
+			if after, _ := os.ReadFile("f"); string(after) != string(before) {
+				t.Errorf("f was overwritten with %q", after)
+			}
+		})
+	}
+
+	t.Run("unrelated change", func(t *testing.T) {
+		diverge(t)
+		os.WriteFile("g", []byte("LOCAL\n"), 0o644)
+
+		if _, err := Merge(".", "topic", MergeOptions{NoVerify: true}); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile("f"); string(data) != "a2\n" {
+			t.Errorf("f holds %q, want the merged a2", data)
+		}
+		if data, _ := os.ReadFile("g"); string(data) != "LOCAL\n" {
+			t.Errorf("the local change to g was lost, it holds %q", data)
+		}
+	})
+}
+
+func TestThreeWayMergeRefusesStagedChanges(t *testing.T) {
+	diverge(t)
+	os.WriteFile("new.txt", []byte("new\n"), 0o644)
+	if _, err := add.Add(".", false, []string{"new.txt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Merge(".", "topic", MergeOptions{NoVerify: true})
+	if err == nil || !strings.Contains(err.Error(), "new.txt") {
+		t.Fatalf("got %v, want the staged new.txt refused", err)
+	}
+	if data, _ := os.ReadFile("f"); string(data) != "a\n" {
+		t.Errorf("f was merged to %q", data)
+	}
+	if utils.Exists(filepath.Join(utils.GetRepoDir("."), refs.MergeHeadFile)) {
+		t.Error("MERGE_HEAD was written")
+	}
+}
+
+func TestPreMergeHook(t *testing.T) {
+	diverge(t)
+	headHash, err := refs.GetRefHash(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hooksDir := filepath.Join(utils.GetRepoDir("."), "hooks")
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	hook := "#!/bin/sh\necho \"$1\" > merged\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(hooksDir, "pre-merge"), []byte(hook), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Merge(".", "topic", MergeOptions{}); err == nil || !strings.Contains(err.Error(), "pre-merge hook") {
+		t.Fatalf("got %v, want the merge refused by the hook", err)
+	}
+	if data, _ := os.ReadFile("merged"); string(data) != "topic\n" {
+		t.Errorf("the hook got %q, want the merged branch", data)
+	}
+	if h, _ := refs.GetRefHash("."); !h.Equals(headHash) {
+		t.Error("the refused merge moved HEAD")
+	}
+
+	if _, err := Merge(".", "topic", MergeOptions{NoVerify: true}); err != nil {
+		t.Fatalf("the merge without hooks failed: %v", err)
+	}
+}
@@ -44,6 +44,11 @@ func WriteBlob(repoPath, filePath string) (ObjectHash, error) {
 	return writeObject(repoPath, data, BlobType)
 }
 
+// WriteBlobData stores data as a blob.
+func WriteBlobData(repoPath string, data []byte) (ObjectHash, error) {
+	return writeObject(repoPath, data, BlobType)
+}
+
 func ReadBlob(repoPath string, hash ObjectHash) (Blob, error) {
 	data, objType, err := readObject(repoPath, hash)
 	if err != nil {
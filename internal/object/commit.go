@@ -15,18 +15,35 @@ const (
 )
 
 type Commit interface {
+	Hash() ObjectHash
 	ParentHash() ObjectHash
+	ParentHashes() []ObjectHash
 	TreeHash() ObjectHash
 	Author() string
 	Email() string
 	Timestamp() time.Time
+	Committer() string
+	CommitterEmail() string
+	CommitTime() time.Time
 	Message() string
 }
 
+// Signature identifies who authored or committed a change, and when.
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+// String formats the signature as stored in commits: "name <email> <unix time> <+hhmm offset>".
+func (s Signature) String() string {
+	return fmt.Sprintf("%s <%s> %d %s", s.Name, s.Email, s.When.Unix(), s.When.Format("-0700"))
+}
+
 type commit struct {
 	hash               ObjectHash
 	tree               ObjectHash
-	parent             ObjectHash
+	parents            []ObjectHash
 	author             string
 	authorEmail        string
 	authorTimestamp    time.Time
@@ -37,12 +54,26 @@ type commit struct {
 	raw                []byte
 }
 
+func (c *commit) Hash() ObjectHash {
+	return c.hash
+}
+
 func (c *commit) TreeHash() ObjectHash {
 	return c.tree
 }
 
+// ParentHash returns the first parent, or nil for a root commit.
 func (c *commit) ParentHash() ObjectHash {
-	return c.parent
+	if len(c.parents) == 0 {
+		return nil
+	}
+	return c.parents[0]
+}
+
+// ParentHashes returns every parent in the order they were recorded.
+// Merge commits have more than one.
+func (c *commit) ParentHashes() []ObjectHash {
+	return c.parents
 }
 
 func (c *commit) Author() string {
@@ -63,10 +94,30 @@ func (c *commit) Email() string {
 	return c.authorEmail
 }
 
+// Timestamp returns the author date, in the timezone of the author.
 func (c *commit) Timestamp() time.Time {
 	return c.authorTimestamp
 }
 
+func (c *commit) Committer() string {
+	if len(c.committer) == 0 {
+		return "unknown"
+	}
+	return c.committer
+}
+
+func (c *commit) CommitterEmail() string {
+	if len(c.committerEmail) == 0 {
+		return "unknown"
+	}
+	return c.committerEmail
+}
+
+// CommitTime returns the committer date, in the timezone of the committer.
+func (c *commit) CommitTime() time.Time {
+	return c.committerTimestamp
+}
+
 func ReadCommit(repoPath string, hash ObjectHash) (Commit, error) {
 	data, objType, err := readObject(repoPath, hash)
 	if err != nil {
@@ -85,21 +136,29 @@ func ReadCommit(repoPath string, hash ObjectHash) (Commit, error) {
 		return nil, err
 	}
 
-	tree, err := NewObjectHash(headers[headerTree])
+	tree, err := NewObjectHash(firstHeader(headers, headerTree))
 	if err != nil {
 		return nil, fmt.Errorf("invalid commit object (%s) no tree found. err %v", hash, err)
 	}
 
-	authorLine := headers[headerAuthor]
+	authorLine := firstHeader(headers, headerAuthor)
 	author, authorEmail, authorTimestamp := parseAuthorCommitterLine(authorLine)
-	committerLine := headers[headerCommitter]
+	committerLine := firstHeader(headers, headerCommitter)
 	committer, committerEmail, committerTimestamp := parseAuthorCommitterLine(committerLine)
 
-	parent, _ := NewObjectHash(headers[headerParent])
+	parents := make([]ObjectHash, 0, len(headers[headerParent]))
+	for _, p := range headers[headerParent] {
+		parent, err := NewObjectHash(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid commit object (%s) bad parent %q", hash, p)
+		}
+		parents = append(parents, parent)
+	}
 
 	return &commit{
+		hash:               hash,
 		tree:               tree,
-		parent:             parent,
+		parents:            parents,
 		author:             author,
 		authorEmail:        authorEmail,
 		authorTimestamp:    authorTimestamp,
@@ -140,35 +199,58 @@ func parseAuthorCommitterLine(line string) (string, string, time.Time) {
 	}
 
 	timestamp := time.Unix(epoch, 0).UTC()
+	if len(timeParts) == 2 {
+		if loc, ok := parseTimezone(timeParts[1]); ok {
+			timestamp = timestamp.In(loc)
+		}
+	}
 	return name, email, timestamp
 }
 
-func WriteCommit(repoPath string, treeHash ObjectHash, parentHash ObjectHash, message string) (ObjectHash, error) {
-	return writeObject(repoPath, buildCommitContent(treeHash, parentHash, message), CommitType)
-}
+// parseTimezone reads an offset like "+0200" or "-0530".
+func parseTimezone(s string) (*time.Location, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) != 5 || (s[0] != '+' && s[0] != '-') {
+		return nil, false
+	}
+
+	hours, err := parseInt64(s[1:3])
+	if err != nil {
+		return nil, false
+	}
+	minutes, err := parseInt64(s[3:5])
+	if err != nil {
+		return nil, false
+	}
 
-func buildCommitContent(treeHash ObjectHash, parentHash ObjectHash, message string) []byte {
-	// author
-	user := os.Getenv("USER")
-	if len(user) == 0 {
-		user = "anonymous"
+	offset := int(hours*3600 + minutes*60)
+	if s[0] == '-' {
+		offset = -offset
 	}
-	email := fmt.Sprintf("%s@localhost", user)
-	ts := time.Now().Unix()
 
+	return time.FixedZone(s, offset), true
+}
+
+// WriteCommit stores a commit object. parents may be empty for a root commit,
+// and holds more than one hash for a merge commit.
+func WriteCommit(repoPath string, treeHash ObjectHash, parents []ObjectHash, author, committer Signature, message string) (ObjectHash, error) {
+	return writeObject(repoPath, buildCommitContent(treeHash, parents, author, committer, message), CommitType)
+}
+
+func buildCommitContent(treeHash ObjectHash, parents []ObjectHash, author, committer Signature, message string) []byte {
 	// commit content
 	data := fmt.Sprintf("%s %s\n", headerTree, treeHash)
-	if parentHash != nil {
-		data += fmt.Sprintf("%s %s\n", headerParent, parentHash)
+	for _, p := range parents {
+		data += fmt.Sprintf("%s %s\n", headerParent, p)
 	}
-	data += fmt.Sprintf("%s %s <%s> %d +0000\n", headerAuthor, user, email, ts)
-	data += fmt.Sprintf("%s %s <%s> %d +0000\n\n", headerCommitter, user, email, ts)
+	data += fmt.Sprintf("%s %s\n", headerAuthor, author)
+	data += fmt.Sprintf("%s %s\n\n", headerCommitter, committer)
 	data += message + "\n"
 
 	return []byte(data)
 }
 
-func parseCommitContent(data []byte) (map[string]string, string, error) {
+func parseCommitContent(data []byte) (map[string][]string, string, error) {
 	s := string(data)
 	parts := strings.SplitN(s, "\n\n", 2)
 	if len(parts) != 2 {
@@ -176,7 +258,7 @@ func parseCommitContent(data []byte) (map[string]string, string, error) {
 	}
 
 	headers := strings.Split(parts[0], "\n")
-	hmap := make(map[string]string)
+	hmap := make(map[string][]string)
 	for _, h := range headers {
 		if len(h) == 0 {
 			continue
@@ -187,7 +269,7 @@ func parseCommitContent(data []byte) (map[string]string, string, error) {
 			continue
 		}
 
-		hmap[kv[0]] = kv[1]
+		hmap[kv[0]] = append(hmap[kv[0]], kv[1])
 	}
 
 	msg := ""
@@ -199,6 +281,13 @@ func parseCommitContent(data []byte) (map[string]string, string, error) {
 	return hmap, msg, nil
 }
 
+func firstHeader(headers map[string][]string, key string) string {
+	if len(headers[key]) == 0 {
+		return ""
+	}
+	return headers[key][0]
+}
+
 func parseInt64(s string) (int64, error) {
 	var v int64
 	_, err := fmt.Sscanf(s, "%d", &v)
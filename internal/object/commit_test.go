@@ -0,0 +1,80 @@
+package object
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCommitRoundTrip(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	tree, err := WriteTree(".", map[string]ObjectHash{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, _ := NewObjectHash(testHash)
+	author := Signature{Name: "Alice", Email: "alice@example.com", When: time.Date(2024, 1, 31, 10, 0, 0, 0, time.FixedZone("", -(5*3600+30*60)))}
+	committer := Signature{Name: "Bob", Email: "bob@example.com", When: time.Date(2024, 2, 1, 9, 15, 0, 0, time.FixedZone("", 2*3600))}
+
+	hash, err := WriteCommit(".", tree, []ObjectHash{parent}, author, committer, "subject\n\nbody")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ReadCommit(".", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Author() != "Alice" || c.Email() != "alice@example.com" || c.Committer() != "Bob" || c.CommitterEmail() != "bob@example.com" {
+		t.Errorf("got author %s <%s> and committer %s <%s>", c.Author(), c.Email(), c.Committer(), c.CommitterEmail())
+	}
+	if got := c.Timestamp().Format(time.RFC3339); got != "2024-01-31T10:00:00-05:30" {
+		t.Errorf("got author date %s, want it in -0530", got)
+	}
+	if got := c.CommitTime().Format(time.RFC3339); got != "2024-02-01T09:15:00+02:00" {
+		t.Errorf("got commit date %s, want it in +0200", got)
+	}
+	if !c.TreeHash().Equals(tree) || len(c.ParentHashes()) != 1 || !c.ParentHash().Equals(parent) {
+		t.Errorf("got tree %s and parents %v", c.TreeHash(), c.ParentHashes())
+	}
+	if c.Message() != "subject\n\nbody" {
+		t.Errorf("got message %q", c.Message())
+	}
+}
+
+func TestSignatureString(t *testing.T) {
+	s := Signature{Name: "Alice", Email: "a@x", When: time.Unix(1706706000, 0).In(time.FixedZone("", -3*3600))}
+	if got := s.String(); got != "Alice <a@x> 1706706000 -0300" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestParseAuthorCommitterLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		name   string
+		email  string
+		want   string
+		offset int
+	}{
+		{"Alice <a@x> 1706706000 +0530", "Alice", "a@x", "2024-01-31T18:30:00+05:30", 5*3600 + 30*60},
+		{"Alice <a@x> 1706706000 -0000", "Alice", "a@x", "2024-01-31T13:00:00Z", 0},
+		{"Alice <a@x> 1706706000", "Alice", "a@x", "2024-01-31T13:00:00Z", 0},
+		{"Alice <a@x> 1706706000 bad", "Alice", "a@x", "2024-01-31T13:00:00Z", 0},
+		{"Alice Smith <a@x> 1706706000 -1200", "Alice Smith", "a@x", "2024-01-31T01:00:00-12:00", -12 * 3600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			name, email, when := parseAuthorCommitterLine(tt.line)
+			_, offset := when.Zone()
+			if name != tt.name || email != tt.email || when.Format(time.RFC3339) != tt.want || offset != tt.offset {
+				t.Errorf("got %q <%s> at %s (offset %d)", name, email, when.Format(time.RFC3339), offset)
+			}
+		})
+	}
+
+	if name, email, when := parseAuthorCommitterLine("no email"); len(name) > 0 || len(email) > 0 || !when.IsZero() {
+		t.Errorf("got %q <%s> at %v from a broken line", name, email, when)
+	}
+}
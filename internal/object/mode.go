@@ -0,0 +1,104 @@
+package object
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// FileMode is the mode of a tree or index entry, with the values git uses.
+type FileMode uint32
+
+const (
+	ModeRegular    FileMode = 0o100644
+	ModeExecutable FileMode = 0o100755
+	ModeSymlink    FileMode = 0o120000
+	ModeTree       FileMode = 0o040000
+)
+
+func (m FileMode) String() string {
+	return fmt.Sprintf("%06o", uint32(m))
+}
+
+// ParseFileMode reads an octal mode, one of the Mode constants.
+func ParseFileMode(s string) (FileMode, error) {
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err == nil {
+		switch m := FileMode(n); m {
+		case ModeRegular, ModeExecutable, ModeSymlink, ModeTree:
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid file mode %q", s)
+}
+
+// FileModeOf returns the mode a file of the working directory is stored with.
+func FileModeOf(info os.FileInfo) FileMode {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return ModeSymlink
+	case info.Mode()&0o111 != 0:
+		return ModeExecutable
+	}
+	return ModeRegular
+}
+
+// ReadWorktreeFile reads a file of the working directory as it is stored in a
+// blob: the target of a symlink, which is not followed, or the content of a file.
+func ReadWorktreeFile(path string) ([]byte, FileMode, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	mode := FileModeOf(info)
+	if mode == ModeSymlink {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		return []byte(filepath.ToSlash(target)), mode, nil
+	}
+
+	if info.IsDir() {
+		return nil, 0, fmt.Errorf("%s is a directory", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, mode, nil
+}
+
+// WriteWorktreeFile writes a file of the working directory from the data of its
+// blob: a symlink to data for ModeSymlink, otherwise a file with the permissions
+// of mode. Missing directories are created. A symlink in the way is replaced,
+// never written through, and a symlinked directory is refused.
+func WriteWorktreeFile(path string, data []byte, mode FileMode) error {
+	if _, err := parentDirs(path, true); err != nil {
+		return err
+	}
+
+	if info, err := os.Lstat(path); err == nil && (mode == ModeSymlink || info.Mode()&os.ModeSymlink != 0) {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	if mode == ModeSymlink {
+		return os.Symlink(filepath.FromSlash(string(data)), path)
+	}
+
+	perm := os.FileMode(0o644)
+	if mode == ModeExecutable {
+		perm = 0o755
+	}
+
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return err
+	}
+	// the permissions of an existing file are kept by WriteFile
+	return os.Chmod(path, perm)
+}
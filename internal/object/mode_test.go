@@ -0,0 +1,117 @@
+package object
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFileMode(t *testing.T) {
+	for _, m := range []FileMode{ModeRegular, ModeExecutable, ModeSymlink, ModeTree} {
+		got, err := ParseFileMode(m.String())
+		if err != nil || got != m {
+			t.Errorf("%s reads back as %s, %v", m, got, err)
+		}
+	}
+	if ModeTree.String() != "040000" {
+		t.Errorf("got %s, want the tree mode with six digits", ModeTree)
+	}
+
+	for _, s := range []string{"", "100664", "644", "x"} {
+		if _, err := ParseFileMode(s); err == nil {
+			t.Errorf("%q is a valid mode", s)
+		}
+	}
+}
+
+func TestWorktreeFileRoundTrip(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	tests := []struct {
+		path string
+		data string
+		mode FileMode
+	}{
+		{"regular", "text\n", ModeRegular},
+		{"bin/run.sh", "#!/bin/sh\n", ModeExecutable},
+		{"link", "bin/run.sh", ModeSymlink},
+		{"dangling", "missing/target", ModeSymlink},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if err := WriteWorktreeFile(tt.path, []byte(tt.data), tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			data, mode, err := ReadWorktreeFile(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.data || mode != tt.mode {
+				t.Errorf("got %q with mode %s, want %q with %s", data, mode, tt.data, tt.mode)
+			}
+		})
+	}
+
+	// rewriting changes the kind of file, and never writes through a symlink
+	if err := WriteWorktreeFile("link", []byte("now a file\n"), ModeRegular); err != nil {
+		t.Fatal(err)
+	}
+	if data, mode, _ := ReadWorktreeFile("link"); string(data) != "now a file\n" || mode != ModeRegular {
+		t.Errorf("got %q with mode %s, want the symlink replaced", data, mode)
+	}
+	if data, _ := os.ReadFile(filepath.Join("bin", "run.sh")); string(data) != "#!/bin/sh\n" {
+		t.Errorf("the symlink target was written: %q", data)
+	}
+
+	if err := WriteWorktreeFile("bin/run.sh", []byte("#!/bin/sh\n"), ModeRegular); err != nil {
+		t.Fatal(err)
+	}
+	if _, mode, _ := ReadWorktreeFile("bin/run.sh"); mode != ModeRegular {
+		t.Errorf("got mode %s, want the executable bit dropped", mode)
+	}
+}
+
+func TestTreeModes(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	blob, err := WriteBlobData(".", []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string]ObjectHash{"a": blob, "dir/run": blob, "dir/link": blob}
+	modes := map[string]FileMode{"dir/run": ModeExecutable, "dir/link": ModeSymlink}
+	root, err := WriteTree(".", entries, modes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]FileMode{}
+	top, err := ReadTreeEntries(".", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range top {
+		got[e.Name] = e.Mode
+		if e.Type != TreeType {
+			continue
+		}
+		sub, err := ReadTreeEntries(".", e.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range sub {
+			got[e.Name+"/"+s.Name] = s.Mode
+		}
+	}
+
+	want := map[string]FileMode{"a": ModeRegular, "dir": ModeTree, "dir/run": ModeExecutable, "dir/link": ModeSymlink}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for p, m := range want {
+		if got[p] != m {
+			t.Errorf("%s has mode %s, want %s", p, got[p], m)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/matiasmartin00/arbor/internal/utils"
 )
@@ -109,7 +110,56 @@ func readObject(repoPath string, hash ObjectHash) ([]byte, ObjectType, error) {
 	return data, parseObjectType(objType), nil
 }
 
+// ReadObject reads any object and returns its type and content.
+func ReadObject(repoPath string, hash ObjectHash) (ObjectType, []byte, error) {
+	data, objType, err := readObject(repoPath, hash)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return -1, nil, fmt.Errorf("object %s not found", hash)
+		}
+		return -1, nil, err
+	}
+	return objType, data, nil
+}
+
 func createObject(data []byte, objType ObjectType) []byte {
 	header := fmt.Sprintf("%s %d\x00", objType, len(data))
 	return append([]byte(header), data...)
 }
+
+// ResolvePrefix expands an abbreviated hash to the full hash of the object it names.
+// It fails when no object or more than one object matches.
+func ResolvePrefix(repoPath, prefix string) (ObjectHash, error) {
+	if len(prefix) < 4 || notIsHex(prefix) {
+		return nil, fmt.Errorf("invalid abbreviated hash %q", prefix)
+	}
+
+	dir := filepath.Join(utils.GetObjectsDir(repoPath), prefix[:2])
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("object %s not found", prefix)
+		}
+		return nil, err
+	}
+
+	var found ObjectHash
+	for _, e := range entries {
+		full := prefix[:2] + e.Name()
+		if !strings.HasPrefix(full, prefix) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("short hash %s is ambiguous", prefix)
+		}
+		found, err = NewObjectHash(full)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("object %s not found", prefix)
+	}
+	return found, nil
+}
@@ -0,0 +1,83 @@
+package object
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// ValidateEntryName checks that name can be a tree entry: a single path element
+// that stays inside the working directory and out of the repository directory.
+func ValidateEntryName(name string) error {
+	switch {
+	case len(name) == 0:
+		return fmt.Errorf("empty tree entry name")
+	case name == "." || name == "..",
+		strings.ContainsAny(name, "/\\\x00\n"),
+		filepath.IsAbs(name) || len(filepath.VolumeName(name)) > 0,
+		utils.IsRepoDir(strings.ToLower(name)):
+		return fmt.Errorf("invalid tree entry name %q", name)
+	}
+	return nil
+}
+
+// ValidatePath checks every element of a slash separated repository path, see
+// ValidateEntryName.
+func ValidatePath(path string) error {
+	for _, name := range strings.Split(path, "/") {
+		if err := ValidateEntryName(name); err != nil {
+			return fmt.Errorf("unsafe path %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// RemoveWorktreeFile removes a file, or symlink, of the working directory. It
+// never removes through a symlinked directory.
+func RemoveWorktreeFile(path string) error {
+	ok, err := parentDirs(path, false)
+	if err != nil || !ok {
+		return err
+	}
+	return utils.RemoveFile(path)
+}
+
+// parentDirs walks the directories leading to path, relative to the working
+// directory, and fails on a symlink or a file in the way, so writes can not
+// escape through them. With create, missing directories are created, otherwise
+// it reports false when one is missing.
+func parentDirs(path string, create bool) (bool, error) {
+	dir := filepath.Dir(filepath.Clean(path))
+	if dir == "." {
+		return true, nil
+	}
+
+	cur := ""
+	for _, name := range strings.Split(filepath.ToSlash(dir), "/") {
+		cur = filepath.Join(cur, name)
+		info, err := os.Lstat(cur)
+		if os.IsNotExist(err) {
+			if !create {
+				return false, nil
+			}
+			if err := os.Mkdir(cur, 0o755); err != nil {
+				return false, err
+			}
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return false, fmt.Errorf("refusing to write %s through the symlink %s", path, cur)
+		}
+		if !info.IsDir() {
+			return false, fmt.Errorf("can not write %s, %s is not a directory", path, cur)
+		}
+	}
+	return true, nil
+}
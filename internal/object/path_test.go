@@ -0,0 +1,172 @@
+package object
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testHash = "78981922613b2afb6025042ff6bd878ac1994e85"
+
+func TestValidateEntryName(t *testing.T) {
+	valid := []string{"a.txt", "..a", "a..", ".arborignore", "arbor", "dir name", ".hidden"}
+	for _, name := range valid {
+		if err := ValidateEntryName(name); err != nil {
+			t.Errorf("ValidateEntryName(%q) = %v, want nil", name, err)
+		}
+	}
+
+	invalid := []string{"", ".", "..", "a/b", "/etc", `a\b`, `..\x`, ".arbor", ".ARBOR", ".Arbor", "a\x00b", "a\nb"}
+	for _, name := range invalid {
+		if err := ValidateEntryName(name); err == nil {
+			t.Errorf("ValidateEntryName(%q) = nil, want error", name)
+		}
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	valid := []string{"a.txt", "src/main.go", "a/.hidden/b"}
+	for _, p := range valid {
+		if err := ValidatePath(p); err != nil {
+			t.Errorf("ValidatePath(%q) = %v, want nil", p, err)
+		}
+	}
+
+	invalid := []string{"", "../x", "a/../../x", "/etc/passwd", ".arbor/config", "a/.arbor/x", "a//b", "a/", "./a"}
+	for _, p := range invalid {
+		if err := ValidatePath(p); err == nil {
+			t.Errorf("ValidatePath(%q) = nil, want error", p)
+		}
+	}
+}
+
+// maliciousTrees are tree objects a crafted repository could hold, each must be
+// refused when read.
+var maliciousTrees = map[string]string{
+	"parent":         "100644 blob " + testHash + " ..\n",
+	"repo dir":       "040000 tree " + testHash + " .arbor\n",
+	"repo dir case":  "040000 tree " + testHash + " .ARBOR\n",
+	"absolute":       "100644 blob " + testHash + " /etc/passwd\n",
+	"separator":      "100644 blob " + testHash + " ../escape\n",
+	"backslash":      "100644 blob " + testHash + " ..\\escape\n",
+	"legacy parent":  "blob " + testHash + " ../escape\n",
+	"duplicate":      "120000 blob " + testHash + " link\n040000 tree " + testHash + " link\n",
+	"current dir":    "040000 tree " + testHash + " .\n",
+	"repo dir write": "100644 blob " + testHash + " a\n040000 tree " + testHash + " .arbor\n",
+}
+
+func TestReadTreeRejectsUnsafeNames(t *testing.T) {
+	repoPath := t.TempDir()
+	for name, data := range maliciousTrees {
+		hash, err := writeObject(repoPath, []byte(data), TreeType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ReadTreeEntries(repoPath, hash); err == nil {
+			t.Errorf("%s: ReadTreeEntries accepted %q", name, data)
+		}
+		if _, err := ReadTree(repoPath, hash); err == nil {
+			t.Errorf("%s: ReadTree accepted %q", name, data)
+		}
+		if err := checkTree([]byte(data)); err == nil {
+			t.Errorf("%s: checkTree accepted %q", name, data)
+		}
+	}
+}
+
+func TestWriteTreeRejectsUnsafePaths(t *testing.T) {
+	repoPath := t.TempDir()
+	hash, err := NewObjectHash(testHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"../escape", "a/../../escape", ".arbor/config", "a/.arbor/x", "/etc/passwd"} {
+		if _, err := WriteTree(repoPath, map[string]ObjectHash{p: hash}, nil); err == nil {
+			t.Errorf("WriteTree accepted %q", p)
+		}
+	}
+
+	if _, err := WriteTree(repoPath, map[string]ObjectHash{"a/b.txt": hash}, nil); err != nil {
+		t.Errorf("WriteTree(a/b.txt) = %v", err)
+	}
+}
+
+func TestWriteWorktreeFileRefusesSymlinkedDir(t *testing.T) {
+	outside := t.TempDir()
+	t.Chdir(t.TempDir())
+	if err := os.Symlink(outside, "link"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := WriteWorktreeFile(filepath.Join("link", "file"), []byte("x"), ModeRegular)
+	if err == nil || !strings.Contains(err.Error(), "symlink") {
+		t.Fatalf("WriteWorktreeFile through a symlink = %v, want a symlink error", err)
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "file")); !os.IsNotExist(err) {
+		t.Fatalf("file written outside the worktree: %v", err)
+	}
+
+	err = WriteWorktreeFile(filepath.Join("link", "sub", "file"), []byte("x"), ModeRegular)
+	if err == nil {
+		t.Fatal("WriteWorktreeFile created directories through a symlink")
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "sub")); !os.IsNotExist(err) {
+		t.Fatalf("directory created outside the worktree: %v", err)
+	}
+}
+
+func TestWriteWorktreeFileReplacesSymlink(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "target")
+	if err := os.WriteFile(target, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(t.TempDir())
+	if err := os.Symlink(target, "file"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteWorktreeFile("file", []byte("new"), ModeRegular); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(target); string(data) != "keep" {
+		t.Fatalf("symlink target overwritten with %q", data)
+	}
+	data, mode, err := ReadWorktreeFile("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new" || mode != ModeRegular {
+		t.Fatalf("ReadWorktreeFile = %q, %s, want \"new\", %s", data, mode, ModeRegular)
+	}
+}
+
+func TestRemoveWorktreeFileRefusesSymlinkedDir(t *testing.T) {
+	outside := t.TempDir()
+	victim := filepath.Join(outside, "file")
+	if err := os.WriteFile(victim, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(t.TempDir())
+	if err := os.Symlink(outside, "link"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RemoveWorktreeFile(filepath.Join("link", "file")); err == nil {
+		t.Fatal("RemoveWorktreeFile through a symlink succeeded")
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Fatalf("file outside the worktree removed: %v", err)
+	}
+
+	// the symlink itself can be removed, its target stays
+	if err := RemoveWorktreeFile("link"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Fatalf("symlink target removed: %v", err)
+	}
+}
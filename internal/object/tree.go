@@ -11,6 +11,7 @@ import (
 type Tree interface {
 	Hash() ObjectHash
 	FillPathMap(map[string]ObjectHash)
+	FillModeMap(map[string]FileMode)
 	Blobs() []blobLine
 	SubTrees() []Tree
 	Basepath() string
@@ -23,8 +24,17 @@ type tree struct {
 	trees    []Tree
 }
 
+// TreeEntry is a single line of a tree object, subtrees are not expanded.
+type TreeEntry struct {
+	Mode FileMode
+	Type ObjectType
+	Hash ObjectHash
+	Name string
+}
+
 type blobLine struct {
 	Hash ObjectHash
+	Mode FileMode
 	File string
 }
 
@@ -54,17 +64,28 @@ func (t *tree) FillPathMap(pathMap map[string]ObjectHash) {
 	}
 }
 
+func (t *tree) FillModeMap(modeMap map[string]FileMode) {
+	for _, bl := range t.blobs {
+		modeMap[bl.File] = bl.Mode
+	}
+
+	for _, st := range t.trees {
+		st.FillModeMap(modeMap)
+	}
+}
+
 func ReadTree(repoPath string, hash ObjectHash) (Tree, error) {
 	return readRecursiveTree(repoPath, hash, "")
 }
 
 // writeTree builds a recursive tree objects from the index and returns the root tree hash.
-// tree format: each line is "blob <hash> <path>" for blobs, and "tree <hash> <path>" for subtrees.
-func WriteTree(repoPath string, entries map[string]ObjectHash) (ObjectHash, error) {
-	return writeRecursiveTree(repoPath, entries, "")
+// tree format: each line is "<mode> blob <hash> <path>" for blobs, and "040000 tree <hash> <path>"
+// for subtrees. Files missing from modes are regular files.
+func WriteTree(repoPath string, entries map[string]ObjectHash, modes map[string]FileMode) (ObjectHash, error) {
+	return writeRecursiveTree(repoPath, entries, modes, "")
 }
 
-func writeRecursiveTree(repoPath string, entries map[string]ObjectHash, basepath string) (ObjectHash, error) {
+func writeRecursiveTree(repoPath string, entries map[string]ObjectHash, modes map[string]FileMode, basepath string) (ObjectHash, error) {
 	files := make(map[string]ObjectHash)
 	subdirsSet := make(map[string]struct{})
 
@@ -101,20 +122,28 @@ func writeRecursiveTree(repoPath string, entries map[string]ObjectHash, basepath
 
 	var content []byte
 	for _, name := range names {
+		if err := ValidateEntryName(name); err != nil {
+			return nil, err
+		}
+
 		if h, ok := files[name]; ok {
 			// file
-			line := fmt.Sprintf("%s %s %s\n", BlobType, h, name)
+			mode, ok := modes[basepath+name]
+			if !ok {
+				mode = ModeRegular
+			}
+			line := fmt.Sprintf("%s %s %s %s\n", mode, BlobType, h, name)
 			content = append(content, []byte(line)...)
 			continue
 		}
 
 		// subdirectory
-		subtreeHash, err := writeRecursiveTree(repoPath, entries, basepath+name+"/")
+		subtreeHash, err := writeRecursiveTree(repoPath, entries, modes, basepath+name+"/")
 		if err != nil {
 			return nil, err
 		}
 
-		line := fmt.Sprintf("%s %s %s\n", TreeType, subtreeHash, name)
+		line := fmt.Sprintf("%s %s %s %s\n", ModeTree, TreeType, subtreeHash, name)
 		content = append(content, []byte(line)...)
 	}
 
@@ -122,12 +151,11 @@ func writeRecursiveTree(repoPath string, entries map[string]ObjectHash, basepath
 }
 
 func readRecursiveTree(repoPath string, hash ObjectHash, basepath string) (Tree, error) {
-	data, err := readTreeData(repoPath, hash)
+	entries, err := ReadTreeEntries(repoPath, hash)
 	if err != nil {
 		return nil, err
 	}
 
-	scanner := bufio.NewScanner(strings.NewReader(string(data)))
 	tree := &tree{
 		hash:     hash,
 		blobs:    []blobLine{},
@@ -135,34 +163,21 @@ func readRecursiveTree(repoPath string, hash ObjectHash, basepath string) (Tree,
 		basepath: basepath,
 	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.SplitN(line, " ", 3)
-		if len(parts) != 3 {
-			continue
-		}
-
-		typ := parts[0]
-		hash, err := NewObjectHash(parts[1])
-		if err != nil {
-			return nil, err
-		}
-
-		path := parts[2]
-
-		if typ == "blob" {
-			fullPath := filepath.FromSlash(filepath.Join(basepath, path))
+	for _, e := range entries {
+		if e.Type == BlobType {
+			fullPath := filepath.FromSlash(filepath.Join(basepath, e.Name))
 			tree.blobs = append(tree.blobs, blobLine{
-				Hash: hash,
+				Hash: e.Hash,
+				Mode: e.Mode,
 				File: fullPath,
 			})
 			continue
 		}
 
-		if typ == "tree" {
+		if e.Type == TreeType {
 			// recurse into subtree
-			subBasepath := filepath.Join(basepath, path)
-			subTree, err := readRecursiveTree(repoPath, hash, subBasepath)
+			subBasepath := filepath.Join(basepath, e.Name)
+			subTree, err := readRecursiveTree(repoPath, e.Hash, subBasepath)
 			if err != nil {
 				return nil, err
 			}
@@ -170,11 +185,90 @@ func readRecursiveTree(repoPath string, hash ObjectHash, basepath string) (Tree,
 		}
 	}
 
+	return tree, nil
+}
+
+// ReadTreeEntries reads a single tree object without expanding its subtrees.
+func ReadTreeEntries(repoPath string, hash ObjectHash) ([]TreeEntry, error) {
+	data, err := readTreeData(repoPath, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTreeEntries(data)
+}
+
+// parseTreeEntries reads the entries of a tree, failing on names that are unsafe
+// to write to the working directory or repeated.
+func parseTreeEntries(data []byte) ([]TreeEntry, error) {
+	entries := []TreeEntry{}
+	names := map[string]struct{}{}
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		e, ok, err := parseTreeLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		if err := ValidateEntryName(e.Name); err != nil {
+			return nil, err
+		}
+		if _, ok := names[e.Name]; ok {
+			return nil, fmt.Errorf("duplicate tree entry %q", e.Name)
+		}
+		names[e.Name] = struct{}{}
+
+		entries = append(entries, e)
+	}
+
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
 
-	return tree, nil
+	return entries, nil
+}
+
+// parseTreeLine reads "<mode> <type> <hash> <name>", or "<type> <hash> <name>" as
+// written before modes were recorded, for a regular file or a tree. It returns
+// false for lines that are not an entry.
+func parseTreeLine(line string) (TreeEntry, bool, error) {
+	mode := FileMode(0)
+	if first, rest, ok := strings.Cut(line, " "); ok && parseObjectType(first) < 0 {
+		m, err := ParseFileMode(first)
+		if err != nil {
+			return TreeEntry{}, false, nil
+		}
+		mode, line = m, rest
+	}
+
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) != 3 {
+		return TreeEntry{}, false, nil
+	}
+
+	typ := parseObjectType(parts[0])
+	if typ != BlobType && typ != TreeType {
+		return TreeEntry{}, false, nil
+	}
+
+	hash, err := NewObjectHash(parts[1])
+	if err != nil {
+		return TreeEntry{}, false, err
+	}
+
+	switch {
+	case mode == 0 && typ == TreeType:
+		mode = ModeTree
+	case mode == 0:
+		mode = ModeRegular
+	case (mode == ModeTree) != (typ == TreeType):
+		return TreeEntry{}, false, nil
+	}
+
+	return TreeEntry{Mode: mode, Type: typ, Hash: hash, Name: parts[2]}, true, nil
 }
 
 func readTreeData(repoPath string, hash ObjectHash) ([]byte, error) {
@@ -189,3 +283,39 @@ func readTreeData(repoPath string, hash ObjectHash) ([]byte, error) {
 
 	return data, nil
 }
+
+// FindTreeEntry looks up a slash separated path inside a tree, reading only the subtrees on the way.
+// It returns nil when the path does not exist.
+func FindTreeEntry(repoPath string, treeHash ObjectHash, path string) (*TreeEntry, error) {
+	parts := strings.Split(strings.Trim(filepath.ToSlash(path), "/"), "/")
+	hash := treeHash
+	for i, name := range parts {
+		entries, err := ReadTreeEntries(repoPath, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		var found *TreeEntry
+		for _, e := range entries {
+			if e.Name == name {
+				found = &e
+				break
+			}
+		}
+
+		if found == nil {
+			return nil, nil
+		}
+
+		if i == len(parts)-1 {
+			return found, nil
+		}
+
+		if found.Type != TreeType {
+			return nil, nil
+		}
+		hash = found.Hash
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,191 @@
+package object
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// ObjectPath returns the file an object is stored in.
+func ObjectPath(repoPath string, hash ObjectHash) string {
+	return filepath.Join(utils.GetObjectsDir(repoPath), hash.Dir(), hash.File())
+}
+
+// Exists reports if the object is in the store.
+func Exists(repoPath string, hash ObjectHash) bool {
+	return utils.Exists(ObjectPath(repoPath, hash))
+}
+
+// ListObjects returns the hash of every object in the store. Files that can not
+// be an object name are returned in invalid, relative to the objects directory.
+// Temporary files of writes in progress or interrupted are skipped, gc removes
+// the stale ones.
+func ListObjects(repoPath string) (hashes []ObjectHash, invalid []string, err error) {
+	dir := utils.GetObjectsDir(repoPath)
+	dirs, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	for _, d := range dirs {
+		if strings.HasPrefix(d.Name(), utils.TempPrefix) {
+			continue
+		}
+		if !d.IsDir() {
+			invalid = append(invalid, d.Name())
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(dir, d.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, f := range files {
+			if strings.HasPrefix(f.Name(), utils.TempPrefix) {
+				continue
+			}
+
+			name := d.Name() + f.Name()
+			if f.IsDir() || len(d.Name()) != 2 || len(name) != sha1.Size*2 || notIsHex(name) {
+				invalid = append(invalid, filepath.Join(d.Name(), f.Name()))
+				continue
+			}
+
+			hash, err := NewObjectHash(name)
+			if err != nil {
+				return nil, nil, err
+			}
+			hashes = append(hashes, hash)
+		}
+	}
+
+	return hashes, invalid, nil
+}
+
+// VerifyObject reads an object, checks that its content hashes to its name and
+// that it parses as its type, and returns its type.
+func VerifyObject(repoPath string, hash ObjectHash) (ObjectType, error) {
+	content, err := os.ReadFile(ObjectPath(repoPath, hash))
+	if err != nil {
+		return -1, err
+	}
+
+	sum := sha1.Sum(content)
+	if actual := hex.EncodeToString(sum[:]); actual != hash.String() {
+		return -1, fmt.Errorf("hash mismatch, content hashes to %s", actual)
+	}
+
+	data, objType, err := readObject(repoPath, hash)
+	if err != nil {
+		return -1, err
+	}
+
+	switch objType {
+	case BlobType:
+		return objType, nil
+	case TreeType:
+		return objType, checkTree(data)
+	case CommitType:
+		return objType, checkCommit(data)
+	default:
+		return -1, fmt.Errorf("unknown object type")
+	}
+}
+
+// checkTree is stricter than parseTreeEntries, which skips lines it does not understand.
+func checkTree(data []byte) error {
+	names := map[string]struct{}{}
+	for n, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if len(line) == 0 && len(data) == 0 {
+			break
+		}
+
+		// trees written before modes were recorded have no mode
+		if first, rest, ok := strings.Cut(line, " "); ok && parseObjectType(first) < 0 {
+			mode, err := ParseFileMode(first)
+			if err != nil {
+				return fmt.Errorf("tree line %d: %w", n+1, err)
+			}
+			if t, _, _ := strings.Cut(rest, " "); (mode == ModeTree) != (parseObjectType(t) == TreeType) {
+				return fmt.Errorf("tree line %d: mode %s does not match type %q", n+1, mode, t)
+			}
+			line = rest
+		}
+
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) != 3 {
+			return fmt.Errorf("tree line %d: expected \"<mode> <type> <hash> <name>\"", n+1)
+		}
+
+		if t := parseObjectType(parts[0]); t != BlobType && t != TreeType {
+			return fmt.Errorf("tree line %d: invalid entry type %q", n+1, parts[0])
+		}
+
+		if !isFullHash(parts[1]) {
+			return fmt.Errorf("tree line %d: invalid hash %q", n+1, parts[1])
+		}
+
+		name := parts[2]
+		if err := ValidateEntryName(name); err != nil {
+			return fmt.Errorf("tree line %d: %w", n+1, err)
+		}
+
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("tree line %d: duplicate entry %q", n+1, name)
+		}
+		names[name] = struct{}{}
+	}
+
+	return nil
+}
+
+func checkCommit(data []byte) error {
+	head, _, ok := strings.Cut(string(data), "\n\n")
+	if !ok {
+		return fmt.Errorf("commit has no message separator")
+	}
+
+	lines := strings.Split(head, "\n")
+	tree, found := strings.CutPrefix(lines[0], headerTree+" ")
+	if !found || !isFullHash(tree) {
+		return fmt.Errorf("commit does not start with a valid tree line")
+	}
+
+	authors, committers := 0, 0
+	for _, l := range lines[1:] {
+		key, value, _ := strings.Cut(l, " ")
+		switch key {
+		case headerParent:
+			if !isFullHash(value) {
+				return fmt.Errorf("invalid parent %q", value)
+			}
+		case headerAuthor, headerCommitter:
+			if _, _, when := parseAuthorCommitterLine(value); when.IsZero() {
+				return fmt.Errorf("invalid %s line %q", key, value)
+			}
+			if key == headerAuthor {
+				authors++
+			} else {
+				committers++
+			}
+		}
+	}
+
+	if authors != 1 || committers != 1 {
+		return fmt.Errorf("commit must have one author and one committer")
+	}
+	return nil
+}
+
+func isFullHash(s string) bool {
+	return len(s) == sha1.Size*2 && !notIsHex(s)
+}
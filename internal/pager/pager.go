@@ -0,0 +1,89 @@
+// Package pager pipes the output of long commands through a pager when it
+// goes to a terminal.
+package pager
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/config"
+)
+
+// Default quits when the output fits the screen (F), keeps colors (R) and does
+// not clear the screen (X).
+const Default = "less -FRX"
+
+// Command returns the pager: $ARBOR_PAGER, core.pager, $PAGER or Default.
+// An empty command or "cat" means no pager.
+func Command(repoPath string) (string, error) {
+	if v, ok := os.LookupEnv("ARBOR_PAGER"); ok {
+		return v, nil
+	}
+
+	v, ok, err := config.Get(repoPath, "core.pager")
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return v, nil
+	}
+
+	if v, ok := os.LookupEnv("PAGER"); ok {
+		return v, nil
+	}
+	return Default, nil
+}
+
+// IsTerminal reports if f is a terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Start runs command with os.Stdout piped into it, and returns a function that
+// restores os.Stdout and waits for the pager to exit. Without a pager, or if it
+// can not start, the output is left as it is.
+func Start(command string) func() {
+	fields := strings.Fields(command)
+	if len(fields) == 0 || command == "cat" {
+		return func() {}
+	}
+
+	// the shell would start and fail, losing the output
+	if _, err := exec.LookPath(fields[0]); err != nil {
+		return func() {}
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return func() {}
+	}
+	r.Close()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	return func() {
+		os.Stdout = stdout
+		w.Close()
+		cmd.Wait()
+	}
+}
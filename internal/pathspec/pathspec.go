@@ -0,0 +1,51 @@
+package pathspec
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Resolve turns paths given by the user in the directory prefix (relative to the
+// repository root) into repository relative paths with slash separators, like the
+// paths in the index. Absolute paths are made relative to root. The root itself is ".".
+func Resolve(root, prefix string, paths []string) ([]string, error) {
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		var rel string
+		if filepath.IsAbs(p) {
+			r, err := filepath.Rel(root, p)
+			if err != nil {
+				return nil, fmt.Errorf("path %s is outside repository at %s", p, root)
+			}
+			rel = r
+		} else {
+			rel = filepath.Join(prefix, p)
+		}
+
+		rel = path.Clean(filepath.ToSlash(rel))
+		if rel == ".." || strings.HasPrefix(rel, "../") {
+			return nil, fmt.Errorf("path %s is outside repository at %s", p, root)
+		}
+
+		out = append(out, rel)
+	}
+	return out, nil
+}
+
+// Match reports if the repository relative path p is one of specs or inside one
+// of them. No specs match every path.
+func Match(specs []string, p string) bool {
+	if len(specs) == 0 {
+		return true
+	}
+
+	for _, s := range specs {
+		s = strings.TrimSuffix(path.Clean(filepath.ToSlash(s)), "/")
+		if s == "." || p == s || strings.HasPrefix(p, s+"/") {
+			return true
+		}
+	}
+	return false
+}
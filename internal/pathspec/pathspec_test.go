@@ -0,0 +1,74 @@
+package pathspec
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	root := "/repo"
+	tests := []struct {
+		name   string
+		prefix string
+		paths  []string
+		want   []string
+	}{
+		{"at the root", ".", []string{"a.txt", "src/main.go"}, []string{"a.txt", "src/main.go"}},
+		{"in a subdirectory", "src", []string{"main.go", "."}, []string{"src/main.go", "src"}},
+		{"up to the root", "src/pkg", []string{"../..", "../a.go"}, []string{".", "src/a.go"}},
+		{"cleaned", "", []string{"./src//pkg/", "src/../b"}, []string{"src/pkg", "b"}},
+		{"absolute", "src", []string{"/repo/docs/a.md", "/repo"}, []string{"docs/a.md", "."}},
+		{"none", "src", []string{}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(root, tt.prefix, tt.paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	outside := []struct {
+		prefix string
+		path   string
+	}{
+		{".", ".."},
+		{"src", "../../etc/passwd"},
+		{".", "/etc/passwd"},
+		{".", "/repository/a"},
+	}
+	for _, tt := range outside {
+		if got, err := Resolve(root, tt.prefix, []string{tt.path}); err == nil {
+			t.Errorf("%s in %s resolved to %q, want it outside of the repository", tt.path, tt.prefix, got)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		specs []string
+		path  string
+		want  bool
+	}{
+		{nil, "a.txt", true},
+		{[]string{"."}, "src/main.go", true},
+		{[]string{"src"}, "src/main.go", true},
+		{[]string{"src/"}, "src/pkg/a.go", true},
+		{[]string{"src/main.go"}, "src/main.go", true},
+		{[]string{"src"}, "srcfile", false},
+		{[]string{"src/main.go"}, "src", false},
+		{[]string{"docs", "src"}, "src/a", true},
+		{[]string{"docs"}, "src/a", false},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.specs, tt.path); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.specs, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Package reach finds the objects that can be reached from the refs, HEAD,
+// MERGE_HEAD, a bisect session and the index, which are the objects a repository
+// needs. arbor has no reflog or stash, so there are no roots for them.
+package reach
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/matiasmartin00/arbor/internal/bisect"
+	"github.com/matiasmartin00/arbor/internal/branch"
+	"github.com/matiasmartin00/arbor/internal/index"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
+)
+
+// Root is an object the repository points to from outside of the object store.
+type Root struct {
+	// Name is where it comes from: "HEAD", "refs/heads/main", "index:path/to/file"
+	Name string
+	Hash object.ObjectHash
+	Type object.ObjectType
+}
+
+// Link is a reference from one object (or root) to another.
+type Link struct {
+	From string
+	Hash object.ObjectHash
+	Type object.ObjectType
+}
+
+type Result struct {
+	// Reachable holds every object found, by hash
+	Reachable map[string]object.ObjectType
+	// Missing are the referenced objects that are not in the store
+	Missing []Link
+	// Broken are the objects that could not be read or parsed, by hash
+	Broken map[string]error
+}
+
+// Roots returns the commits of every branch, HEAD, MERGE_HEAD and the BISECT_*
+// state files, and the blobs in the index.
+func Roots(repoPath string) ([]Root, error) {
+	roots := []Root{}
+
+	branches, err := branch.ListBranches(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range branches {
+		hash, err := refs.GetRefHashByName(repoPath, b.Name)
+		if err != nil {
+			return nil, fmt.Errorf("ref refs/heads/%s: %w", b.Name, err)
+		}
+		if hash != nil {
+			roots = append(roots, Root{Name: filepath.ToSlash(filepath.Join("refs/heads", b.Name)), Hash: hash, Type: object.CommitType})
+		}
+	}
+
+	head, err := refs.GetRefHash(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("HEAD: %w", err)
+	}
+	if head != nil {
+		roots = append(roots, Root{Name: "HEAD", Hash: head, Type: object.CommitType})
+	}
+
+	mergeHead, err := refs.GetMergeHead(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("MERGE_HEAD: %w", err)
+	}
+	if mergeHead != nil {
+		roots = append(roots, Root{Name: "MERGE_HEAD", Hash: mergeHead, Type: object.CommitType})
+	}
+
+	// the commits of a bisect session are still needed to go on with it or reset it
+	bisected, err := bisect.Commits(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(bisected))
+	for name := range bisected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, h := range bisected[name] {
+			roots = append(roots, Root{Name: name, Hash: h, Type: object.CommitType})
+		}
+	}
+
+	idx, err := index.Load(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("index: %w", err)
+	}
+
+	for p, e := range idx {
+		roots = append(roots, Root{Name: "index:" + p, Hash: e.Hash, Type: object.BlobType})
+	}
+
+	return roots, nil
+}
+
+// Mark walks from roots through commit parents and trees and returns every object found.
+// Each object is read once; a missing or broken object does not stop the walk.
+func Mark(repoPath string, roots []Root) *Result {
+	r := &Result{
+		Reachable: map[string]object.ObjectType{},
+		Broken:    map[string]error{},
+	}
+
+	stack := make([]Link, 0, len(roots))
+	for _, root := range roots {
+		stack = append(stack, Link{From: root.Name, Hash: root.Hash, Type: root.Type})
+	}
+
+	for len(stack) > 0 {
+		l := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		key := l.Hash.String()
+		if _, ok := r.Reachable[key]; ok {
+			continue
+		}
+
+		if !object.Exists(repoPath, l.Hash) {
+			r.Missing = append(r.Missing, l)
+			// only reported once, even if more objects point to it
+			r.Reachable[key] = l.Type
+			continue
+		}
+		r.Reachable[key] = l.Type
+
+		next, err := Links(repoPath, l.Hash, l.Type)
+		if err != nil {
+			r.Broken[key] = err
+			continue
+		}
+		stack = append(stack, next...)
+	}
+
+	return r
+}
+
+// Links returns the objects the object hash of type objType points to.
+func Links(repoPath string, hash object.ObjectHash, objType object.ObjectType) ([]Link, error) {
+	from := fmt.Sprintf("%s %s", objType, hash)
+
+	switch objType {
+	case object.CommitType:
+		c, err := object.ReadCommit(repoPath, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		out := []Link{{From: from, Hash: c.TreeHash(), Type: object.TreeType}}
+		for _, p := range c.ParentHashes() {
+			out = append(out, Link{From: from, Hash: p, Type: object.CommitType})
+		}
+		return out, nil
+	case object.TreeType:
+		entries, err := object.ReadTreeEntries(repoPath, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make([]Link, 0, len(entries))
+		for _, e := range entries {
+			out = append(out, Link{From: from, Hash: e.Hash, Type: e.Type})
+		}
+		return out, nil
+	default:
+		_, err := object.ReadBlob(repoPath, hash)
+		return nil, err
+	}
+}
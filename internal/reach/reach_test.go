@@ -0,0 +1,142 @@
+package reach
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/add"
+	"github.com/matiasmartin00/arbor/internal/bisect"
+	"github.com/matiasmartin00/arbor/internal/commit"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
+	"github.com/matiasmartin00/arbor/internal/repo"
+)
+
+// newRepo creates a repository in a temporary directory and moves into it.
+func newRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("ARBOR_CONFIG_USER__NAME", "T")
+	t.Setenv("ARBOR_CONFIG_USER__EMAIL", "t@x")
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commitFile writes and stages the file and commits it.
+func commitFile(t *testing.T, p, content string) object.ObjectHash {
+	t.Helper()
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := add.Add(".", false, []string{p}); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := commit.Commit(".", commit.CommitOptions{Message: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func rootNames(t *testing.T) []string {
+	t.Helper()
+	roots, err := Roots(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, r := range roots {
+		names = append(names, r.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestRoots(t *testing.T) {
+	newRepo(t)
+	if names := rootNames(t); len(names) != 0 {
+		t.Errorf("an empty repository has roots %q", names)
+	}
+
+	first := commitFile(t, "f", "1\n")
+	commitFile(t, "f", "2\n")
+	if err := refs.WriteMergeHead(".", first); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"HEAD", "MERGE_HEAD", "index:f", "refs/heads/main"}
+	if names := rootNames(t); !slices.Equal(names, want) {
+		t.Errorf("got roots %q, want %q", names, want)
+	}
+
+	t.Run("bisect", func(t *testing.T) {
+		if err := refs.ClearMergeHead("."); err != nil {
+			t.Fatal(err)
+		}
+		commitFile(t, "f", "3\n")
+		if _, err := bisect.Start(".", "HEAD", []string{first.String()}); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{"BISECT_BAD", "BISECT_EXPECTED_REV", "BISECT_GOOD", "BISECT_START", "HEAD", "index:f", "refs/heads/main"}
+		if names := rootNames(t); !slices.Equal(names, want) {
+			t.Errorf("got roots %q, want %q", names, want)
+		}
+	})
+}
+
+func TestMark(t *testing.T) {
+	newRepo(t)
+	first := commitFile(t, "f", "1\n")
+	second := commitFile(t, "f", "2\n")
+
+	c, err := object.ReadCommit(".", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstTree := c.TreeHash()
+	firstBlob, err := object.WriteBlobData(".", []byte("1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Mark(".", []Root{{Name: "HEAD", Hash: second, Type: object.CommitType}})
+	for _, h := range []object.ObjectHash{second, first, firstTree, firstBlob} {
+		if _, ok := r.Reachable[h.String()]; !ok {
+			t.Errorf("%s is not reachable", h)
+		}
+	}
+	if len(r.Missing) != 0 || len(r.Broken) != 0 {
+		t.Errorf("got missing %v and broken %v in a sound repository", r.Missing, r.Broken)
+	}
+
+	t.Run("missing and broken", func(t *testing.T) {
+		secondBlob, err := object.WriteBlobData(".", []byte("2\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(object.ObjectPath(".", secondBlob)); err != nil {
+			t.Fatal(err)
+		}
+		treePath := object.ObjectPath(".", firstTree)
+		os.Chmod(treePath, 0o644)
+		if err := os.WriteFile(treePath, []byte("not zlib"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		r := Mark(".", []Root{{Name: "HEAD", Hash: second, Type: object.CommitType}})
+		if len(r.Missing) != 1 || !r.Missing[0].Hash.Equals(secondBlob) {
+			t.Errorf("got missing %v, want the blob %s", r.Missing, secondBlob)
+		}
+		if _, ok := r.Broken[firstTree.String()]; !ok {
+			t.Errorf("the corrupt tree %s is not broken: %v", firstTree, r.Broken)
+		}
+		// the walk goes on past both
+		if _, ok := r.Reachable[first.String()]; !ok {
+			t.Errorf("%s is not reachable", first)
+		}
+	})
+}
@@ -10,11 +10,18 @@ import (
 	"github.com/matiasmartin00/arbor/internal/utils"
 )
 
+// HeadFile, MergeHeadFile and RefFile name the files of the refs, relative to the
+// repository directory, for code that saves and restores them as they are.
 const (
-	headFile = "HEAD"
-	refsDir  = "refs/heads"
+	HeadFile      = "HEAD"
+	MergeHeadFile = "MERGE_HEAD"
+	refsDir       = "refs/heads"
 )
 
+func RefFile(ref string) string {
+	return filepath.Join(refsDir, ref)
+}
+
 func readHEAD(repoPath string) (string, error) {
 	headPath := getHeadPath(repoPath)
 	data, err := utils.ReadFile(headPath)
@@ -35,6 +42,11 @@ func GetRefHash(repoPath string) (object.ObjectHash, error) {
 		return nil, err
 	}
 
+	// a detached HEAD holds the hash itself
+	if !IsRef(head) {
+		return object.NewObjectHash(head)
+	}
+
 	return getRefHash(repoPath, head)
 }
 
@@ -69,6 +81,10 @@ func UpdateRef(repoPath string, hash object.ObjectHash) error {
 		return err
 	}
 
+	// a detached HEAD moves by itself, no branch follows it
+	if !IsRef(head) {
+		head = HeadFile
+	}
 	refPath := filepath.Join(utils.GetRepoDir(repoPath), head)
 
 	return utils.WriteFile(refPath, []byte(hash.String()+"\n"))
@@ -97,6 +113,33 @@ func CreateRef(repoPath, ref string, hash object.ObjectHash) error {
 	return utils.WriteFile(refPath, []byte(hash.String()+"\n"))
 }
 
+// GetMergeHead returns the commit being merged when a merge is in progress,
+// or nil if there is none.
+func GetMergeHead(repoPath string) (object.ObjectHash, error) {
+	data, err := utils.ReadFile(getMergeHeadPath(repoPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return object.NewObjectHashFromBytes(data)
+}
+
+// WriteMergeHead records the commit being merged so the next commit gets it as second parent.
+func WriteMergeHead(repoPath string, hash object.ObjectHash) error {
+	return utils.WriteFile(getMergeHeadPath(repoPath), []byte(hash.String()+"\n"))
+}
+
+func ClearMergeHead(repoPath string) error {
+	return utils.RemoveFile(getMergeHeadPath(repoPath))
+}
+
+func getMergeHeadPath(path string) string {
+	return filepath.Join(utils.GetRepoDir(path), MergeHeadFile)
+}
+
 func getHeadPath(path string) string {
-	return filepath.Join(utils.GetRepoDir(path), headFile)
+	return filepath.Join(utils.GetRepoDir(path), HeadFile)
 }
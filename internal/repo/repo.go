@@ -3,6 +3,7 @@ package repo
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/matiasmartin00/arbor/internal/index"
 	"github.com/matiasmartin00/arbor/internal/object"
@@ -10,6 +11,12 @@ import (
 	"github.com/matiasmartin00/arbor/internal/utils"
 )
 
+// DirEnv names the .arbor directory to use, instead of looking for it from the
+// working directory up. Hooks run with it set.
+const DirEnv = "ARBOR_DIR"
+
+var ErrNotRepo = fmt.Errorf("not a valid arbor repository (or any of the parent directories): .arbor")
+
 func Init(path string) error {
 	dirs := []string{
 		utils.GetObjectsDir(path),
@@ -27,11 +34,45 @@ func Init(path string) error {
 func EnsureRepo(path string) error {
 	repoDir := utils.GetRepoDir(path)
 	if !utils.Exists(repoDir) {
-		return fmt.Errorf("not a valid arbor repository (or any of the parent directories): .arbor")
+		return ErrNotRepo
 	}
 	return nil
 }
 
+// FindRoot returns the root of the repository dir belongs to: the closest of dir
+// and its parents holding a .arbor directory. ARBOR_DIR, when set, names the .arbor
+// directory to use instead, and its parent is the root.
+func FindRoot(dir string) (string, error) {
+	if arborDir, ok := os.LookupEnv(DirEnv); ok && len(arborDir) > 0 {
+		abs, err := filepath.Abs(arborDir)
+		if err != nil {
+			return "", err
+		}
+
+		if !utils.IsRepoDir(filepath.Base(abs)) || !utils.Exists(abs) {
+			return "", fmt.Errorf("%s=%s is not an arbor repository directory", DirEnv, arborDir)
+		}
+		return filepath.Dir(abs), nil
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if utils.Exists(utils.GetRepoDir(dir)) {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrNotRepo
+		}
+		dir = parent
+	}
+}
+
 // ensureCleanWorktree checks that for every entry in the index the working file matches the indexed blob hash.
 // If a file is missing or modified (workdir != index) it returns an error.
 func EnsureCleanWorktree(repoPath string) error {
@@ -41,7 +82,7 @@ func EnsureCleanWorktree(repoPath string) error {
 	}
 
 	for p, ie := range index {
-		data, err := utils.ReadFile(p)
+		data, mode, err := object.ReadWorktreeFile(p)
 		if err != nil {
 			if os.IsNotExist(err) {
 				return fmt.Errorf("uncommitted changes: file %s is missing (not committed or staged)", p)
@@ -54,7 +95,7 @@ func EnsureCleanWorktree(repoPath string) error {
 			return err
 		}
 
-		if curHash.NotEquals(ie.Hash) {
+		if curHash.NotEquals(ie.Hash) || mode != ie.Mode {
 			return fmt.Errorf("uncommitted changes: file %s has been modified (not committed or staged)", p)
 		}
 	}
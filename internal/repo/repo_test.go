@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindRoot(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(DirEnv, "")
+	if err := Init(root); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{root, nested} {
+		got, err := FindRoot(dir)
+		if err != nil || got != root {
+			t.Errorf("FindRoot(%s) = %s, %v, want %s", dir, got, err, root)
+		}
+	}
+
+	outside := t.TempDir()
+	if _, err := FindRoot(outside); !errors.Is(err, ErrNotRepo) {
+		t.Errorf("got %v outside of a repository, want ErrNotRepo", err)
+	}
+
+	t.Run("ARBOR_DIR", func(t *testing.T) {
+		t.Setenv(DirEnv, filepath.Join(root, ".arbor"))
+		if got, err := FindRoot(outside); err != nil || got != root {
+			t.Errorf("got %s, %v, want %s", got, err, root)
+		}
+
+		t.Setenv(DirEnv, filepath.Join(root, "a"))
+		if _, err := FindRoot(root); err == nil {
+			t.Error("a directory other than .arbor was taken as the repository")
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package reset
+
+import (
+	"sort"
+
+	"github.com/matiasmartin00/arbor/internal/diff"
+	"github.com/matiasmartin00/arbor/internal/hunk"
+	"github.com/matiasmartin00/arbor/internal/index"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/pathspec"
+	"github.com/matiasmartin00/arbor/internal/tree"
+)
+
+// Reset unstages the changes under paths (repository relative, everything when
+// empty): their index entries go back to HEAD, and files that are not in HEAD
+// leave the index. The working directory is not touched. It returns the
+// unstaged paths, sorted.
+func Reset(repoPath string, paths []string) ([]string, error) {
+	idx, lock, err := index.LoadForUpdate(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	headMap, err := tree.GetHeadTreeMap(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	headModes, err := tree.GetHeadTreeModes(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	unstaged := []string{}
+	for p, h := range headMap {
+		if !pathspec.Match(paths, p) {
+			continue
+		}
+
+		if ie, ok := idx[p]; !ok || ie.Hash.NotEquals(h) || ie.Mode != headModes[p] {
+			idx.AddEntryMode(p, h, headModes[p])
+			unstaged = append(unstaged, p)
+		}
+	}
+
+	for p := range idx {
+		if _, ok := headMap[p]; ok || !pathspec.Match(paths, p) {
+			continue
+		}
+		idx.Remove(p)
+		unstaged = append(unstaged, p)
+	}
+
+	if err := idx.Commit(lock); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(unstaged)
+	return unstaged, nil
+}
+
+// ResetPatch walks the hunks of the staged changes under paths and unstages the
+// ones taken with p. New, deleted and binary files, and symlinks, are left out.
+// It returns the files with hunks unstaged.
+func ResetPatch(repoPath string, paths []string, p *hunk.Prompter) ([]string, error) {
+	idx, lock, err := index.LoadForUpdate(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	results, err := diff.DiffIndexVsHead(repoPath, diff.Options{Paths: paths})
+	if err != nil {
+		return nil, err
+	}
+
+	unstaged := []string{}
+	for _, dr := range results {
+		if dr.Lines == nil || dr.AHash == nil || dr.BHash == nil || dr.IsSymlink() {
+			continue
+		}
+
+		hunks, accepted, more, err := p.Select(dr, "Unstage this hunk")
+		if err != nil {
+			return nil, err
+		}
+
+		if hunk.Taken(accepted) {
+			hash, err := object.WriteBlobData(repoPath, hunk.Revert(dr.Lines, hunks, accepted))
+			if err != nil {
+				return nil, err
+			}
+			idx.AddEntryMode(dr.File, hash, dr.AMode)
+			unstaged = append(unstaged, dr.File)
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	if err := idx.Commit(lock); err != nil {
+		return nil, err
+	}
+
+	return unstaged, nil
+}
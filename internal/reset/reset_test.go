@@ -0,0 +1,68 @@
+package reset
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/add"
+	"github.com/matiasmartin00/arbor/internal/commit"
+	"github.com/matiasmartin00/arbor/internal/hunk"
+	"github.com/matiasmartin00/arbor/internal/index"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/repo"
+)
+
+// newRepo creates a repository in a temporary directory and moves into it.
+func newRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("ARBOR_CONFIG_USER__NAME", "T")
+	t.Setenv("ARBOR_CONFIG_USER__EMAIL", "t@x")
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func stage(t *testing.T, p, content string, perm os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(p, []byte(content), perm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(p, perm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := add.Add(".", false, []string{p}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResetPatchRestoresHeadMode(t *testing.T) {
+	newRepo(t)
+	stage(t, "run.sh", "#!/bin/sh\necho 1\n", 0o755)
+	if _, err := commit.Commit(".", commit.CommitOptions{Message: "script"}); err != nil {
+		t.Fatal(err)
+	}
+	stage(t, "run.sh", "#!/bin/sh\necho 2\n", 0o644)
+
+	unstaged, err := ResetPatch(".", nil, hunk.NewPrompter(strings.NewReader("y\n"), io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unstaged) != 1 || unstaged[0] != "run.sh" {
+		t.Fatalf("unstaged %q, want run.sh", unstaged)
+	}
+
+	idx, err := index.Load(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	headBlob, err := object.WriteBlobData(".", []byte("#!/bin/sh\necho 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := idx["run.sh"]; !e.Hash.Equals(headBlob) || e.Mode != object.ModeExecutable {
+		t.Errorf("got %s with mode %o, want the HEAD content and mode", e.Hash, e.Mode)
+	}
+}
@@ -0,0 +1,97 @@
+package restore
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/matiasmartin00/arbor/internal/diff"
+	"github.com/matiasmartin00/arbor/internal/hunk"
+	"github.com/matiasmartin00/arbor/internal/index"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/pathspec"
+)
+
+// Restore discards the changes in the working directory under paths (repository
+// relative), writing back the staged version of the files. It returns the
+// restored paths, sorted.
+func Restore(repoPath string, paths []string) ([]string, error) {
+	idx, err := index.Load(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := []string{}
+	for p, ie := range idx {
+		if !pathspec.Match(paths, p) {
+			continue
+		}
+
+		workPath := filepath.FromSlash(p)
+		data, mode, err := object.ReadWorktreeFile(workPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			hash, err := object.NewHashBlob(data)
+			if err != nil {
+				return nil, err
+			}
+			if hash.Equals(ie.Hash) && mode == ie.Mode {
+				continue
+			}
+		}
+
+		blob, err := object.ReadBlob(repoPath, ie.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFile(workPath, blob.Data(), ie.Mode); err != nil {
+			return nil, err
+		}
+		restored = append(restored, p)
+	}
+
+	sort.Strings(restored)
+	return restored, nil
+}
+
+// RestorePatch walks the hunks of the changes in the working directory under
+// paths and discards the ones taken with p. Deleted and binary files and
+// symlinks are left out. It returns the files with hunks discarded.
+func RestorePatch(repoPath string, paths []string, p *hunk.Prompter) ([]string, error) {
+	results, err := diff.DiffWorktreeVsIndex(repoPath, diff.Options{Paths: paths})
+	if err != nil {
+		return nil, err
+	}
+
+	restored := []string{}
+	for _, dr := range results {
+		if dr.Lines == nil || dr.BHash == nil || dr.IsSymlink() {
+			continue
+		}
+
+		hunks, accepted, more, err := p.Select(dr, "Discard this hunk from worktree")
+		if err != nil {
+			return nil, err
+		}
+
+		if hunk.Taken(accepted) {
+			content := hunk.Revert(dr.Lines, hunks, accepted)
+			if err := writeFile(filepath.FromSlash(dr.File), content, dr.BMode); err != nil {
+				return nil, err
+			}
+			restored = append(restored, dr.File)
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return restored, nil
+}
+
+func writeFile(path string, data []byte, mode object.FileMode) error {
+	return object.WriteWorktreeFile(path, data, mode)
+}
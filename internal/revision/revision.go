@@ -0,0 +1,171 @@
+package revision
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
+)
+
+const headName = "HEAD"
+
+// Resolve turns a revision into a commit hash.
+// Accepted forms: HEAD (or @), a branch name, a full or abbreviated hash,
+// optionally followed by any number of ~<n> (n-th first parent) and ^<n> (n-th parent) suffixes.
+func Resolve(repoPath, rev string) (object.ObjectHash, error) {
+	base, suffix := splitSuffix(rev)
+	if len(base) == 0 {
+		return nil, fmt.Errorf("invalid revision %q", rev)
+	}
+
+	hash, err := resolveBase(repoPath, base)
+	if err != nil {
+		return nil, err
+	}
+
+	for len(suffix) > 0 {
+		op := suffix[0]
+		suffix = suffix[1:]
+
+		digits := 0
+		for digits < len(suffix) && '0' <= suffix[digits] && suffix[digits] <= '9' {
+			digits++
+		}
+
+		n := 1
+		if digits > 0 {
+			n, err = strconv.Atoi(suffix[:digits])
+			if err != nil {
+				return nil, fmt.Errorf("invalid revision %q", rev)
+			}
+			suffix = suffix[digits:]
+		}
+
+		switch op {
+		case '~':
+			for i := 0; i < n; i++ {
+				hash, err = nthParent(repoPath, hash, 1, rev)
+				if err != nil {
+					return nil, err
+				}
+			}
+		case '^':
+			if n == 0 {
+				continue
+			}
+			hash, err = nthParent(repoPath, hash, n, rev)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid revision %q", rev)
+		}
+	}
+
+	return hash, nil
+}
+
+func splitSuffix(rev string) (string, string) {
+	i := strings.IndexAny(rev, "~^")
+	if i == -1 {
+		return rev, ""
+	}
+	return rev[:i], rev[i:]
+}
+
+func resolveBase(repoPath, base string) (object.ObjectHash, error) {
+	if base == headName || base == "@" {
+		hash, err := refs.GetRefHash(repoPath)
+		if err != nil {
+			return nil, err
+		}
+		if hash == nil {
+			return nil, fmt.Errorf("HEAD does not point to any commit yet")
+		}
+		return hash, nil
+	}
+
+	if refs.ExistsRef(repoPath, base) {
+		return refs.GetRefHashByName(repoPath, base)
+	}
+
+	hash, err := object.NewObjectHash(base)
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %q", base)
+	}
+
+	if len(base) < 40 {
+		return object.ResolvePrefix(repoPath, base)
+	}
+
+	return hash, nil
+}
+
+func nthParent(repoPath string, hash object.ObjectHash, n int, rev string) (object.ObjectHash, error) {
+	commit, err := object.ReadCommit(repoPath, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	parents := commit.ParentHashes()
+	if n > len(parents) {
+		return nil, fmt.Errorf("revision %q goes past the history of %s", rev, hash.Short(7))
+	}
+
+	return parents[n-1], nil
+}
+
+// ResolveObject extends Resolve to name any object, not only commits:
+// <rev>:<path> is the blob or tree at path in the commit, <rev>: and <rev>^{tree}
+// are its root tree, and a full or abbreviated hash can be any object.
+func ResolveObject(repoPath, rev string) (object.ObjectHash, error) {
+	if base, path, ok := strings.Cut(rev, ":"); ok {
+		if len(base) == 0 {
+			base = headName
+		}
+
+		treeHash, err := commitTree(repoPath, base)
+		if err != nil {
+			return nil, err
+		}
+
+		path = strings.Trim(path, "/")
+		if len(path) == 0 {
+			return treeHash, nil
+		}
+
+		entry, err := object.FindTreeEntry(repoPath, treeHash, path)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return nil, fmt.Errorf("path %s does not exist in %s", path, base)
+		}
+		return entry.Hash, nil
+	}
+
+	if base, ok := strings.CutSuffix(rev, "^{tree}"); ok {
+		return commitTree(repoPath, base)
+	}
+
+	if base, ok := strings.CutSuffix(rev, "^{commit}"); ok {
+		return Resolve(repoPath, base)
+	}
+
+	return Resolve(repoPath, rev)
+}
+
+func commitTree(repoPath, rev string) (object.ObjectHash, error) {
+	hash, err := Resolve(repoPath, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := object.ReadCommit(repoPath, hash)
+	if err != nil {
+		return nil, err
+	}
+	return commit.TreeHash(), nil
+}
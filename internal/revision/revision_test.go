@@ -0,0 +1,151 @@
+package revision
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/refs"
+	"github.com/matiasmartin00/arbor/internal/repo"
+)
+
+// newHistory creates a repository in a temporary directory, moves into it and
+// writes this history, main pointing to M and topic to C:
+//
+//	A - B - M
+//	 \     /
+//	  C ---
+//
+// Every commit holds dir/f with its name.
+func newHistory(t *testing.T) map[string]object.ObjectHash {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+
+	commits := map[string]object.ObjectHash{}
+	add := func(name string, parents ...string) {
+		t.Helper()
+		blob, err := object.WriteBlobData(".", []byte(name+"\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := object.WriteTree(".", map[string]object.ObjectHash{"dir/f": blob}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parentHashes := []object.ObjectHash{}
+		for _, p := range parents {
+			parentHashes = append(parentHashes, commits[p])
+		}
+		sig := object.Signature{Name: "T", Email: "t@x", When: time.Unix(int64(len(commits)), 0).UTC()}
+		commits[name], err = object.WriteCommit(".", tree, parentHashes, sig, sig, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	add("A")
+	add("B", "A")
+	add("C", "A")
+	add("M", "B", "C")
+
+	if err := refs.CreateRef(".", "main", commits["M"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := refs.CreateRef(".", "topic", commits["C"]); err != nil {
+		t.Fatal(err)
+	}
+	return commits
+}
+
+func TestResolve(t *testing.T) {
+	commits := newHistory(t)
+
+	tests := []struct {
+		rev  string
+		want string
+	}{
+		{"HEAD", "M"},
+		{"@", "M"},
+		{"main", "M"},
+		{"topic", "C"},
+		{commits["B"].String(), "B"},
+		{commits["B"].Short(7), "B"},
+		{"HEAD~", "B"},
+		{"HEAD~1", "B"},
+		{"HEAD~2", "A"},
+		{"HEAD^", "B"},
+		{"HEAD^2", "C"},
+		{"HEAD^0", "M"},
+		{"HEAD^2~1", "A"},
+		{"main~~", "A"},
+		{"topic^", "A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rev, func(t *testing.T) {
+			got, err := Resolve(".", tt.rev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equals(commits[tt.want]) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, rev := range []string{"", "~1", "HEAD~3", "HEAD^3", "unknown", "HEAD~x", "zz"} {
+		if got, err := Resolve(".", rev); err == nil {
+			t.Errorf("%q resolved to %s", rev, got)
+		}
+	}
+}
+
+func TestResolveObject(t *testing.T) {
+	commits := newHistory(t)
+	c, err := object.ReadCommit(".", commits["B"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := c.TreeHash()
+	dir, err := object.FindTreeEntry(".", tree, "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := object.WriteBlobData(".", []byte("B\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rev  string
+		want object.ObjectHash
+	}{
+		{"HEAD~1:", tree},
+		{"HEAD~1^{tree}", tree},
+		{"HEAD~1:dir", dir.Hash},
+		{"HEAD~1:dir/f", blob},
+		{"HEAD~1:/dir/f/", blob},
+		{"HEAD~1^{commit}", commits["B"]},
+		{blob.Short(8), blob},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rev, func(t *testing.T) {
+			got, err := ResolveObject(".", tt.rev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equals(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ResolveObject(".", "HEAD:missing"); err == nil {
+		t.Error("resolved a missing path")
+	}
+}
@@ -1,32 +1,55 @@
 package status
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 
+	"github.com/matiasmartin00/arbor/internal/branch"
+	"github.com/matiasmartin00/arbor/internal/diff"
 	"github.com/matiasmartin00/arbor/internal/index"
 	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/pathspec"
+	"github.com/matiasmartin00/arbor/internal/refs"
 	"github.com/matiasmartin00/arbor/internal/tree"
 	"github.com/matiasmartin00/arbor/internal/utils"
 )
 
 type StatusDetail struct {
+	// Branch is the current branch, empty when HEAD is detached at Detached
+	Branch        string
+	Detached      object.ObjectHash
 	ToBeCommitted []string
 	NotStaged     []string
 	Untracked     []string
 }
 
-func fileBlobHash(path string) (object.ObjectHash, error) {
-	data, err := utils.ReadFile(path)
+func fileBlobHash(path string) (object.ObjectHash, object.FileMode, error) {
+	data, mode, err := object.ReadWorktreeFile(path)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return object.NewHashBlob(data)
+	hash, err := object.NewHashBlob(data)
+	return hash, mode, err
 }
 
-func Status(repoPath string) (StatusDetail, error) {
+// Status compares HEAD, the index and the working directory, limited to paths
+// when given (repository relative, see pathspec.Match).
+func Status(repoPath string, paths []string) (StatusDetail, error) {
+	currentBranch, err := branch.GetCurrentBranch(repoPath)
+	if err != nil && !errors.Is(err, branch.ErrDetachedHead) {
+		return StatusDetail{}, err
+	}
+
+	var detached object.ObjectHash
+	if len(currentBranch) == 0 {
+		detached, err = refs.GetRefHash(repoPath)
+		if err != nil {
+			return StatusDetail{}, err
+		}
+	}
 
 	idx, err := index.Load(repoPath)
 	if err != nil {
@@ -39,44 +62,77 @@ func Status(repoPath string) (StatusDetail, error) {
 		return StatusDetail{}, err
 	}
 
+	headModes, err := tree.GetHeadTreeModes(repoPath)
+	if err != nil {
+		return StatusDetail{}, err
+	}
+
 	// changes to be committed: index vs head tree
 	toBeCommitted := []string{}
+	added := map[string]object.ObjectHash{}
 	for p, ie := range idx {
+		if !pathspec.Match(paths, p) {
+			continue
+		}
+
 		hh, ok := headMap[p]
 		if !ok {
-			toBeCommitted = append(toBeCommitted, fmt.Sprintf("new file: %s", p))
+			added[p] = ie.Hash
 			continue
 		}
 
-		if ie.Hash.NotEquals(hh) {
+		// a mode-only change is a modification too
+		if ie.Hash.NotEquals(hh) || ie.Mode != headModes[p] {
 			toBeCommitted = append(toBeCommitted, fmt.Sprintf("modified: %s", p))
 		}
 	}
 
 	// dectect deleted staged, (in the head but not in the index)
-	for p := range headMap {
+	deleted := map[string]object.ObjectHash{}
+	for p, hh := range headMap {
+		if !pathspec.Match(paths, p) {
+			continue
+		}
+
 		if _, ok := idx[p]; !ok {
-			toBeCommitted = append(toBeCommitted, fmt.Sprintf("deleted: %s", p))
+			deleted[p] = hh
 		}
 	}
 
+	renamed, err := stagedRenames(repoPath, headMap, deleted, added)
+	if err != nil {
+		return StatusDetail{}, err
+	}
+	toBeCommitted = append(toBeCommitted, renamed...)
+
+	for p := range added {
+		toBeCommitted = append(toBeCommitted, fmt.Sprintf("new file: %s", p))
+	}
+	for p := range deleted {
+		toBeCommitted = append(toBeCommitted, fmt.Sprintf("deleted: %s", p))
+	}
+
 	// changes not staged for commit: workdir vs index
 	notStaged := []string{}
 	for p, ie := range idx {
-		if _, err := os.Stat(p); err != nil {
+		if !pathspec.Match(paths, p) {
+			continue
+		}
+
+		if _, err := os.Lstat(p); err != nil {
 			if os.IsNotExist(err) {
-				notStaged = append(notStaged, fmt.Sprintf("deleted: %s"))
+				notStaged = append(notStaged, fmt.Sprintf("deleted: %s", p))
 				continue
 			}
 			return StatusDetail{}, err
 		}
 
-		curHash, err := fileBlobHash(p)
+		curHash, curMode, err := fileBlobHash(p)
 		if err != nil {
 			return StatusDetail{}, err
 		}
 
-		if curHash.NotEquals(ie.Hash) {
+		if curHash.NotEquals(ie.Hash) || curMode != ie.Mode {
 			notStaged = append(notStaged, fmt.Sprintf("modified: %s", p))
 		}
 	}
@@ -99,7 +155,7 @@ func Status(repoPath string) (StatusDetail, error) {
 
 		rel := filepath.ToSlash(path)
 
-		if _, ok := idx[rel]; ok {
+		if _, ok := idx[rel]; ok || !pathspec.Match(paths, rel) {
 			return nil
 		}
 
@@ -112,8 +168,42 @@ func Status(repoPath string) (StatusDetail, error) {
 	}
 
 	return StatusDetail{
+		Branch:        currentBranch,
+		Detached:      detached,
 		ToBeCommitted: toBeCommitted,
 		NotStaged:     notStaged,
 		Untracked:     untracked,
 	}, nil
 }
+
+// stagedRenames pairs the staged deletions and new files that are renames (or
+// copies of files in HEAD, when diff.renames is "copies"), and removes them
+// from deleted and added.
+func stagedRenames(repoPath string, headMap, deleted, added map[string]object.ObjectHash) ([]string, error) {
+	renames, copies, err := diff.RenamesFromConfig(repoPath)
+	if err != nil || !renames || len(added) == 0 {
+		return nil, err
+	}
+
+	var copySources map[string]object.ObjectHash
+	if copies {
+		copySources = headMap
+	}
+
+	found, err := diff.DetectRenames(repoPath, deleted, added, copySources, diff.DefaultRenameThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []string{}
+	for _, r := range found {
+		delete(added, r.To)
+		if r.Copy {
+			out = append(out, fmt.Sprintf("copied: %s -> %s", r.From, r.To))
+			continue
+		}
+		delete(deleted, r.From)
+		out = append(out, fmt.Sprintf("renamed: %s -> %s", r.From, r.To))
+	}
+	return out, nil
+}
@@ -0,0 +1,64 @@
+package status
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/matiasmartin00/arbor/internal/add"
+	"github.com/matiasmartin00/arbor/internal/commit"
+	"github.com/matiasmartin00/arbor/internal/repo"
+)
+
+// newRepo creates a repository in a temporary directory and moves into it.
+func newRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("ARBOR_CONFIG_USER__NAME", "T")
+	t.Setenv("ARBOR_CONFIG_USER__EMAIL", "t@x")
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestModeOnlyChanges(t *testing.T) {
+	newRepo(t)
+	if err := os.WriteFile("run.sh", []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("run.sh", "link"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := add.Add(".", false, []string{"run.sh", "link"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := commit.Commit(".", commit.CommitOptions{Message: "script"}); err != nil {
+		t.Fatal(err)
+	}
+
+	status := func() StatusDetail {
+		t.Helper()
+		s, err := Status(".", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	if s := status(); len(s.ToBeCommitted) != 0 || len(s.NotStaged) != 0 || len(s.Untracked) != 0 {
+		t.Fatalf("got %+v right after the commit, want a symlink and a file unchanged", s)
+	}
+
+	if err := os.Chmod("run.sh", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(); len(s.ToBeCommitted) != 0 || !slices.Equal(s.NotStaged, []string{"modified: run.sh"}) {
+		t.Errorf("got %+v, want the executable bit not staged", s)
+	}
+
+	if _, err := add.Add(".", false, []string{"run.sh"}); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(); !slices.Equal(s.ToBeCommitted, []string{"modified: run.sh"}) || len(s.NotStaged) != 0 {
+		t.Errorf("got %+v, want the executable bit staged", s)
+	}
+}
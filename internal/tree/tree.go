@@ -16,37 +16,66 @@ func WriteTree(repoPath string) (object.ObjectHash, error) {
 
 	// build a map of path -> hash
 	entries := make(map[string]object.ObjectHash, len(idx))
+	modes := make(map[string]object.FileMode, len(idx))
 	for p, ie := range idx {
 		entries[filepath.ToSlash(p)] = ie.Hash
+		modes[filepath.ToSlash(p)] = ie.Mode
 	}
 
-	return object.WriteTree(repoPath, entries)
+	return object.WriteTree(repoPath, entries, modes)
 }
 
 func GetHeadTreeMap(repoPath string) (map[string]object.ObjectHash, error) {
 	m := map[string]object.ObjectHash{}
 
-	commitHash, err := refs.GetRefHash(repoPath)
+	tree, err := headTree(repoPath)
 	if err != nil {
 		return nil, err
 	}
 
-	// if commit hash is nil, no head yet
-	if commitHash == nil {
+	if tree == nil {
 		return m, nil
 	}
 
-	commit, err := object.ReadCommit(repoPath, commitHash)
+	tree.FillPathMap(m)
+
+	return m, nil
+}
+
+// GetHeadTreeModes maps the files of the HEAD tree to their modes.
+func GetHeadTreeModes(repoPath string) (map[string]object.FileMode, error) {
+	m := map[string]object.FileMode{}
+
+	tree, err := headTree(repoPath)
 	if err != nil {
 		return nil, err
 	}
 
-	tree, err := object.ReadTree(repoPath, commit.TreeHash())
+	if tree == nil {
+		return m, nil
+	}
+
+	tree.FillModeMap(m)
+
+	return m, nil
+}
+
+// headTree reads the tree of HEAD, nil when there are no commits yet.
+func headTree(repoPath string) (object.Tree, error) {
+	commitHash, err := refs.GetRefHash(repoPath)
 	if err != nil {
 		return nil, err
 	}
 
-	tree.FillPathMap(m)
+	// if commit hash is nil, no head yet
+	if commitHash == nil {
+		return nil, nil
+	}
 
-	return m, nil
+	commit, err := object.ReadCommit(repoPath, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.ReadTree(repoPath, commit.TreeHash())
 }
@@ -1,9 +1,14 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
 )
 
 const repoDir = ".arbor"
@@ -40,8 +45,80 @@ func Exists(path string) bool {
 	return !os.IsNotExist(err)
 }
 
+// TempPrefix starts the names of the temporary files WriteFile renames into place.
+const TempPrefix = ".tmp-"
+
+// WriteFile replaces path with data atomically: data goes to a temporary file
+// next to path, synced to disk and renamed over path, so a crash leaves either
+// the old or the new content, never a part of it.
 func WriteFile(path string, data []byte) error {
-	return os.WriteFile(path, data, 0644)
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, TempPrefix+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	// nothing to remove once renamed
+	defer os.Remove(tmp.Name())
+
+	if err := WriteSynced(tmp, data); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return SyncDir(dir)
+}
+
+// TempFiles lists the temporary files of WriteFile under dir, those of a crash
+// are left behind.
+func TempFiles(dir string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasPrefix(d.Name(), TempPrefix) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// WriteSynced writes data to f with 0644 permissions, flushes it to disk and
+// closes f.
+func WriteSynced(f *os.File, data []byte) error {
+	_, err := f.Write(data)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// SyncDir flushes the entries of dir to disk, so a rename in it survives a crash.
+// Filesystems and systems that can not sync directories are skipped.
+func SyncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTSUP) {
+		return err
+	}
+	return nil
 }
 
 func ReadFile(path string) ([]byte, error) {
@@ -49,20 +126,21 @@ func ReadFile(path string) ([]byte, error) {
 }
 
 func IsBinary(data []byte) bool {
-    n := len(data)
-    if n > 8000 {
-        n = 8000 // limit
-    }
-    for _, b := range data[:n] {
-        if b == 0 {
-            return true
-        }
-    }
-    return false
+	n := len(data)
+	if n > 8000 {
+		n = 8000 // limit
+	}
+	for _, b := range data[:n] {
+		if b == 0 {
+			return true
+		}
+	}
+	return false
 }
 
 func RemoveFile(path string) error {
-	if _, err := os.Stat(path); err == nil {
+	// Lstat, so a symlink is removed even if its target is missing
+	if _, err := os.Lstat(path); err == nil {
 		if err := os.Remove(path); err != nil {
 			return fmt.Errorf("remove %s: %w", path, err)
 		}
@@ -1,102 +1,319 @@
 package worktree
 
 import (
-	"path/filepath"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
 
+	"github.com/matiasmartin00/arbor/internal/diff"
 	"github.com/matiasmartin00/arbor/internal/index"
+	"github.com/matiasmartin00/arbor/internal/journal"
 	"github.com/matiasmartin00/arbor/internal/object"
-	"github.com/matiasmartin00/arbor/internal/repo"
+	"github.com/matiasmartin00/arbor/internal/tree"
 	"github.com/matiasmartin00/arbor/internal/utils"
 )
 
-func RestoreCommitWorktree(repoPath string, commitHash object.ObjectHash) error {
-	// ensure repository is clean
-	if err := repo.EnsureCleanWorktree(repoPath); err != nil {
-		return err
+// Options decide what happens to the local changes, staged or not, when the
+// working directory moves to another commit. By default the changes to files
+// that are the same in both commits are kept, and the move is refused when a
+// changed file would be overwritten.
+type Options struct {
+	// Action names the transaction, see journal.Begin
+	Action string
+	// Force discards every local change
+	Force bool
+	// Merge merges the local changes into the files that change, TargetName
+	// labels the new version in the conflict markers
+	Merge      bool
+	TargetName string
+}
+
+// version of a file in a commit, the index or the working directory, a nil hash
+// when it is missing
+type version struct {
+	hash object.ObjectHash
+	mode object.FileMode
+}
+
+func (v version) equals(o version) bool {
+	if v.hash == nil || o.hash == nil {
+		return v.hash == nil && o.hash == nil
 	}
+	return v.hash.Equals(o.hash) && v.mode == o.mode
+}
 
-	// read commit object
-	commit, err := object.ReadCommit(repoPath, commitHash)
+// RestoreCommitWorktree makes the working directory and the index match the
+// commit, along with the ref updates, as one transaction (see journal.Begin).
+// Local changes are carried over as opts says. It returns the files merged
+// with conflicts.
+func RestoreCommitWorktree(repoPath string, commitHash object.ObjectHash, opts Options, updates ...journal.RefUpdate) ([]string, error) {
+	// no other writer while the working directory changes
+	tx, idx, err := journal.Begin(repoPath, opts.Action)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer tx.Abort()
 
-	tree, err := object.ReadTree(repoPath, commit.TreeHash())
+	head, err := headFiles(repoPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// apply tree to working directory
-	err = applyTree(repoPath, tree)
+	target, err := commitFiles(repoPath, commitHash)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// build map[path]hash for entire in the tree
-	treeMap := make(map[string]object.ObjectHash)
-	tree.FillPathMap(treeMap)
+	changed, untracked, conflicts := []string{}, []string{}, []string{}
+	for _, p := range allPaths(head, target, idx) {
+		h, t := head[p], target[p]
+		i := version{}
+		if ie, ok := idx[p]; ok {
+			i = version{ie.Hash, ie.Mode}
+		}
 
-	// remove tracked that are in the index but not in the tree
-	idx, err := index.Load(repoPath)
-	if err != nil {
-		return err
-	}
+		// the file is the same in both commits, or already staged as in the new one
+		if !opts.Force && (h.equals(t) || i.equals(t)) {
+			continue
+		}
+
+		w, err := worktreeFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		// without local changes, or with the file already as in the new commit
+		if opts.Force || (i.equals(h) && w.equals(i)) || w.equals(t) {
+			update(tx, idx, p, i, w, t)
+			continue
+		}
+
+		if h.hash == nil && i.hash == nil {
+			untracked = append(untracked, p)
+			continue
+		}
 
-	for path := range idx {
-		if _, ok := treeMap[path]; !ok {
-			// file is in index but not in tree, remove it
-			if err := utils.RemoveFile(path); err != nil {
-				return err
-			}
-			delete(idx, path)
+		if !opts.Merge {
+			changed = append(changed, p)
+			continue
+		}
+
+		conflict, err := mergeLocal(repoPath, tx, idx, p, h, t, opts.TargetName)
+		if err != nil {
+			return nil, err
 		}
+		if conflict {
+			conflicts = append(conflicts, p)
+		}
+	}
+
+	if len(untracked) > 0 {
+		return nil, fmt.Errorf("the untracked files %s would be overwritten by %s, move or remove them first",
+			strings.Join(untracked, ", "), opts.Action)
+	}
+	if len(changed) > 0 {
+		return nil, fmt.Errorf("your local changes to %s would be overwritten by %s, commit or discard them first",
+			strings.Join(changed, ", "), opts.Action)
 	}
 
-	// update index to match tree
-	for path, hash := range treeMap {
-		idx.AddEntry(path, hash)
+	tx.UpdateRefs(updates...)
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
+	return conflicts, nil
+}
 
-	if err := idx.Save(repoPath); err != nil {
+// CheckLocalChanges fails when writing the files of target, along with their
+// modes, would lose local changes, staged or not, or overwrite untracked files.
+// A nil hash removes the file. Files already as in target are fine.
+func CheckLocalChanges(repoPath string, idx index.Index, target map[string]object.ObjectHash, modes map[string]object.FileMode, action string) error {
+	head, err := headFiles(repoPath)
+	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	paths := make([]string, 0, len(target))
+	for p := range target {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
 
-// applyTree writes files and directories from the given tree object into basePath. (relative to repo root)
-func applyTree(repoPath string, tree object.Tree) error {
-	for _, bl := range tree.Blobs() {
-		// ensure directory exists, the bl.File contains the full path with /
-		dir := filepath.Dir(bl.File)
-		if dir != "." {
-			if err := utils.CreateDir(dir); err != nil {
-				return err
-			}
+	changed, untracked := []string{}, []string{}
+	for _, p := range paths {
+		h, t := head[p], version{}
+		if target[p] != nil {
+			t = version{target[p], modes[p]}
+		}
+		i := version{}
+		if ie, ok := idx[p]; ok {
+			i = version{ie.Hash, ie.Mode}
 		}
 
-		// read blob data
-		blob, err := object.ReadBlob(repoPath, bl.Hash)
+		w, err := worktreeFile(p)
 		if err != nil {
 			return err
 		}
 
-		// write file
-		if err := utils.WriteFile(bl.File, blob.Data()); err != nil {
-			return err
+		switch {
+		case (i.equals(h) && w.equals(i)) || w.equals(t):
+		case h.hash == nil && i.hash == nil:
+			untracked = append(untracked, p)
+		default:
+			changed = append(changed, p)
 		}
 	}
 
-	for _, st := range tree.SubTrees() {
-		// create directory and recurse
-		if err := utils.CreateDir(st.Basepath()); err != nil {
-			return err
+	if len(untracked) > 0 {
+		return fmt.Errorf("the untracked files %s would be overwritten by %s, move or remove them first",
+			strings.Join(untracked, ", "), action)
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("your local changes to %s would be overwritten by %s, commit or discard them first",
+			strings.Join(changed, ", "), action)
+	}
+	return nil
+}
+
+// update stages the version t of p and writes it when the working directory has
+// another one, a missing t removes the file.
+func update(tx *journal.Transaction, idx index.Index, p string, i, w, t version) {
+	if t.hash == nil {
+		if w.hash != nil && i.hash != nil {
+			tx.Remove(p)
 		}
+		idx.Remove(p)
+		return
+	}
 
-		if err := applyTree(repoPath, st); err != nil {
-			return err
+	if !w.equals(t) {
+		tx.Write(p, t.hash, t.mode)
+	}
+	if !i.equals(t) {
+		idx.AddEntryMode(p, t.hash, t.mode)
+	}
+}
+
+// mergeLocal merges the changes made to p in the working directory since h into
+// its version t, and stages t. It reports if the merge has conflicts.
+func mergeLocal(repoPath string, tx *journal.Transaction, idx index.Index, p string, h, t version, targetName string) (bool, error) {
+	local, mode, err := object.ReadWorktreeFile(p)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	if err != nil || h.hash == nil || t.hash == nil ||
+		mode == object.ModeSymlink || h.mode == object.ModeSymlink || t.mode == object.ModeSymlink {
+		return false, fmt.Errorf("can not merge the local changes to %s, commit or discard them first", p)
+	}
+
+	base, err := object.ReadBlob(repoPath, h.hash)
+	if err != nil {
+		return false, err
+	}
+	theirs, err := object.ReadBlob(repoPath, t.hash)
+	if err != nil {
+		return false, err
+	}
+
+	if utils.IsBinary(local) || utils.IsBinary(base.Data()) || utils.IsBinary(theirs.Data()) {
+		return false, fmt.Errorf("can not merge the local changes to the binary file %s, commit or discard them first", p)
+	}
+
+	merged, conflict := diff.Merge3(base.Data(), local, theirs.Data(), "local", targetName)
+	hash, err := object.WriteBlobData(repoPath, merged)
+	if err != nil {
+		return false, err
+	}
+
+	tx.Write(p, hash, t.mode)
+	idx.AddEntryMode(p, t.hash, t.mode)
+	return conflict, nil
+}
+
+// worktreeFile hashes the file at p, a missing file or a directory has no hash.
+func worktreeFile(p string) (version, error) {
+	info, err := os.Lstat(p)
+	if err != nil {
+		if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+			return version{}, nil
 		}
+		return version{}, err
+	}
+	if info.IsDir() {
+		return version{}, nil
 	}
 
-	return nil
+	data, mode, err := object.ReadWorktreeFile(p)
+	if err != nil {
+		return version{}, err
+	}
+
+	hash, err := object.NewHashBlob(data)
+	if err != nil {
+		return version{}, err
+	}
+	return version{hash, mode}, nil
+}
+
+func headFiles(repoPath string) (map[string]version, error) {
+	hashes, err := tree.GetHeadTreeMap(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	modes, err := tree.GetHeadTreeModes(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	return versions(hashes, modes), nil
+}
+
+func commitFiles(repoPath string, commitHash object.ObjectHash) (map[string]version, error) {
+	commit, err := object.ReadCommit(repoPath, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := object.ReadTree(repoPath, commit.TreeHash())
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string]object.ObjectHash)
+	t.FillPathMap(hashes)
+	modes := make(map[string]object.FileMode)
+	t.FillModeMap(modes)
+	return versions(hashes, modes), nil
+}
+
+func versions(hashes map[string]object.ObjectHash, modes map[string]object.FileMode) map[string]version {
+	m := make(map[string]version, len(hashes))
+	for p, h := range hashes {
+		m[p] = version{h, modes[p]}
+	}
+	return m
+}
+
+// allPaths lists the files of both commits and the index, sorted.
+func allPaths(head, target map[string]version, idx index.Index) []string {
+	seen := map[string]bool{}
+	for p := range head {
+		seen[p] = true
+	}
+	for p := range target {
+		seen[p] = true
+	}
+	for p := range idx {
+		seen[p] = true
+	}
+
+	paths := make([]string, 0, len(seen))
+	for p := range seen {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
 }
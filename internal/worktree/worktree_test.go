@@ -0,0 +1,232 @@
+package worktree
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matiasmartin00/arbor/internal/journal"
+	"github.com/matiasmartin00/arbor/internal/object"
+	"github.com/matiasmartin00/arbor/internal/repo"
+	"github.com/matiasmartin00/arbor/internal/utils"
+)
+
+// newRepo creates a repository in a temporary directory and moves into it.
+func newRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := repo.Init("."); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeRawObject stores data as is, as a crafted repository could hold it.
+func writeRawObject(t *testing.T, typ, data string) object.ObjectHash {
+	t.Helper()
+	content := fmt.Sprintf("%s %d\x00%s", typ, len(data), data)
+	sum := sha1.Sum([]byte(content))
+	hash, err := object.NewObjectHash(hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(utils.GetObjectsDir("."), hash.Dir())
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, hash.File()), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func writeCommit(t *testing.T, tree object.ObjectHash) object.ObjectHash {
+	t.Helper()
+	sig := object.Signature{Name: "T", Email: "t@x", When: time.Unix(0, 0)}
+	hash, err := object.WriteCommit(".", tree, nil, sig, sig, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func writeBlob(t *testing.T, data string) object.ObjectHash {
+	t.Helper()
+	hash, err := object.WriteBlobData(".", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestRestoreRejectsMaliciousTrees(t *testing.T) {
+	newRepo(t)
+	blob := writeBlob(t, "pwned\n")
+	inner := writeRawObject(t, "tree", fmt.Sprintf("100644 blob %s config\n", blob))
+
+	trees := map[string]string{
+		"parent file":   fmt.Sprintf("100644 blob %s ../escape\n", blob),
+		"parent dir":    fmt.Sprintf("040000 tree %s ..\n", inner),
+		"repo dir":      fmt.Sprintf("040000 tree %s .arbor\n", inner),
+		"repo dir case": fmt.Sprintf("040000 tree %s .Arbor\n", inner),
+		"absolute":      fmt.Sprintf("100644 blob %s /tmp/escape\n", blob),
+		"nested":        fmt.Sprintf("040000 tree %s sub\n", writeRawObject(t, "tree", fmt.Sprintf("040000 tree %s .arbor\n", inner))),
+	}
+
+	for name, data := range trees {
+		commit := writeCommit(t, writeRawObject(t, "tree", data))
+		if _, err := RestoreCommitWorktree(".", commit, Options{Action: "checkout"}); err == nil {
+			t.Errorf("%s: checkout of %q succeeded", name, data)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join("..", "escape")); !os.IsNotExist(err) {
+		t.Errorf("file written outside the worktree: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(utils.GetRepoDir("."), "config")); !os.IsNotExist(err) {
+		t.Errorf("file written into the repository directory: %v", err)
+	}
+}
+
+// a tree can not hold a symlink and a directory with the same name, which would
+// write the directory through the symlink
+func TestRestoreRejectsSymlinkAndDirWithSameName(t *testing.T) {
+	outside := t.TempDir()
+	newRepo(t)
+
+	link := writeBlob(t, outside)
+	sub := writeRawObject(t, "tree", fmt.Sprintf("100644 blob %s file\n", writeBlob(t, "pwned\n")))
+	tree := writeRawObject(t, "tree", fmt.Sprintf("120000 blob %s link\n040000 tree %s link\n", link, sub))
+
+	if _, err := RestoreCommitWorktree(".", writeCommit(t, tree), Options{Action: "checkout"}); err == nil {
+		t.Fatal("checkout of a tree with a duplicate entry succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "file")); !os.IsNotExist(err) {
+		t.Fatalf("file written through the symlink: %v", err)
+	}
+}
+
+func TestRestoreRefusesUntrackedSymlinkedDir(t *testing.T) {
+	outside := t.TempDir()
+	newRepo(t)
+
+	tree, err := object.WriteTree(".", map[string]object.ObjectHash{"dir/file": writeBlob(t, "data\n")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(outside, "dir"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := RestoreCommitWorktree(".", writeCommit(t, tree), Options{Action: "checkout"}); err == nil {
+		t.Fatal("checkout wrote through an untracked symlink")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "file")); !os.IsNotExist(err) {
+		t.Fatalf("file written through the symlink: %v", err)
+	}
+}
+
+// switching from a commit where dir is a symlink to one where it is a directory
+// replaces the symlink, its target is left alone
+func TestRestoreReplacesTrackedSymlinkWithDir(t *testing.T) {
+	outside := t.TempDir()
+	newRepo(t)
+
+	first, err := object.WriteTree(".",
+		map[string]object.ObjectHash{"dir": writeBlob(t, outside)},
+		map[string]object.FileMode{"dir": object.ModeSymlink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := object.WriteTree(".", map[string]object.ObjectHash{"dir/file": writeBlob(t, "data\n")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the branch follows, so the second checkout leaves the first commit
+	firstCommit := writeCommit(t, first)
+	if _, err := RestoreCommitWorktree(".", firstCommit, Options{Action: "checkout"}, journal.SetBranch("main", firstCommit)); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink("dir"); err != nil || target != outside {
+		t.Fatalf("dir = %q, %v, want a symlink to %s", target, err, outside)
+	}
+
+	if _, err := RestoreCommitWorktree(".", writeCommit(t, second), Options{Action: "checkout"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join("dir", "file")); err != nil || string(data) != "data\n" {
+		t.Fatalf("dir/file = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "file")); !os.IsNotExist(err) {
+		t.Fatalf("file written through the old symlink: %v", err)
+	}
+}
+
+func TestRestoreCarriesLocalChanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		local     string
+		opts      Options
+		want      string
+		conflicts int
+		err       string
+	}{
+		{name: "same in both", file: "g", local: "local\n", want: "local\n"},
+		{name: "refused", file: "f", local: "A\nb\nc\n", err: "your local changes to f"},
+		{name: "merged", file: "f", local: "A\nb\nc\n", opts: Options{Merge: true}, want: "A\nb\nC\n"},
+		{
+			name: "merged with conflicts", file: "f", local: "a\nb\nlocal\n", opts: Options{Merge: true, TargetName: "second"},
+			want: "a\nb\n<<<<<<< local\nlocal\n=======\nC\n>>>>>>> second\n", conflicts: 1,
+		},
+		{name: "forced", file: "f", local: "A\nb\nc\n", opts: Options{Force: true}, want: "a\nb\nC\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newRepo(t)
+			g := writeBlob(t, "x\n")
+			first, err := object.WriteTree(".", map[string]object.ObjectHash{"f": writeBlob(t, "a\nb\nc\n"), "g": g}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := object.WriteTree(".", map[string]object.ObjectHash{"f": writeBlob(t, "a\nb\nC\n"), "g": g}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			firstCommit := writeCommit(t, first)
+			if _, err := RestoreCommitWorktree(".", firstCommit, Options{Action: "checkout"}, journal.SetBranch("main", firstCommit)); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(tt.file, []byte(tt.local), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.opts.Action = "checkout"
+			conflicts, err := RestoreCommitWorktree(".", writeCommit(t, second), tt.opts)
+			if len(tt.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %v, want %q", err, tt.err)
+				}
+				tt.want = tt.local
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(conflicts) != tt.conflicts {
+				t.Errorf("got conflicts %v, want %d", conflicts, tt.conflicts)
+			}
+			if data, err := os.ReadFile(tt.file); err != nil || string(data) != tt.want {
+				t.Errorf("%s = %q, %v, want %q", tt.file, data, err, tt.want)
+			}
+		})
+	}
+}